@@ -0,0 +1,475 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/output"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+var (
+	// Batch command flags
+	batchConcurrency int
+	batchResults     string
+	batchOverwrite   bool
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <manifest>",
+	Short: "Generate many images from a JSONL or YAML manifest",
+	Long: `Run every job in a manifest with bounded concurrency.
+
+Each job takes the same fields as generate. JSONL manifests hold one job per
+line; YAML manifests hold a list of jobs (or a "jobs:" key with that list).
+Relative input and output paths are resolved against the manifest directory.
+
+JOB FIELDS:
+  id                 Job name used in results (default job-N)
+  prompt             Prompt text (required)
+  output             Output image path (default <id>.png); its extension
+                     sets the format, as on generate
+  inputs             Reference images: paths, http(s) URLs or data: URIs
+  fit_inputs         Downscale inputs that exceed the request size limit
+  upload_inputs      Send inputs through the Files API, reusing uploads
+  quality            JPEG quality 1-100 when output is .jpg
+  model              Model alias or ID (default: --model)
+  aspect_ratio       Aspect ratio, e.g. 16:9
+  image_size         512, 1K, 2K, 4K
+  count              Images per job (1-10)
+  thinking_level     minimal, high
+  system             System instruction
+  safety             Map of category to threshold, e.g. {"dangerous": "none"}
+  temperature, top_p, top_k, seed, candidate_count
+                     Sampling controls, as on generate
+  include_thoughts   Include thought parts
+  ground_web         Ground with Google Search
+  ground_image       Ground with Google Image Search
+
+RESULTS:
+  Each finished job appends one line to the results file (default
+  <manifest>.results.jsonl) in the same shape as "generate --json", plus a
+  "job" field. Jobs whose output already exists (for every candidate, with
+  count) are skipped, so rerunning a manifest resumes where it stopped; use
+  --overwrite to regenerate everything.
+
+EXAMPLES:
+  # jobs.jsonl
+  {"id": "hero", "prompt": "a lighthouse at dusk", "output": "out/hero.png", "aspect_ratio": "16:9"}
+  {"id": "icon", "prompt": "a flat banana icon", "output": "out/icon.png", "model": "banana"}
+
+  # Run four jobs at a time
+  nanobanana batch jobs.jsonl --concurrency 4
+
+  # Regenerate everything and write results elsewhere
+  nanobanana batch jobs.yaml --overwrite --results run.jsonl
+
+BATCH API:
+  For large overnight runs, "batch submit" sends the same manifest to the
+  Gemini Batch API at half price; "batch status" and "batch fetch" poll it and
+  save the images. See "nanobanana batch submit --help".`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBatch,
+}
+
+func init() {
+	batchCmd.Flags().IntVar(&batchConcurrency, "concurrency", gemini.DefaultConcurrency, "Maximum jobs in flight")
+	batchCmd.Flags().StringVar(&batchResults, "results", "", "Results file (default <manifest>.results.jsonl)")
+	batchCmd.Flags().BoolVar(&batchOverwrite, "overwrite", false, "Regenerate jobs whose output already exists")
+
+	addGeminiFlags(batchCmd)
+
+	rootCmd.AddCommand(batchCmd)
+}
+
+// batchJob is one manifest entry. Fields mirror gemini.GenerateOptions.
+type batchJob struct {
+	ID              string            `json:"id" yaml:"id"`
+	Prompt          string            `json:"prompt" yaml:"prompt"`
+	Output          string            `json:"output" yaml:"output"`
+	Inputs          []string          `json:"inputs" yaml:"inputs"`
+	FitInputs       bool              `json:"fit_inputs" yaml:"fit_inputs"`
+	UploadInputs    bool              `json:"upload_inputs" yaml:"upload_inputs"`
+	Quality         int               `json:"quality" yaml:"quality"`
+	Model           string            `json:"model" yaml:"model"`
+	AspectRatio     string            `json:"aspect_ratio" yaml:"aspect_ratio"`
+	ImageSize       string            `json:"image_size" yaml:"image_size"`
+	Count           int               `json:"count" yaml:"count"`
+	ThinkingLevel   string            `json:"thinking_level" yaml:"thinking_level"`
+	System          string            `json:"system" yaml:"system"`
+	Temperature     *float64          `json:"temperature" yaml:"temperature"`
+	TopP            *float64          `json:"top_p" yaml:"top_p"`
+	TopK            *int              `json:"top_k" yaml:"top_k"`
+	Seed            *int32            `json:"seed" yaml:"seed"`
+	CandidateCount  int               `json:"candidate_count" yaml:"candidate_count"`
+	Safety          map[string]string `json:"safety" yaml:"safety"`
+	IncludeThoughts bool              `json:"include_thoughts" yaml:"include_thoughts"`
+	GroundWeb       bool              `json:"ground_web" yaml:"ground_web"`
+	GroundImage     bool              `json:"ground_image" yaml:"ground_image"`
+}
+
+// batchResult is a results-file line: a generate response tagged with its job.
+type batchResult struct {
+	Job string `json:"job"`
+	output.Response
+}
+
+func runBatch(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	startTime := time.Now()
+	manifest := args[0]
+
+	jobs, err := loadBatchJobs(manifest)
+	if err != nil {
+		f.Error("batch", "INVALID_MANIFEST", err.Error(), "Use JSONL (one job per line) or a YAML list of jobs")
+		return err
+	}
+
+	apiKey := GetAPIKey()
+	if apiKey == "" && !usingVertex() {
+		f.Error("batch", "MISSING_API_KEY", "No API key provided", "Set GEMINI_API_KEY environment variable or use --api-key flag")
+		return fmt.Errorf("missing API key")
+	}
+
+	if batchConcurrency < 1 {
+		f.Error("batch", "INVALID_CONCURRENCY", "Concurrency must be at least 1", "")
+		return fmt.Errorf("invalid concurrency")
+	}
+
+	resultsPath := batchResults
+	if resultsPath == "" {
+		resultsPath = strings.TrimSuffix(manifest, filepath.Ext(manifest)) + ".results.jsonl"
+	}
+	resultsFile, err := os.OpenFile(resultsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		f.Error("batch", "RESULTS_OPEN_FAILED", err.Error(), "")
+		return err
+	}
+	defer resultsFile.Close()
+
+	clients := &batchClients{apiKey: apiKey, clients: map[string]*gemini.Client{}}
+	var (
+		mu                         sync.Mutex
+		wg                         sync.WaitGroup
+		succeeded, failed, skipped int
+	)
+	sem := make(chan struct{}, batchConcurrency)
+
+	f.Progress("Running %d jobs from %s...", len(jobs), manifest)
+	for _, job := range jobs {
+		if !batchOverwrite && batchOutputExists(job) {
+			skipped++
+			f.Progress("Skipping %s: %s exists", job.ID, job.Output)
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(job batchJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res := runBatchJob(context.Background(), clients, job)
+			line, _ := json.Marshal(res)
+
+			mu.Lock()
+			defer mu.Unlock()
+			resultsFile.Write(append(line, '\n'))
+			if res.Success {
+				succeeded++
+				f.Progress("%s: done", job.ID)
+			} else {
+				failed++
+				f.Progress("%s: failed [%s] %s", job.ID, res.Error.Code, res.Error.Message)
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	timing := &output.Timing{TotalMs: time.Since(startTime).Milliseconds()}
+	summary := map[string]any{
+		"manifest":  manifest,
+		"results":   resultsPath,
+		"total":     len(jobs),
+		"succeeded": succeeded,
+		"failed":    failed,
+		"skipped":   skipped,
+	}
+	if failed > 0 {
+		details := map[string]string{}
+		for k, v := range summary {
+			details[k] = fmt.Sprint(v)
+		}
+		f.ErrorWithDetails("batch", "BATCH_INCOMPLETE", fmt.Sprintf("%d of %d jobs failed", failed, len(jobs)),
+			"See the results file, fix the failed jobs and rerun; finished jobs are skipped", details)
+		return fmt.Errorf("%d jobs failed", failed)
+	}
+
+	f.Info("%d succeeded, %d skipped. Results: %s", succeeded, skipped, resultsPath)
+	f.Success("batch", summary, timing)
+	return nil
+}
+
+// runBatchJob generates one job and returns its results line.
+func runBatchJob(ctx context.Context, clients *batchClients, job batchJob) batchResult {
+	startTime := time.Now()
+	res := batchResult{Job: job.ID, Response: output.Response{Command: "generate"}}
+
+	if err := job.validate(); err != nil {
+		res.Error = &output.ErrorInfo{Code: "INVALID_JOB", Message: err.Error()}
+		return res
+	}
+
+	client, err := clients.get(job.Model)
+	if err != nil {
+		res.Error = &output.ErrorInfo{Code: "CLIENT_ERROR", Message: err.Error(), Hint: clientErrorHint()}
+		return res
+	}
+
+	opts := batchJobOptions(job)
+	// Jobs already run in parallel; keep candidates sequential so
+	// --concurrency bounds total requests.
+	opts.Concurrency = 1
+	result, err := client.Generate(ctx, job.Prompt, opts)
+	if err != nil {
+		res.Error = generateErrorInfo(err)
+		return res
+	}
+
+	images, err := saveGeneratedImages(client, result, job.Output, job.candidates(), job.Quality, nil)
+	if err != nil {
+		res.Error = &output.ErrorInfo{Code: "SAVE_FAILED", Message: err.Error()}
+		return res
+	}
+
+	recordUsage("batch", result)
+	res.Success = true
+	res.Data = generateResultData(job.Prompt, client.Model().Spec, opts, result, images)
+	res.Timing = &output.Timing{
+		TotalMs:  time.Since(startTime).Milliseconds(),
+		Attempts: result.Attempts,
+		Retries:  result.Retries,
+	}
+	return res
+}
+
+func batchJobOptions(job batchJob) *gemini.GenerateOptions {
+	// Safety settings are checked by validate.
+	safety, _ := gemini.ParseSafetyMap(job.Safety)
+	return &gemini.GenerateOptions{
+		AspectRatio:       job.AspectRatio,
+		ImageSize:         job.ImageSize,
+		Count:             job.Count,
+		InputPaths:        job.Inputs,
+		FitInputs:         job.FitInputs,
+		UploadInputs:      job.UploadInputs,
+		GroundWeb:         job.GroundWeb,
+		GroundImage:       job.GroundImage,
+		IncludeThoughts:   job.IncludeThoughts,
+		ThinkingLevel:     job.ThinkingLevel,
+		SystemInstruction: job.System,
+		Sampling: gemini.Sampling{
+			Temperature:    job.Temperature,
+			TopP:           job.TopP,
+			TopK:           job.TopK,
+			Seed:           job.Seed,
+			CandidateCount: job.CandidateCount,
+		},
+		SafetySettings: safety,
+	}
+}
+
+// candidates is the number of images the job asks for, whether as separate
+// requests (count) or native candidates (candidate_count).
+func (j batchJob) candidates() int {
+	return max(j.Count, j.CandidateCount)
+}
+
+// batchClients shares one client per model across jobs.
+type batchClients struct {
+	apiKey  string
+	mu      sync.Mutex
+	clients map[string]*gemini.Client
+}
+
+func (b *batchClients) get(model string) (*gemini.Client, error) {
+	if model == "" {
+		model = GetModel()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if client, ok := b.clients[model]; ok {
+		return client, nil
+	}
+	client, err := newGeminiClientForModel(b.apiKey, model, 3*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	b.clients[model] = client
+	return client, nil
+}
+
+// loadBatchJobs parses a JSONL or YAML manifest, assigning default IDs and
+// resolving relative paths against the manifest directory.
+func loadBatchJobs(path string) ([]batchJob, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var jobs []batchJob
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		jobs, err = parseYAMLJobs(data)
+	default:
+		jobs, err = parseJSONLJobs(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("manifest %s contains no jobs", path)
+	}
+
+	dir := filepath.Dir(path)
+	seen := map[string]bool{}
+	for i := range jobs {
+		job := &jobs[i]
+		if job.ID == "" {
+			job.ID = "job-" + strconv.Itoa(i+1)
+		}
+		if seen[job.ID] {
+			return nil, fmt.Errorf("duplicate job id %q", job.ID)
+		}
+		seen[job.ID] = true
+		if job.Output == "" {
+			job.Output = job.ID + ".png"
+		}
+		if job.Count == 0 {
+			job.Count = 1
+		}
+		job.Output = resolveManifestPath(dir, job.Output)
+		for j, input := range job.Inputs {
+			if gemini.IsFileInput(input) {
+				job.Inputs[j] = resolveManifestPath(dir, input)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+func parseJSONLJobs(data []byte) ([]batchJob, error) {
+	var jobs []batchJob
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var job batchJob
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&job); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, scanner.Err()
+}
+
+func parseYAMLJobs(data []byte) ([]batchJob, error) {
+	var jobs []batchJob
+	if err := yaml.Unmarshal(data, &jobs); err == nil {
+		return jobs, nil
+	}
+	var wrapped struct {
+		Jobs []batchJob `yaml:"jobs"`
+	}
+	if err := yaml.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("invalid YAML manifest: %w", err)
+	}
+	return wrapped.Jobs, nil
+}
+
+func resolveManifestPath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func (j *batchJob) validate() error {
+	if strings.TrimSpace(j.Prompt) == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	if j.Count < 1 || j.Count > 10 {
+		return fmt.Errorf("count must be between 1 and 10")
+	}
+	if j.AspectRatio != "" && !gemini.IsValidAspectRatio(j.AspectRatio) {
+		return fmt.Errorf("invalid aspect ratio: %s", j.AspectRatio)
+	}
+	if !gemini.IsValidImageSize(strings.TrimSpace(j.ImageSize)) {
+		return fmt.Errorf("invalid image size: %s", j.ImageSize)
+	}
+	if strings.TrimSpace(j.ThinkingLevel) != "" && !slices.Contains([]string{"minimal", "high"}, strings.ToLower(j.ThinkingLevel)) {
+		return fmt.Errorf("invalid thinking level: %s", j.ThinkingLevel)
+	}
+	if _, err := gemini.ParseSafetyMap(j.Safety); err != nil {
+		return err
+	}
+	if err := validateOutputFormat(j.Output); err != nil {
+		return err
+	}
+	if err := validateQuality(j.Quality); err != nil {
+		return err
+	}
+	for _, input := range j.Inputs {
+		if input == gemini.StdinInput {
+			return fmt.Errorf("inputs cannot read from stdin in a batch")
+		}
+		if !gemini.IsFileInput(input) {
+			continue
+		}
+		if _, err := os.Stat(input); err != nil {
+			return fmt.Errorf("input file not found: %s", input)
+		}
+	}
+	return nil
+}
+
+// batchOutputExists reports whether a previous run already produced an image
+// for every candidate of the job, using the same naming as generate. A job
+// with a failed candidate runs again.
+func batchOutputExists(job batchJob) bool {
+	n := job.candidates()
+	if n <= 1 {
+		return fileExists(job.Output)
+	}
+	ext := filepath.Ext(job.Output)
+	base := strings.TrimSuffix(job.Output, ext)
+	for i := 1; i <= n; i++ {
+		// A candidate that returned several images is saved as _N_1, _N_2...
+		if !fileExists(fmt.Sprintf("%s_%d%s", base, i, ext)) && !fileExists(fmt.Sprintf("%s_%d_1%s", base, i, ext)) {
+			return false
+		}
+	}
+	return true
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,474 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Batch API command flags
+	batchStateFile    string
+	batchDisplayName  string
+	batchWait         bool
+	batchPollInterval time.Duration
+)
+
+var batchSubmitCmd = &cobra.Command{
+	Use:   "submit <manifest>",
+	Short: "Submit a manifest to the Gemini Batch API",
+	Long: `Submit every job in a manifest as an asynchronous Gemini batch.
+
+Batch requests cost half as much as interactive ones and do not count against
+per-minute rate limits, but results can take up to 24 hours. Jobs are grouped
+into one batch per model. The batch names and resolved jobs are written to a
+state file (default <manifest>.batch.json) that "batch status" and
+"batch fetch" read. A batch whose requests exceed the API's 20 MB inline
+limit, for example with many reference images, is uploaded as a JSONL file
+through the Files API instead.
+
+Jobs whose output already exists are left out unless --overwrite is set.
+The Batch API is only available on the rest backend.
+
+EXAMPLES:
+  nanobanana batch submit jobs.jsonl
+  nanobanana batch status jobs.batch.json
+  nanobanana batch fetch jobs.batch.json --wait`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBatchSubmit,
+}
+
+var batchStatusCmd = &cobra.Command{
+	Use:   "status <state-file|batches/ID>",
+	Short: "Show the state of submitted batches",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBatchStatus,
+}
+
+var batchFetchCmd = &cobra.Command{
+	Use:   "fetch <state-file>",
+	Short: "Download finished batch results and save images",
+	Long: `Download the results of finished batches and save each job's images to
+its output path, like "nanobanana batch" does for synchronous runs.
+
+Each job appends a line to the results file (default <manifest>.results.jsonl).
+Batches that are still running are reported as pending; rerun fetch later or
+use --wait to poll until they finish. Fetched batches are marked in the state
+file and skipped on later runs. A batch that ended failed, cancelled or
+expired adds a BATCH_FAILED line for each of its jobs and is marked fetched;
+one that cannot be reached is left for the next run.
+
+EXAMPLES:
+  nanobanana batch fetch jobs.batch.json
+  nanobanana batch fetch jobs.batch.json --wait --poll-interval 5m`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBatchFetch,
+}
+
+func init() {
+	batchSubmitCmd.Flags().StringVar(&batchStateFile, "state", "", "State file (default <manifest>.batch.json)")
+	batchSubmitCmd.Flags().StringVar(&batchDisplayName, "name", "", "Batch display name (default manifest file name)")
+	batchSubmitCmd.Flags().BoolVar(&batchOverwrite, "overwrite", false, "Submit jobs whose output already exists")
+
+	batchFetchCmd.Flags().StringVar(&batchResults, "results", "", "Results file (default <manifest>.results.jsonl)")
+	batchFetchCmd.Flags().BoolVar(&batchWait, "wait", false, "Poll until every batch finishes")
+	batchFetchCmd.Flags().DurationVar(&batchPollInterval, "poll-interval", time.Minute, "Time between polls with --wait")
+
+	for _, cmd := range []*cobra.Command{batchSubmitCmd, batchStatusCmd, batchFetchCmd} {
+		addGeminiFlags(cmd)
+		batchCmd.AddCommand(cmd)
+	}
+}
+
+// batchState is the state file written by batch submit.
+type batchState struct {
+	Manifest    string           `json:"manifest"`
+	SubmittedAt time.Time        `json:"submitted_at"`
+	Batches     []submittedBatch `json:"batches"`
+	Jobs        []batchJob       `json:"jobs"`
+}
+
+// submittedBatch is one Batch API job holding the manifest jobs for a model.
+type submittedBatch struct {
+	Name    string   `json:"name"`
+	Model   string   `json:"model"`
+	Jobs    []string `json:"jobs"`
+	Fetched bool     `json:"fetched,omitempty"`
+}
+
+func runBatchSubmit(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	startTime := time.Now()
+	manifest := args[0]
+
+	jobs, err := loadBatchJobs(manifest)
+	if err != nil {
+		f.Error("batch", "INVALID_MANIFEST", err.Error(), "Use JSONL (one job per line) or a YAML list of jobs")
+		return err
+	}
+
+	apiKey := GetAPIKey()
+	if apiKey == "" {
+		f.Error("batch", "MISSING_API_KEY", "No API key provided", "Set GEMINI_API_KEY environment variable or use --api-key flag")
+		return fmt.Errorf("missing API key")
+	}
+
+	clients := &batchClients{apiKey: apiKey, clients: map[string]*gemini.Client{}}
+	state := &batchState{Manifest: manifest, SubmittedAt: time.Now().UTC()}
+	var models []string
+	requests := map[string][]gemini.BatchRequest{}
+	jobIDs := map[string][]string{}
+	skipped := 0
+
+	for _, job := range jobs {
+		if !batchOverwrite && batchOutputExists(job) {
+			skipped++
+			f.Progress("Skipping %s: %s exists", job.ID, job.Output)
+			continue
+		}
+		if err := job.validate(); err != nil {
+			f.Error("batch", "INVALID_JOB", fmt.Sprintf("%s: %v", job.ID, err), "")
+			return err
+		}
+		client, err := clients.get(job.Model)
+		if err != nil {
+			f.Error("batch", "CLIENT_ERROR", err.Error(), clientErrorHint())
+			return err
+		}
+		model := client.Model().Spec.ID
+		if _, ok := requests[model]; !ok {
+			models = append(models, model)
+		}
+		requests[model] = append(requests[model], gemini.BatchRequest{
+			Key:     job.ID,
+			Prompt:  job.Prompt,
+			Options: batchJobOptions(job),
+		})
+		jobIDs[model] = append(jobIDs[model], job.ID)
+		state.Jobs = append(state.Jobs, job)
+	}
+
+	if len(state.Jobs) == 0 {
+		f.Info("Nothing to submit: all %d jobs already have output", skipped)
+		f.Success("batch", map[string]any{"manifest": manifest, "submitted": 0, "skipped": skipped}, nil)
+		return nil
+	}
+
+	displayName := batchDisplayName
+	if displayName == "" {
+		displayName = filepath.Base(manifest)
+	}
+
+	statePath := batchStateFile
+	if statePath == "" {
+		statePath = strings.TrimSuffix(manifest, filepath.Ext(manifest)) + ".batch.json"
+	}
+
+	// The state is saved after every batch, so batches already running are
+	// not lost when a later one fails to submit.
+	var submitted []*gemini.BatchJob
+	for _, model := range models {
+		client, _ := clients.get(model)
+		f.Progress("Submitting %d jobs to %s...", len(requests[model]), model)
+		job, err := client.SubmitBatch(context.Background(), displayName, requests[model])
+		if err != nil {
+			info := generateErrorInfo(err)
+			if len(submitted) > 0 {
+				info.Hint = strings.TrimPrefix(info.Hint+"; ", "; ") +
+					fmt.Sprintf("Batches were submitted: %s (state: %s)", batchNames(state), statePath)
+			}
+			f.ErrorWithDetails("batch", info.Code, info.Message, info.Hint, info.Details)
+			return err
+		}
+		submitted = append(submitted, job)
+		state.Batches = append(state.Batches, submittedBatch{Name: job.Name, Model: model, Jobs: jobIDs[model]})
+		if err := writeBatchState(statePath, state); err != nil {
+			f.Error("batch", "STATE_WRITE_FAILED", err.Error(), "Batches were submitted: "+batchNames(state))
+			return err
+		}
+	}
+
+	f.Info("Submitted %d jobs in %d batches. State: %s", len(state.Jobs), len(submitted), statePath)
+	f.Success("batch", map[string]any{
+		"manifest":   manifest,
+		"state_file": statePath,
+		"submitted":  len(state.Jobs),
+		"skipped":    skipped,
+		"batches":    submitted,
+	}, &output.Timing{TotalMs: time.Since(startTime).Milliseconds()})
+	return nil
+}
+
+func runBatchStatus(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	startTime := time.Now()
+
+	var names []string
+	if strings.HasPrefix(args[0], "batches/") {
+		names = []string{args[0]}
+	} else {
+		state, err := readBatchState(args[0])
+		if err != nil {
+			f.Error("batch", "INVALID_STATE", err.Error(), "Pass the state file written by batch submit or a batches/ID name")
+			return err
+		}
+		for _, b := range state.Batches {
+			names = append(names, b.Name)
+		}
+	}
+
+	apiKey := GetAPIKey()
+	if apiKey == "" {
+		f.Error("batch", "MISSING_API_KEY", "No API key provided", "Set GEMINI_API_KEY environment variable or use --api-key flag")
+		return fmt.Errorf("missing API key")
+	}
+	client, err := newGeminiClient(apiKey, 60*time.Second)
+	if err != nil {
+		f.Error("batch", "CLIENT_ERROR", err.Error(), clientErrorHint())
+		return err
+	}
+
+	var jobs []*gemini.BatchJob
+	allDone := true
+	for _, name := range names {
+		job, err := client.GetBatch(context.Background(), name)
+		if err != nil {
+			reportGenerateError("batch", err)
+			return err
+		}
+		allDone = allDone && job.Done
+		f.Info("%s: %s (%d/%d succeeded, %d failed)", job.Name, job.State,
+			job.Stats.SuccessfulRequestCount, job.Stats.RequestCount, job.Stats.FailedRequestCount)
+		jobs = append(jobs, job)
+	}
+
+	f.Success("batch", map[string]any{"batches": jobs, "done": allDone},
+		&output.Timing{TotalMs: time.Since(startTime).Milliseconds()})
+	return nil
+}
+
+func runBatchFetch(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	startTime := time.Now()
+	statePath := args[0]
+
+	state, err := readBatchState(statePath)
+	if err != nil {
+		f.Error("batch", "INVALID_STATE", err.Error(), "Pass the state file written by batch submit")
+		return err
+	}
+
+	apiKey := GetAPIKey()
+	if apiKey == "" {
+		f.Error("batch", "MISSING_API_KEY", "No API key provided", "Set GEMINI_API_KEY environment variable or use --api-key flag")
+		return fmt.Errorf("missing API key")
+	}
+	if batchPollInterval <= 0 {
+		f.Error("batch", "INVALID_POLL_INTERVAL", "Poll interval must be positive", "")
+		return fmt.Errorf("invalid poll interval")
+	}
+
+	resultsPath := batchResults
+	if resultsPath == "" {
+		resultsPath = strings.TrimSuffix(state.Manifest, filepath.Ext(state.Manifest)) + ".results.jsonl"
+	}
+	resultsFile, err := os.OpenFile(resultsPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		f.Error("batch", "RESULTS_OPEN_FAILED", err.Error(), "")
+		return err
+	}
+	defer resultsFile.Close()
+
+	jobs := map[string]batchJob{}
+	for _, job := range state.Jobs {
+		jobs[job.ID] = job
+	}
+
+	clients := &batchClients{apiKey: apiKey, clients: map[string]*gemini.Client{}}
+	ctx := context.Background()
+	var succeeded, failed, pending, unfetched int
+	writeResult := func(res batchResult) error {
+		line, err := json.Marshal(res)
+		if err != nil {
+			return fmt.Errorf("failed to encode result for %s: %w", res.Job, err)
+		}
+		if _, err := resultsFile.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write results file: %w", err)
+		}
+		if res.Success {
+			succeeded++
+			f.Progress("%s: done", res.Job)
+		} else {
+			failed++
+			f.Progress("%s: failed [%s] %s", res.Job, res.Error.Code, res.Error.Message)
+		}
+		return nil
+	}
+
+	// Errors reaching a batch leave it unfetched for the next run; the
+	// others are still fetched and the state file is always written.
+	for i := range state.Batches {
+		submitted := &state.Batches[i]
+		if submitted.Fetched {
+			continue
+		}
+		client, err := clients.get(submitted.Model)
+		if err != nil {
+			unfetched += len(submitted.Jobs)
+			f.Progress("%s: %v", submitted.Name, err)
+			continue
+		}
+
+		var remote *gemini.BatchJob
+		if batchWait {
+			remote, err = client.WaitBatch(ctx, submitted.Name, batchPollInterval, func(job *gemini.BatchJob) {
+				f.Progress("%s: %s (%d/%d done)", job.Name, job.State,
+					job.Stats.SuccessfulRequestCount+job.Stats.FailedRequestCount, job.Stats.RequestCount)
+			})
+		} else {
+			remote, err = client.GetBatch(ctx, submitted.Name)
+		}
+		if err != nil {
+			unfetched += len(submitted.Jobs)
+			f.Progress("%s: %v", submitted.Name, err)
+			continue
+		}
+		if !remote.Done {
+			pending += len(submitted.Jobs)
+			f.Progress("%s: %s, %d jobs pending", remote.Name, remote.State, len(submitted.Jobs))
+			continue
+		}
+
+		if remote.State != gemini.BatchSucceeded {
+			// A failed, cancelled or expired batch has no results; its jobs
+			// fail once and the batch is not polled again.
+			for _, id := range submitted.Jobs {
+				err = writeResult(batchResult{Job: id, Response: output.Response{
+					Command: "generate",
+					Error: &output.ErrorInfo{
+						Code:    "BATCH_FAILED",
+						Message: fmt.Sprintf("batch %s ended with state %s: %s", remote.Name, remote.State, remote.Error),
+						Hint:    "Resubmit the manifest; jobs with output are skipped",
+					},
+				}})
+				if err != nil {
+					break
+				}
+			}
+		} else {
+			var results []gemini.BatchResult
+			if results, err = client.BatchResults(ctx, remote); err == nil {
+				for _, r := range results {
+					if job, ok := jobs[r.Key]; ok {
+						if err = writeResult(saveBatchResult(client, job, r)); err != nil {
+							break
+						}
+					}
+				}
+			}
+		}
+		// A batch whose results were not all recorded is fetched again.
+		if err != nil {
+			unfetched += len(submitted.Jobs)
+			f.Progress("%s: %v", submitted.Name, err)
+			continue
+		}
+		submitted.Fetched = true
+	}
+
+	if err := writeBatchState(statePath, state); err != nil {
+		f.Error("batch", "STATE_WRITE_FAILED", err.Error(), "")
+		return err
+	}
+
+	timing := &output.Timing{TotalMs: time.Since(startTime).Milliseconds()}
+	summary := map[string]any{
+		"state_file": statePath,
+		"results":    resultsPath,
+		"total":      len(state.Jobs),
+		"succeeded":  succeeded,
+		"failed":     failed,
+		"pending":    pending,
+		"unfetched":  unfetched,
+	}
+	if failed > 0 || unfetched > 0 {
+		details := map[string]string{}
+		for k, v := range summary {
+			details[k] = fmt.Sprint(v)
+		}
+		message := fmt.Sprintf("%d jobs failed", failed)
+		hint := "See the results file; resubmit the manifest to retry jobs without output"
+		if unfetched > 0 {
+			message = fmt.Sprintf("%d jobs failed, %d could not be fetched", failed, unfetched)
+			hint = "Rerun batch fetch to retry the batches that could not be reached; " + hint
+		}
+		f.ErrorWithDetails("batch", "BATCH_INCOMPLETE", message, hint, details)
+		return fmt.Errorf("%d jobs failed, %d unfetched", failed, unfetched)
+	}
+
+	f.Info("%d saved, %d pending. Results: %s", succeeded, pending, resultsPath)
+	f.Success("batch", summary, timing)
+	return nil
+}
+
+// saveBatchResult saves one job's images and returns its results line.
+func saveBatchResult(client *gemini.Client, job batchJob, r gemini.BatchResult) batchResult {
+	res := batchResult{Job: job.ID, Response: output.Response{Command: "generate"}}
+	if r.Err != nil {
+		res.Error = generateErrorInfo(r.Err)
+		return res
+	}
+
+	images, err := saveGeneratedImages(client, r.Result, job.Output, job.candidates(), job.Quality, nil)
+	if err != nil {
+		res.Error = &output.ErrorInfo{Code: "SAVE_FAILED", Message: err.Error()}
+		return res
+	}
+
+	recordUsage("batch", r.Result)
+	res.Success = true
+	res.Data = generateResultData(job.Prompt, client.Model().Spec, batchJobOptions(job), r.Result, images)
+	return res
+}
+
+func readBatchState(path string) (*batchState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	var state batchState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if len(state.Batches) == 0 {
+		return nil, fmt.Errorf("state file %s lists no batches", path)
+	}
+	return &state, nil
+}
+
+func writeBatchState(path string, state *batchState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+func batchNames(state *batchState) string {
+	names := make([]string, 0, len(state.Batches))
+	for _, b := range state.Batches {
+		names = append(names, b.Name)
+	}
+	return strings.Join(names, ", ")
+}
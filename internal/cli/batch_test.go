@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini/geminitest"
+)
+
+func TestBatchRunsJobsAndResumes(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "jobs.jsonl")
+	os.WriteFile(manifest, []byte(strings.Join([]string{
+		`{"id": "hero", "prompt": "a lighthouse", "output": "out/hero.png", "aspect_ratio": "16:9"}`,
+		`# comments and blank lines are ignored`,
+		``,
+		`{"id": "pair", "prompt": "a banana", "output": "out/pair.png", "count": 2}`,
+		`{"id": "bad", "prompt": "oops", "aspect_ratio": "7:3"}`,
+	}, "\n")), 0644)
+
+	args := []string{"batch", manifest, "--concurrency", "2", "--base-url", srv.URL, "--api-key", "k"}
+	resp, err := runCLI(t, args...)
+	if err == nil {
+		t.Fatalf("expected the invalid job to fail the batch: %v", resp)
+	}
+	for _, name := range []string{"hero.png", "pair_1.png", "pair_2.png"} {
+		if _, err := os.Stat(filepath.Join(dir, "out", name)); err != nil {
+			t.Fatalf("missing output %s: %v", name, err)
+		}
+	}
+
+	results := readBatchResults(t, filepath.Join(dir, "jobs.results.jsonl"))
+	if len(results) != 3 {
+		t.Fatalf("results = %d lines, want 3", len(results))
+	}
+	if r := results["bad"]; r.Success || r.Error == nil || r.Error.Code != "INVALID_JOB" {
+		t.Fatalf("bad job result = %+v", r)
+	}
+	if r := results["hero"]; !r.Success || r.Command != "generate" {
+		t.Fatalf("hero job result = %+v", r)
+	}
+	if got := len(srv.Requests()); got != 3 {
+		t.Fatalf("requests = %d, want 3", got)
+	}
+
+	// A rerun only retries the job without output.
+	runCLI(t, args...)
+	if got := len(srv.Requests()); got != 3 {
+		t.Fatalf("requests after resume = %d, want 3", got)
+	}
+
+	// A job missing one candidate's output runs again.
+	os.Remove(filepath.Join(dir, "out", "pair_2.png"))
+	runCLI(t, args...)
+	if got := len(srv.Requests()); got != 5 {
+		t.Fatalf("requests after losing a candidate = %d, want 5", got)
+	}
+}
+
+func TestLoadBatchJobsYAML(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "jobs.yaml")
+	os.WriteFile(manifest, []byte("jobs:\n  - id: a\n    prompt: first\n    inputs: [ref.png]\n  - prompt: second\n    output: /tmp/abs.png\n"), 0644)
+
+	jobs, err := loadBatchJobs(manifest)
+	if err != nil {
+		t.Fatalf("loadBatchJobs: %v", err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("jobs = %d", len(jobs))
+	}
+	if jobs[0].Output != filepath.Join(dir, "a.png") || jobs[0].Inputs[0] != filepath.Join(dir, "ref.png") {
+		t.Fatalf("paths not resolved against manifest: %+v", jobs[0])
+	}
+	if jobs[1].ID != "job-2" || jobs[1].Output != "/tmp/abs.png" || jobs[1].Count != 1 {
+		t.Fatalf("defaults not applied: %+v", jobs[1])
+	}
+}
+
+func readBatchResults(t *testing.T, path string) map[string]batchResult {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	out := map[string]batchResult{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r batchResult
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid results line: %v", err)
+		}
+		out[r.Job] = r
+	}
+	return out
+}
+
+func TestBatchSubmitStatusFetch(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "jobs.jsonl")
+	os.WriteFile(manifest, []byte(strings.Join([]string{
+		`{"id": "hero", "prompt": "a lighthouse", "output": "out/hero.png", "aspect_ratio": "16:9"}`,
+		`{"id": "pair", "prompt": "a banana", "output": "out/pair.png", "count": 2}`,
+	}, "\n")), 0644)
+	flags := []string{"--base-url", srv.URL, "--api-key", "k"}
+
+	resp, err := runCLI(t, append([]string{"batch", "submit", manifest}, flags...)...)
+	if err != nil {
+		t.Fatalf("submit: %v (%v)", err, resp)
+	}
+	statePath := filepath.Join(dir, "jobs.batch.json")
+	state, err := readBatchState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Batches) != 1 || len(state.Jobs) != 2 || len(srv.Requests()) != 3 {
+		t.Fatalf("state = %+v, requests = %d", state, len(srv.Requests()))
+	}
+
+	resp, err = runCLI(t, append([]string{"batch", "status", statePath}, flags...)...)
+	if err != nil {
+		t.Fatalf("status: %v (%v)", err, resp)
+	}
+	if data, _ := resp["data"].(map[string]any); data["done"] != true {
+		t.Fatalf("status = %v", resp)
+	}
+	resp, err = runCLI(t, append([]string{"batch", "status", "batches/typo"}, flags...)...)
+	if err == nil || resp["error"].(map[string]any)["code"] != gemini.ErrNotFound {
+		t.Fatalf("status of an unknown batch = %v (%v), want %s", err, resp, gemini.ErrNotFound)
+	}
+
+	resp, err = runCLI(t, append([]string{"batch", "fetch", statePath}, flags...)...)
+	if err != nil {
+		t.Fatalf("fetch: %v (%v)", err, resp)
+	}
+	for _, name := range []string{"hero.png", "pair_1.png", "pair_2.png"} {
+		if _, err := os.Stat(filepath.Join(dir, "out", name)); err != nil {
+			t.Fatalf("missing output %s: %v", name, err)
+		}
+	}
+	results := readBatchResults(t, filepath.Join(dir, "jobs.results.jsonl"))
+	if r := results["pair"]; !r.Success || len(results) != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+
+	// Fetched batches are not downloaded again.
+	if resp, err := runCLI(t, append([]string{"batch", "fetch", statePath}, flags...)...); err != nil {
+		t.Fatalf("second fetch: %v (%v)", err, resp)
+	}
+	if got := len(readBatchResults(t, filepath.Join(dir, "jobs.results.jsonl"))); got != 2 {
+		t.Fatalf("results after second fetch = %d", got)
+	}
+}
+
+func TestBatchSubmitSavesStateBeforeALaterFailure(t *testing.T) {
+	fake := geminitest.NewFake()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/models/gemini-3-pro-image-preview:batchGenerateContent") {
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"error":{"code":429,"message":"quota","status":"RESOURCE_EXHAUSTED"}}`)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "jobs.jsonl")
+	os.WriteFile(manifest, []byte(`{"id": "hero", "prompt": "a lighthouse", "output": "hero.png"}
+{"id": "poster", "prompt": "a poster", "output": "poster.png", "model": "pro"}`), 0644)
+	resp, err := runCLI(t, "batch", "submit", manifest, "--base-url", srv.URL, "--api-key", "k", "--retries", "0")
+	if err == nil {
+		t.Fatalf("submit succeeded: %v", resp)
+	}
+	if hint, _ := resp["error"].(map[string]any)["hint"].(string); !strings.Contains(hint, "batches/fake-1") {
+		t.Fatalf("hint = %q, want the submitted batch", hint)
+	}
+	state, err := readBatchState(filepath.Join(dir, "jobs.batch.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Batches) != 1 || state.Batches[0].Name != "batches/fake-1" || state.Batches[0].Jobs[0] != "hero" {
+		t.Fatalf("batches = %+v", state.Batches)
+	}
+}
+
+func TestBatchFetchContinuesPastBadBatches(t *testing.T) {
+	fake := geminitest.NewFake()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/batches/failed") {
+			io.WriteString(w, `{"name":"batches/failed","done":true,"metadata":{"state":"BATCH_STATE_CANCELLED"},"error":{"code":1,"message":"cancelled"}}`)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "jobs.jsonl")
+	os.WriteFile(manifest, []byte(`{"id": "hero", "prompt": "a lighthouse", "output": "out/hero.png"}`), 0644)
+	flags := []string{"--base-url", srv.URL, "--api-key", "k"}
+	if resp, err := runCLI(t, append([]string{"batch", "submit", manifest}, flags...)...); err != nil {
+		t.Fatalf("submit: %v (%v)", err, resp)
+	}
+	statePath := filepath.Join(dir, "jobs.batch.json")
+	state, err := readBatchState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := state.Batches[0].Model
+	state.Batches = append([]submittedBatch{
+		{Name: "batches/missing", Model: model, Jobs: []string{"lost"}},
+		{Name: "batches/failed", Model: model, Jobs: []string{"cancelled"}},
+	}, state.Batches...)
+	if err := writeBatchState(statePath, state); err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		resp, err := runCLI(t, append([]string{"batch", "fetch", statePath}, flags...)...)
+		if err == nil || resp["error"].(map[string]any)["code"] != "BATCH_INCOMPLETE" {
+			t.Fatalf("run %d: fetch = %v (%v), want BATCH_INCOMPLETE", run, err, resp)
+		}
+	}
+	// Each finished batch is written once across both runs.
+	data, _ := os.ReadFile(filepath.Join(dir, "jobs.results.jsonl"))
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Fatalf("results lines = %d, want 2", lines)
+	}
+	results := readBatchResults(t, filepath.Join(dir, "jobs.results.jsonl"))
+	if !results["hero"].Success || results["cancelled"].Error == nil || results["cancelled"].Error.Code != "BATCH_FAILED" || len(results) != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+	state, _ = readBatchState(statePath)
+	if state.Batches[0].Fetched || !state.Batches[1].Fetched || !state.Batches[2].Fetched {
+		t.Fatalf("batches = %+v", state.Batches)
+	}
+}
+
+func TestBatchFetchKeepsBatchesWhoseResultsWereNotWritten(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("needs /dev/full")
+	}
+	srv := geminitest.NewServer()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "jobs.jsonl")
+	os.WriteFile(manifest, []byte(`{"id": "hero", "prompt": "a lighthouse", "output": "out/hero.png"}`), 0644)
+	flags := []string{"--base-url", srv.URL, "--api-key", "k"}
+	if resp, err := runCLI(t, append([]string{"batch", "submit", manifest}, flags...)...); err != nil {
+		t.Fatalf("submit: %v (%v)", err, resp)
+	}
+	statePath := filepath.Join(dir, "jobs.batch.json")
+
+	resp, err := runCLI(t, append([]string{"batch", "fetch", statePath, "--results", "/dev/full"}, flags...)...)
+	if err == nil || resp["error"].(map[string]any)["code"] != "BATCH_INCOMPLETE" {
+		t.Fatalf("fetch into a full disk = %v (%v), want BATCH_INCOMPLETE", err, resp)
+	}
+	if state, _ := readBatchState(statePath); state.Batches[0].Fetched {
+		t.Fatalf("batch marked fetched without its results: %+v", state.Batches)
+	}
+
+	if resp, err := runCLI(t, append([]string{"batch", "fetch", statePath}, flags...)...); err != nil {
+		t.Fatalf("refetch: %v (%v)", err, resp)
+	}
+	if results := readBatchResults(t, filepath.Join(dir, "jobs.results.jsonl")); !results["hero"].Success {
+		t.Fatalf("results = %+v", results)
+	}
+}
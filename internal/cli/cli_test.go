@@ -0,0 +1,1005 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/png"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini/geminitest"
+	"github.com/lyalindotcom/nano-banana-cli/internal/session"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// runCLI executes the root command with args in JSON mode and returns the
+// decoded response. Flags are reset first so tests do not leak into each other.
+func runCLI(t *testing.T, args ...string) (map[string]any, error) {
+	t.Helper()
+	data, runErr := runCLIOutput(t, args...)
+	var resp map[string]any
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, data)
+	}
+	return resp, runErr
+}
+
+// configDirs gives each test one config dir, so state such as presets carries
+// over between its runCLI calls.
+var configDirs = map[*testing.T]string{}
+
+// runCLIOutput is runCLI without decoding, for commands that stream NDJSON.
+func runCLIOutput(t *testing.T, args ...string) ([]byte, error) {
+	t.Helper()
+	dir, ok := configDirs[t]
+	if !ok {
+		dir = t.TempDir()
+		configDirs[t] = dir
+	}
+	t.Setenv("NANOBANANA_CONFIG_DIR", dir)
+	for _, name := range []string{"GEMINI_API_KEY", "NANOBANANA_API_KEY", "GOOGLE_API_KEY", "NANOBANANA_BASE_URL", "GEMINI_BASE_URL", "NANOBANANA_BACKEND"} {
+		t.Setenv(name, "")
+	}
+	resetFlags(rootCmd)
+
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = stdout
+	defer func() { os.Stdout = saved }()
+
+	rootCmd.SetArgs(append(args, "--json"))
+	runErr := rootCmd.Execute()
+
+	data, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, runErr
+}
+
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			var values []string
+			if def := strings.Trim(f.DefValue, "[]"); def != "" {
+				values = strings.Split(def, ",")
+			}
+			sv.Replace(values)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
+
+func decodedSize(t *testing.T, path string) (int, int) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestGenerateWithFakeServer(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	out := filepath.Join(t.TempDir(), "fox.png")
+
+	resp, err := runCLI(t, "generate", "a red fox", "-o", out, "--aspect-ratio", "16:9", "--base-url", srv.URL, "--api-key", "fake-key")
+	if err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if resp["success"] != true {
+		t.Fatalf("response = %v", resp)
+	}
+
+	wantW, wantH := geminitest.ImageDimensions("16:9", "1K")
+	if w, h := decodedSize(t, out); w != wantW || h != wantH {
+		t.Fatalf("image = %dx%d, want %dx%d", w, h, wantW, wantH)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 || reqs[0].APIKey != "fake-key" || reqs[0].Prompt != "a red fox" || reqs[0].AspectRatio != "16:9" {
+		t.Fatalf("requests = %+v", reqs)
+	}
+}
+
+func TestGenerateInputSources(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	pngData := buf.Bytes()
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngData)
+	}))
+	defer images.Close()
+	out := filepath.Join(t.TempDir(), "mix.png")
+
+	rootCmd.SetIn(bytes.NewReader(pngData))
+	defer rootCmd.SetIn(nil)
+	dataURI := "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngData)
+	resp, err := runCLI(t, "generate", "combine these", "-o", out, "-i", "-", "-i", images.URL+"/ref", "-i", dataURI, "--base-url", srv.URL, "--api-key", "k")
+	if err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 1 || reqs[0].InputImages != 3 || strings.Count(string(reqs[0].Body), `"mime_type":"image/png"`) != 3 {
+		t.Fatalf("requests = %+v", reqs)
+	}
+	inputs := resp["data"].(map[string]any)["input_images"].([]any)
+	if label := inputs[2].(string); !strings.HasPrefix(label, "data:image/png;base64,... (") {
+		t.Errorf("input_images[2] = %s, want a shortened data URI", label)
+	}
+
+	resp, err = runCLI(t, "generate", "-o", out, "-i", "-", "--base-url", srv.URL, "--api-key", "k")
+	if err == nil || resp["error"].(map[string]any)["code"] != "STDIN_CONFLICT" {
+		t.Fatalf("stdin prompt and image: err = %v, resp = %v", err, resp)
+	}
+
+	resp, err = runCLI(t, "generate", "a cat", "-o", out, "-i", "data:text/plain,hello", "--base-url", srv.URL, "--api-key", "k")
+	if err == nil || resp["error"].(map[string]any)["code"] != gemini.ErrInvalidInput {
+		t.Fatalf("non-image data URI: err = %v, resp = %v", err, resp)
+	}
+}
+
+func TestGenerateFitInputs(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	configDirs[t] = t.TempDir()
+	os.WriteFile(filepath.Join(configDirs[t], "config.yaml"), []byte(`models:
+  gemini-3.1-flash-image-preview:
+    max_request_bytes: 200000
+`), 0644)
+
+	img := image.NewRGBA(image.Rect(0, 0, 300, 300))
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.IntN(256))
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	ref := filepath.Join(dir, "photo.png")
+	os.WriteFile(ref, buf.Bytes(), 0644)
+	out := filepath.Join(dir, "out.png")
+
+	resp, err := runCLI(t, "generate", "restyle", "-i", ref, "-o", out, "--base-url", srv.URL, "--api-key", "k")
+	if err == nil || resp["error"].(map[string]any)["code"] != gemini.ErrRequestTooLarge {
+		t.Fatalf("oversized request: err = %v, resp = %v", err, resp)
+	}
+	if len(srv.Requests()) != 0 {
+		t.Fatal("an oversized request should not be sent")
+	}
+
+	resp, err = runCLI(t, "generate", "restyle", "-i", ref, "-o", out, "--fit-inputs", "--base-url", srv.URL, "--api-key", "k")
+	if err != nil {
+		t.Fatalf("generate --fit-inputs: %v (%v)", err, resp)
+	}
+	adjustments, _ := resp["data"].(map[string]any)["input_adjustments"].([]any)
+	if len(adjustments) != 1 || adjustments[0].(map[string]any)["input"] != ref {
+		t.Fatalf("input_adjustments = %v", adjustments)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 1 || len(reqs[0].Body) > 200000 {
+		t.Fatalf("requests = %d, body = %d bytes", len(reqs), len(reqs[0].Body))
+	}
+}
+
+func TestOutputFormatConversion(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+
+	for _, tc := range []struct {
+		args   []string
+		output string
+		format string
+	}{
+		{args: []string{"generate", "a lighthouse", "--quality", "70"}, output: "hero.jpg", format: "jpeg"},
+		{args: []string{"pattern", "hexagons"}, output: "tile.gif", format: "gif"},
+		{args: []string{"generate", "a lighthouse"}, output: "hero.webp", format: "webp"},
+		{args: []string{"generate", "a lighthouse"}, output: "raw", format: "png"},
+	} {
+		out := filepath.Join(dir, tc.output)
+		args := append(tc.args, "-o", out, "--base-url", srv.URL, "--api-key", "k")
+		resp, err := runCLI(t, args...)
+		if err != nil {
+			t.Fatalf("%s: %v (%v)", tc.output, err, resp)
+		}
+		data := resp["data"].(map[string]any)
+		img, ok := data["image"].(map[string]any)
+		if !ok {
+			img = data["images"].([]any)[0].(map[string]any)
+		}
+		if img["format"] != tc.format {
+			t.Errorf("%s: reported format %v, want %s", tc.output, img["format"], tc.format)
+		}
+		raw, _ := os.ReadFile(out)
+		if _, format, err := image.DecodeConfig(bytes.NewReader(raw)); err != nil || format != tc.format {
+			t.Errorf("%s: file is %q (%v), want %s", tc.output, format, err, tc.format)
+		}
+	}
+
+	n := len(srv.Requests())
+	resp, err := runCLI(t, "generate", "a lighthouse", "-o", filepath.Join(dir, "hero.heic"), "--base-url", srv.URL, "--api-key", "k")
+	if err == nil || resp["error"].(map[string]any)["code"] != "UNSUPPORTED_FORMAT" {
+		t.Fatalf("heic output: err = %v, resp = %v", err, resp)
+	}
+	resp, err = runCLI(t, "icon", "a gear", "-o", filepath.Join(dir, "gear_{size}.jpg"), "--base-url", srv.URL, "--api-key", "k")
+	if err == nil || resp["error"].(map[string]any)["code"] != "UNSUPPORTED_FORMAT" {
+		t.Fatalf("transparent jpeg icon: err = %v, resp = %v", err, resp)
+	}
+	resp, err = runCLI(t, "generate", "a lighthouse", "--quality", "101", "-o", filepath.Join(dir, "hero.jpg"), "--base-url", srv.URL, "--api-key", "k")
+	if err == nil || resp["error"].(map[string]any)["code"] != "INVALID_QUALITY" || !strings.Contains(resp["error"].(map[string]any)["message"].(string), "0 for the default") {
+		t.Fatalf("quality 101: err = %v, resp = %v", err, resp)
+	}
+	if len(srv.Requests()) != n {
+		t.Fatal("unsupported output formats should be rejected before any request")
+	}
+}
+
+func TestProvenanceMetadata(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	ref := filepath.Join(dir, "ref.png")
+	os.WriteFile(ref, buf.Bytes(), 0644)
+	sum := sha256.Sum256(buf.Bytes())
+
+	out := filepath.Join(dir, "hero.png")
+	if resp, err := runCLI(t, "generate", "a lighthouse", "-i", ref, "--seed", "7", "--aspect-ratio", "16:9", "-o", out, "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	resp, err := runCLI(t, "meta", "show", out)
+	if err != nil {
+		t.Fatalf("meta show: %v (%v)", err, resp)
+	}
+	data := resp["data"].(map[string]any)
+	options := data["options"].(map[string]any)
+	inputs := data["inputs"].([]any)
+	if data["prompt"] != "a lighthouse" || data["command"] != "generate" || data["model"] != gemini.DefaultModelID ||
+		options["aspect_ratio"] != "16:9" || options["sampling"].(map[string]any)["seed"] != 7.0 ||
+		len(inputs) != 1 || inputs[0].(map[string]any)["sha256"] != hex.EncodeToString(sum[:]) {
+		t.Fatalf("meta show = %v", data)
+	}
+
+	jpg := filepath.Join(dir, "tile.jpg")
+	if resp, err := runCLI(t, "pattern", "hexagons", "--type", "texture", "-o", jpg, "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("pattern: %v (%v)", err, resp)
+	}
+	resp, err = runCLI(t, "meta", "show", jpg)
+	if err != nil {
+		t.Fatalf("meta show: %v (%v)", err, resp)
+	}
+	data = resp["data"].(map[string]any)
+	if data["format"] != "jpeg" || data["prompt"] != "hexagons" || data["options"].(map[string]any)["type"] != "texture" {
+		t.Fatalf("meta show = %v", data)
+	}
+
+	bare := filepath.Join(dir, "bare.png")
+	if resp, err := runCLI(t, "generate", "a lighthouse", "--no-metadata", "-o", bare, "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate --no-metadata: %v (%v)", err, resp)
+	}
+	resp, err = runCLI(t, "meta", "show", bare)
+	if err == nil || resp["error"].(map[string]any)["code"] != "NO_METADATA" {
+		t.Fatalf("meta show without metadata: err = %v, resp = %v", err, resp)
+	}
+}
+
+func TestManifestAndRegenerate(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	ref := filepath.Join(dir, "ref.png")
+	os.WriteFile(ref, buf.Bytes(), 0644)
+
+	out := filepath.Join(dir, "hero.png")
+	resp, err := runCLI(t, "generate", "a lighthouse", "-i", ref, "--aspect-ratio", "16:9", "--manifest", "-o", out, "--base-url", srv.URL, "--api-key", "k")
+	if err != nil {
+		t.Fatalf("generate --manifest: %v (%v)", err, resp)
+	}
+	manifest := out + ".nb.json"
+	if resp["data"].(map[string]any)["manifest"] != manifest {
+		t.Fatalf("manifest = %v", resp["data"].(map[string]any)["manifest"])
+	}
+	m, err := loadGenerateManifest(manifest)
+	if err != nil {
+		t.Fatalf("loadGenerateManifest: %v", err)
+	}
+	if m.Request.Seed == nil || m.Request.Model != gemini.DefaultModelID || m.Request.Inputs[0] != ref || len(m.Inputs) != 1 {
+		t.Fatalf("manifest = %+v", m)
+	}
+
+	if resp, err := runCLI(t, "regenerate", manifest, "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("regenerate: %v (%v)", err, resp)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 2 || !bytes.Equal(reqs[0].Body, reqs[1].Body) {
+		t.Fatalf("regenerate should repeat the request exactly:\n%s\n%s", reqs[0].Body, reqs[len(reqs)-1].Body)
+	}
+
+	pro := filepath.Join(dir, "hero-pro.png")
+	if resp, err := runCLI(t, "regenerate", manifest, "-m", "pro", "-o", pro, "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("regenerate -m pro: %v (%v)", err, resp)
+	}
+	reqs = srv.Requests()
+	if last := reqs[len(reqs)-1]; last.Model != gemini.ResolveModelName("pro") || last.AspectRatio != "16:9" {
+		t.Fatalf("regenerate -m pro sent model %s, aspect ratio %s", last.Model, last.AspectRatio)
+	}
+	if _, err := os.Stat(pro + ".nb.json"); err != nil {
+		t.Fatalf("regenerate should write a manifest next to its output: %v", err)
+	}
+
+	os.WriteFile(ref, []byte("changed"), 0644)
+	resp, err = runCLI(t, "regenerate", manifest, "--base-url", srv.URL, "--api-key", "k")
+	if err == nil || resp["error"].(map[string]any)["code"] != "INPUT_CHANGED" {
+		t.Fatalf("changed input: err = %v, resp = %v", err, resp)
+	}
+}
+
+func TestUploadInputsAndFiles(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	configDirs[t] = t.TempDir()
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	ref := filepath.Join(dir, "product.png")
+	os.WriteFile(ref, buf.Bytes(), 0644)
+
+	for _, name := range []string{"a.png", "b.png"} {
+		resp, err := runCLI(t, "generate", "on a beach", "-i", ref, "--upload-inputs", "-o", filepath.Join(dir, name), "--base-url", srv.URL, "--api-key", "test-key")
+		if err != nil {
+			t.Fatalf("generate: %v (%v)", err, resp)
+		}
+	}
+	reqs := srv.Requests()
+	if srv.Uploads() != 1 || len(reqs) != 2 || reqs[1].InputFiles != 1 || reqs[1].InputImages != 0 {
+		t.Fatalf("uploads = %d, requests = %+v", srv.Uploads(), reqs)
+	}
+	if !strings.Contains(string(reqs[1].Body), `"file_uri":"https://generativelanguage.googleapis.com/v1beta/files/fake-1"`) {
+		t.Fatalf("body = %s", reqs[1].Body)
+	}
+
+	resp, err := runCLI(t, "files", "list", "--base-url", srv.URL, "--api-key", "test-key")
+	if err != nil {
+		t.Fatalf("files list: %v (%v)", err, resp)
+	}
+	files := resp["data"].(map[string]any)["files"].([]any)
+	if len(files) != 1 || files[0].(map[string]any)["cached"] != true || files[0].(map[string]any)["display_name"] != "product.png" {
+		t.Fatalf("files = %v", files)
+	}
+
+	if resp, err := runCLI(t, "files", "delete", "--all", "--base-url", srv.URL, "--api-key", "test-key"); err != nil {
+		t.Fatalf("files delete: %v (%v)", err, resp)
+	}
+	if resp, err := runCLI(t, "generate", "on a beach", "-i", ref, "--upload-inputs", "-o", filepath.Join(dir, "c.png"), "--base-url", srv.URL, "--api-key", "test-key"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if srv.Uploads() != 2 {
+		t.Fatalf("uploads after delete = %d, want a fresh upload", srv.Uploads())
+	}
+
+	// Errors that do not name the upload leave the cache alone.
+	srv.Enqueue(geminitest.Reply{Status: http.StatusNotFound, Body: `{"error":{"code":404,"message":"models/gemini-3.1-flash-image-preview is not found","status":"NOT_FOUND"}}`})
+	if _, err := runCLI(t, "generate", "on a beach", "-i", ref, "--upload-inputs", "-o", filepath.Join(dir, "x.png"), "--base-url", srv.URL, "--api-key", "test-key"); err == nil {
+		t.Fatal("expected the model error to be returned")
+	}
+	if srv.Uploads() != 2 {
+		t.Fatalf("uploads after a model error = %d, want the cached upload kept", srv.Uploads())
+	}
+
+	// A cached upload the API no longer knows is uploaded again.
+	srv.Enqueue(geminitest.Reply{Status: http.StatusNotFound, Body: `{"error":{"code":404,"message":"File fake-2 not found","status":"NOT_FOUND"}}`})
+	if resp, err := runCLI(t, "generate", "on a beach", "-i", ref, "--upload-inputs", "-o", filepath.Join(dir, "d.png"), "--base-url", srv.URL, "--api-key", "test-key"); err != nil {
+		t.Fatalf("generate after stale upload: %v (%v)", err, resp)
+	}
+	if srv.Uploads() != 3 {
+		t.Fatalf("uploads after NOT_FOUND = %d, want a fresh upload", srv.Uploads())
+	}
+
+	// Uploads are not shared between API keys.
+	if resp, err := runCLI(t, "generate", "on a beach", "-i", ref, "--upload-inputs", "-o", filepath.Join(dir, "e.png"), "--base-url", srv.URL, "--api-key", "test-key-2"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if srv.Uploads() != 4 {
+		t.Fatalf("uploads with another key = %d, want a fresh upload", srv.Uploads())
+	}
+
+	// Deleting a file that is already gone drops it from the cache and
+	// carries on with the other names.
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/files/fake-3", nil)
+	if _, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = runCLI(t, "files", "delete", "files/typo", "files/fake-3", "--base-url", srv.URL, "--api-key", "test-key")
+	if err != nil {
+		t.Fatalf("files delete of missing files: %v (%v)", err, resp)
+	}
+	if missing := resp["data"].(map[string]any)["not_found"].([]any); len(missing) != 2 {
+		t.Fatalf("not_found = %v", missing)
+	}
+	if resp, err := runCLI(t, "generate", "on a beach", "-i", ref, "--upload-inputs", "-o", filepath.Join(dir, "g.png"), "--base-url", srv.URL, "--api-key", "test-key"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if srv.Uploads() != 5 {
+		t.Fatalf("uploads after deleting a missing file = %d, want a fresh upload", srv.Uploads())
+	}
+
+	// A corrupt cache does not break runs that do not upload.
+	cachePath, _ := fileCachePath()
+	os.WriteFile(cachePath, []byte("{not json"), 0644)
+	if resp, err := runCLI(t, "generate", "plain", "-o", filepath.Join(dir, "f.png"), "--base-url", srv.URL, "--api-key", "test-key"); err != nil {
+		t.Fatalf("generate with a corrupt file cache: %v (%v)", err, resp)
+	}
+}
+
+func TestGenerateCountWithFakeServer(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+
+	if resp, err := runCLI(t, "generate", "a banana", "-o", filepath.Join(dir, "b.png"), "--count", "3", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+	if len(files) != 3 || len(srv.Requests()) != 3 {
+		t.Fatalf("files = %v, requests = %d", files, len(srv.Requests()))
+	}
+}
+
+func TestGenerateReportsAPIError(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	srv.Enqueue(geminitest.Reply{
+		Status: 403,
+		Body:   `{"error":{"code":403,"message":"Model not enabled","status":"PERMISSION_DENIED"}}`,
+	})
+
+	resp, err := runCLI(t, "generate", "x", "-o", filepath.Join(t.TempDir(), "x.png"), "--base-url", srv.URL, "--api-key", "k")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	errInfo, _ := resp["error"].(map[string]any)
+	if errInfo["code"] != "PERMISSION_DENIED" {
+		t.Fatalf("error = %v", resp["error"])
+	}
+}
+
+func TestGenerateSafetySettings(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	configDirs[t] = t.TempDir()
+	os.WriteFile(filepath.Join(configDirs[t], "config.yaml"), []byte("safety:\n  dangerous: medium\n  sexual: low\n"), 0644)
+
+	if resp, err := runCLI(t, "generate", "an anatomy diagram", "-o", filepath.Join(dir, "a.png"), "--safety", "dangerous=none", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	body := string(srv.Requests()[0].Body)
+	for _, want := range []string{
+		`{"category":"HARM_CATEGORY_SEXUALLY_EXPLICIT","threshold":"BLOCK_LOW_AND_ABOVE"}`,
+		`{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","threshold":"BLOCK_NONE"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("request body missing %s: %s", want, body)
+		}
+	}
+	if strings.Contains(body, "BLOCK_MEDIUM_AND_ABOVE") {
+		t.Fatalf("--safety did not override the config default: %s", body)
+	}
+
+	srv.Enqueue(geminitest.Reply{
+		Status: 200,
+		Body:   `{"candidates":[{"finishReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH"}]}]}`,
+	})
+	resp, err := runCLI(t, "generate", "an anatomy diagram", "-o", filepath.Join(dir, "b.png"), "--base-url", srv.URL, "--api-key", "k")
+	if err == nil {
+		t.Fatal("expected a safety block")
+	}
+	errInfo, _ := resp["error"].(map[string]any)
+	details, _ := errInfo["details"].(map[string]any)
+	if errInfo["code"] != gemini.ErrSafetyBlocked || details["blocked_category"] != "HARM_CATEGORY_DANGEROUS_CONTENT" || !strings.Contains(errInfo["hint"].(string), "--safety") {
+		t.Fatalf("error = %v", errInfo)
+	}
+
+	if _, err := runCLI(t, "generate", "x", "-o", filepath.Join(dir, "c.png"), "--safety", "violence=none", "--base-url", srv.URL, "--api-key", "k"); err == nil {
+		t.Fatal("expected an unknown category to fail")
+	}
+}
+
+func TestManifestRecordsEffectiveSafety(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	out := filepath.Join(t.TempDir(), "a.png")
+	configDirs[t] = t.TempDir()
+	configPath := filepath.Join(configDirs[t], "config.yaml")
+	os.WriteFile(configPath, []byte("safety:\n  dangerous: medium\n  sexual: low\n"), 0644)
+
+	if resp, err := runCLI(t, "generate", "an anatomy diagram", "-o", out, "--manifest", "--safety", "dangerous=none", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	m, err := loadGenerateManifest(out + ".nb.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"HARM_CATEGORY_DANGEROUS_CONTENT": "BLOCK_NONE",
+		"HARM_CATEGORY_SEXUALLY_EXPLICIT": "BLOCK_LOW_AND_ABOVE",
+	}
+	if !maps.Equal(m.Request.Safety, want) {
+		t.Fatalf("manifest safety = %v, want %v", m.Request.Safety, want)
+	}
+
+	// A later config must not change what regenerate sends.
+	os.WriteFile(configPath, []byte("safety:\n  sexual: none\n  harassment: high\n"), 0644)
+	if resp, err := runCLI(t, "regenerate", out+".nb.json", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("regenerate: %v (%v)", err, resp)
+	}
+	body := string(srv.Requests()[1].Body)
+	if !strings.Contains(body, `{"category":"HARM_CATEGORY_SEXUALLY_EXPLICIT","threshold":"BLOCK_LOW_AND_ABOVE"}`) ||
+		!strings.Contains(body, `{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","threshold":"BLOCK_NONE"}`) ||
+		strings.Contains(body, "HARM_CATEGORY_HARASSMENT") {
+		t.Fatalf("regenerate sent %s", body)
+	}
+
+	if resp, err := runCLI(t, "regenerate", out+".nb.json", "--safety", "sexual=high", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("regenerate --safety: %v (%v)", err, resp)
+	}
+	body = string(srv.Requests()[2].Body)
+	if !strings.Contains(body, `{"category":"HARM_CATEGORY_SEXUALLY_EXPLICIT","threshold":"BLOCK_ONLY_HIGH"}`) ||
+		!strings.Contains(body, `{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","threshold":"BLOCK_NONE"}`) ||
+		strings.Contains(body, "HARM_CATEGORY_HARASSMENT") {
+		t.Fatalf("regenerate --safety sent %s", body)
+	}
+}
+
+func TestIconAndPatternWithFakeServer(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+
+	if resp, err := runCLI(t, "icon", "a rocket", "-o", dir, "--sizes", "32,64", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("icon: %v (%v)", err, resp)
+	}
+	icons, _ := filepath.Glob(filepath.Join(dir, "*.png"))
+	if len(icons) != 2 {
+		t.Fatalf("icons = %v", icons)
+	}
+
+	tile := filepath.Join(dir, "tile.png")
+	if resp, err := runCLI(t, "pattern", "waves", "-o", tile, "--size", "256x256", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("pattern: %v (%v)", err, resp)
+	}
+	if _, err := os.Stat(tile); err != nil {
+		t.Fatalf("pattern output: %v", err)
+	}
+}
+
+func TestTransformGeneratedImage(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.png")
+	out := filepath.Join(dir, "out.png")
+
+	if resp, err := runCLI(t, "generate", "a square", "-o", in, "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if resp, err := runCLI(t, "transform", in, "--resize", "50%", "-o", out); err != nil {
+		t.Fatalf("transform: %v (%v)", err, resp)
+	}
+	if w, h := decodedSize(t, out); w != 512 || h != 512 {
+		t.Fatalf("transformed = %dx%d, want 512x512", w, h)
+	}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	srv := geminitest.NewServer()
+	fixtures := t.TempDir()
+	dir := t.TempDir()
+	args := []string{"generate", "a lighthouse", "--base-url", srv.URL, "--api-key", "k"}
+
+	if resp, err := runCLI(t, append(args, "-o", filepath.Join(dir, "rec.png"), "--record", fixtures)...); err != nil {
+		t.Fatalf("record: %v (%v)", err, resp)
+	}
+	srv.Close()
+
+	if resp, err := runCLI(t, append(args, "-o", filepath.Join(dir, "rep.png"), "--replay", fixtures)...); err != nil {
+		t.Fatalf("replay: %v (%v)", err, resp)
+	}
+	rec, _ := os.ReadFile(filepath.Join(dir, "rec.png"))
+	rep, _ := os.ReadFile(filepath.Join(dir, "rep.png"))
+	if len(rec) == 0 || string(rec) != string(rep) {
+		t.Fatal("replayed image differs from recorded image")
+	}
+}
+
+func TestGenerateStreamsNDJSON(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	out := filepath.Join(t.TempDir(), "owl.png")
+
+	data, err := runCLIOutput(t, "generate", "an owl", "-o", out, "-m", "pro", "--include-thoughts", "--stream", "--base-url", srv.URL, "--api-key", "k")
+	if err != nil {
+		t.Fatalf("generate: %v\n%s", err, data)
+	}
+
+	var events []string
+	var final map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Fatalf("line is not JSON: %v\n%s", err, line)
+		}
+		if event, ok := obj["event"].(string); ok {
+			events = append(events, event)
+			continue
+		}
+		final = obj
+	}
+	if got := strings.Join(events, ","); got != "thought,text,image,done" {
+		t.Fatalf("events = %s", got)
+	}
+	if final["success"] != true {
+		t.Fatalf("final response = %v", final)
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Fatalf("output: %v", err)
+	}
+	if reqs := srv.Requests(); len(reqs) != 1 || !reqs[0].Stream {
+		t.Fatalf("requests = %+v", srv.Requests())
+	}
+}
+
+func TestSessionScript(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	ref := filepath.Join(dir, "ref.png")
+	os.WriteFile(ref, []byte("not really a png"), 0644)
+
+	script := strings.Join([]string{
+		"a red bicycle",
+		"/undo",
+		"a blue bicycle",
+		"/attach " + ref,
+		"add a bell",
+		"/branch bikes-v2",
+		"/quit",
+	}, "\n")
+	rootCmd.SetIn(strings.NewReader(script))
+	defer rootCmd.SetIn(nil)
+
+	data, err := runCLIOutput(t, "session", "bikes", "--output-dir", dir, "--base-url", srv.URL, "--api-key", "k")
+	if err != nil {
+		t.Fatalf("session: %v\n%s", err, data)
+	}
+	for _, name := range []string{"bikes_001.png", "bikes_002.png", "bikes_003.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("missing %s: %v", name, err)
+		}
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 3 || reqs[2].InputImages != 1 || !strings.Contains(string(reqs[2].Body), "a blue bicycle") || strings.Contains(string(reqs[2].Body), "a red bicycle") {
+		t.Fatalf("third request should continue the blue turn with one attachment: %+v", reqs)
+	}
+
+	s, err := session.Load("bikes")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(s.Turns) != 2 || s.Counter != 3 || len(s.Attachments) != 0 {
+		t.Fatalf("session = %+v", s)
+	}
+	history, err := gemini.LoadHistory(s.HistoryPath())
+	if err != nil || len(history.Contents) != 4 {
+		t.Fatalf("history = %v, %v", history, err)
+	}
+	if branch, err := session.Load("bikes-v2"); err != nil || branch.Parent != "bikes" {
+		t.Fatalf("branch = %+v, %v", branch, err)
+	}
+}
+
+func TestHistoryCommands(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	history := filepath.Join(dir, "h.json")
+
+	for i, prompt := range []string{"a red fox", "make it winter", "add a moon"} {
+		args := []string{"generate", prompt, "-o", filepath.Join(dir, "fox.png"), "--history-out", history, "--base-url", srv.URL, "--api-key", "k"}
+		if i > 0 {
+			args = append(args, "--history-in", history)
+		}
+		if resp, err := runCLI(t, args...); err != nil {
+			t.Fatalf("generate: %v (%v)", err, resp)
+		}
+	}
+
+	resp, err := runCLI(t, "history", "turns", history)
+	if err != nil {
+		t.Fatalf("turns: %v (%v)", err, resp)
+	}
+	turns := resp["data"].(map[string]any)["turns"].([]any)
+	if len(turns) != 3 || turns[1].(map[string]any)["prompt"] != "make it winter" {
+		t.Fatalf("turns = %v", turns)
+	}
+
+	fork := filepath.Join(dir, "fork.json")
+	if resp, err := runCLI(t, "history", "fork", history, "--at", "1", "-o", fork); err != nil {
+		t.Fatalf("fork: %v (%v)", err, resp)
+	}
+	if resp, err := runCLI(t, "history", "rewind", history); err != nil {
+		t.Fatalf("rewind: %v (%v)", err, resp)
+	}
+	for path, want := range map[string]int{fork: 1, history: 2} {
+		h, err := gemini.LoadHistory(path)
+		if err != nil || len(h.Turns()) != want {
+			t.Fatalf("%s: %v, %v", path, h, err)
+		}
+	}
+	if _, err := runCLI(t, "history", "rewind", fork); err == nil {
+		t.Fatal("rewinding the only turn should fail")
+	}
+
+	resp, err = runCLI(t, "history", "extract", history)
+	if err != nil {
+		t.Fatalf("extract: %v (%v)", err, resp)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "h-images", "*"))
+	if len(files) != 2 || filepath.Base(files[0]) != "turn01_model_1.png" {
+		t.Fatalf("files = %v", files)
+	}
+}
+
+func TestHistoryExternalFormat(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	history := filepath.Join(dir, "h.json")
+	blobs := filepath.Join(dir, "h.blobs")
+
+	if resp, err := runCLI(t, "generate", "a red fox", "-o", filepath.Join(dir, "fox.png"), "--history-out", history, "--history-format", "external", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if resp, err := runCLI(t, "generate", "make it winter", "-o", filepath.Join(dir, "fox2.png"), "--history-in", history, "--history-out", history, "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if files, _ := os.ReadDir(blobs); len(files) != 2 {
+		t.Fatalf("blobs = %v", files)
+	}
+	if reqs := srv.Requests(); !strings.Contains(string(reqs[1].Body), `"inline_data"`) || strings.Contains(string(reqs[1].Body), `"file"`) {
+		t.Fatalf("second request should carry the rehydrated image:\n%s", reqs[1].Body)
+	}
+
+	resp, err := runCLI(t, "history", "migrate", history, "--to", "inline")
+	if err != nil {
+		t.Fatalf("migrate: %v (%v)", err, resp)
+	}
+	if data := resp["data"].(map[string]any); data["from"] != "external" || data["images"] != float64(2) {
+		t.Fatalf("migrate = %v", data)
+	}
+	if _, err := os.Stat(blobs); !os.IsNotExist(err) {
+		t.Fatalf("blob directory left behind: %v", err)
+	}
+}
+
+func TestSystemInstructionCarriesOverHistory(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	history := filepath.Join(dir, "h.json")
+	system := filepath.Join(dir, "brand.txt")
+	os.WriteFile(system, []byte("Use only the brand palette.\n"), 0644)
+
+	resp, err := runCLI(t, "generate", "a poster", "-o", filepath.Join(dir, "a.png"), "--system-file", system, "--history-out", history, "--base-url", srv.URL, "--api-key", "k")
+	if err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if resp["data"].(map[string]any)["system_instruction"] != "Use only the brand palette." {
+		t.Fatalf("response = %v", resp)
+	}
+	if resp, err := runCLI(t, "generate", "now in blue", "-o", filepath.Join(dir, "b.png"), "--history-in", history, "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 2 || reqs[0].System != "Use only the brand palette." || reqs[1].System != reqs[0].System {
+		t.Fatalf("requests = %+v", reqs)
+	}
+}
+
+func TestPresetAppliesToGenerateAndIcon(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	ref := filepath.Join(dir, "logo.png")
+	os.WriteFile(ref, []byte("not really a png"), 0644)
+
+	if resp, err := runCLI(t, "preset", "save", "brand-hero", "--system", "Brand voice.", "-m", "pro", "--aspect-ratio", "16:9", "--ref", ref); err != nil {
+		t.Fatalf("preset save: %v (%v)", err, resp)
+	}
+	if _, err := runCLI(t, "preset", "save", "brand-hero"); err == nil {
+		t.Fatal("saving over a preset without --overwrite should fail")
+	}
+
+	if resp, err := runCLI(t, "generate", "a poster", "-o", filepath.Join(dir, "a.png"), "--preset", "brand-hero", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if resp, err := runCLI(t, "generate", "a square", "-o", filepath.Join(dir, "b.png"), "--preset", "brand-hero", "--aspect-ratio", "1:1", "-m", "banana", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	if resp, err := runCLI(t, "icon", "a gear", "-o", filepath.Join(dir, "icons")+"/", "--sizes", "16", "--preset", "brand-hero", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("icon: %v (%v)", err, resp)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 3 {
+		t.Fatalf("requests = %+v", reqs)
+	}
+	if r := reqs[0]; r.Model != gemini.ResolveModelName("pro") || r.System != "Brand voice." || r.AspectRatio != "16:9" || r.InputImages != 1 {
+		t.Fatalf("preset request = %+v", r)
+	}
+	if r := reqs[1]; r.Model != gemini.ResolveModelName("banana") || r.AspectRatio != "1:1" || r.System != "Brand voice." {
+		t.Fatalf("overridden request = %+v", r)
+	}
+	if r := reqs[2]; r.AspectRatio != "1:1" || r.System != "Brand voice." || r.InputImages != 1 {
+		t.Fatalf("icon request = %+v", r)
+	}
+
+	resp, err := runCLI(t, "preset", "list")
+	if err != nil {
+		t.Fatalf("preset list: %v (%v)", err, resp)
+	}
+	if presets := resp["data"].(map[string]any)["presets"].([]any); len(presets) != 1 {
+		t.Fatalf("presets = %v", presets)
+	}
+}
+
+func TestGenerateSamplingFlags(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	history := filepath.Join(dir, "h.json")
+
+	resp, err := runCLI(t, "generate", "a poster", "-o", filepath.Join(dir, "a.png"), "--seed", "42", "--temperature", "0.5", "--top-k", "16", "--history-out", history, "--base-url", srv.URL, "--api-key", "k")
+	if err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	sampling, _ := resp["data"].(map[string]any)["sampling"].(map[string]any)
+	if sampling["seed"] != float64(42) || sampling["temperature"] != 0.5 || sampling["top_k"] != float64(16) || sampling["top_p"] != nil {
+		t.Fatalf("sampling = %v", sampling)
+	}
+
+	body := string(srv.Requests()[0].Body)
+	for _, want := range []string{`"seed":42`, `"temperature":0.5`, `"topK":16`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("request body missing %s: %s", want, body)
+		}
+	}
+	if strings.Contains(body, "topP") || strings.Contains(body, "candidateCount") {
+		t.Fatalf("request body has unset sampling fields: %s", body)
+	}
+
+	saved, err := gemini.LoadHistory(history)
+	if err != nil {
+		t.Fatalf("LoadHistory: %v", err)
+	}
+	if saved.Sampling == nil || *saved.Sampling.Seed != 42 || *saved.Sampling.Temperature != 0.5 {
+		t.Fatalf("history sampling = %+v", saved.Sampling)
+	}
+
+	if _, err := runCLI(t, "generate", "too hot", "-o", filepath.Join(dir, "b.png"), "--temperature", "3", "--base-url", srv.URL, "--api-key", "k"); err == nil {
+		t.Fatal("expected an out-of-range temperature to fail")
+	}
+	if _, err := runCLI(t, "generate", "both", "-o", filepath.Join(dir, "c.png"), "--count", "2", "--candidate-count", "2", "--base-url", srv.URL, "--api-key", "k"); err == nil {
+		t.Fatal("expected --count with --candidate-count to fail")
+	}
+	if n := len(srv.Requests()); n != 1 {
+		t.Fatalf("requests = %d, want 1", n)
+	}
+}
+
+func TestModelsRegistry(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	configDirs[t] = t.TempDir()
+	os.WriteFile(filepath.Join(configDirs[t], "config.yaml"), []byte(`models:
+  gemini-4-flash-image-preview:
+    aliases: [banana4]
+    aspect_ratios: ["1:1", "16:9"]
+    image_sizes: [1K]
+    thinking_level: false
+`), 0644)
+
+	resp, err := runCLI(t, "models", "banana4")
+	if err != nil {
+		t.Fatalf("models: %v (%v)", err, resp)
+	}
+	declared := resp["data"].(map[string]any)["model"].(map[string]any)
+	if declared["id"] != "gemini-4-flash-image-preview" || declared["source"] != gemini.SourceConfig || declared["thinking_level"] != false {
+		t.Fatalf("declared model = %v", declared)
+	}
+
+	if resp, err := runCLI(t, "generate", "a kite", "-o", filepath.Join(dir, "a.png"), "-m", "banana4", "--aspect-ratio", "16:9", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate: %v (%v)", err, resp)
+	}
+	for _, args := range [][]string{{"--aspect-ratio", "3:2"}, {"--image-size", "2K"}, {"--thinking-level", "high"}} {
+		args = append([]string{"generate", "a kite", "-o", filepath.Join(dir, "b.png"), "-m", "banana4", "--base-url", srv.URL, "--api-key", "k"}, args...)
+		if _, err := runCLI(t, args...); err == nil {
+			t.Fatalf("%v should be rejected for the declared model", args)
+		}
+	}
+
+	resp, err = runCLI(t, "models", "--refresh", "--base-url", srv.URL, "--api-key", "k")
+	if err != nil {
+		t.Fatalf("models --refresh: %v (%v)", err, resp)
+	}
+	var ids []string
+	for _, m := range resp["data"].(map[string]any)["models"].([]any) {
+		ids = append(ids, m.(map[string]any)["id"].(string))
+	}
+	if !slices.Contains(ids, "gemini-9-flash-image-preview") || !slices.Contains(ids, "gemini-4-flash-image-preview") || slices.Contains(ids, "gemini-2.5-flash") {
+		t.Fatalf("models = %v", ids)
+	}
+	if _, err := os.Stat(filepath.Join(configDirs[t], "models.json")); err != nil {
+		t.Fatalf("model cache: %v", err)
+	}
+
+	if resp, err := runCLI(t, "generate", "a kite", "-o", filepath.Join(dir, "c.png"), "-m", "gemini-9-flash-image-preview", "--base-url", srv.URL, "--api-key", "k"); err != nil {
+		t.Fatalf("generate with listed model: %v (%v)", err, resp)
+	}
+	resp, err = runCLI(t, "generate", "a kite", "-o", filepath.Join(dir, "d.png"), "-m", "gemini-0-typo-image", "--base-url", srv.URL, "--api-key", "k")
+	if err == nil || resp["error"].(map[string]any)["code"] != gemini.ErrModelNotFound {
+		t.Fatalf("unlisted model: %v (%v)", err, resp)
+	}
+	resp, err = runCLI(t, "generate", "a kite", "-o", filepath.Join(dir, "e.png"), "-m", "gemini-2.5-flash", "--base-url", srv.URL, "--api-key", "k")
+	if err == nil || resp["error"].(map[string]any)["code"] != gemini.ErrInvalidInput {
+		t.Fatalf("listed text model: %v (%v)", err, resp)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 2 || reqs[0].Model != "gemini-4-flash-image-preview" || reqs[1].Model != "gemini-9-flash-image-preview" {
+		t.Fatalf("requests = %+v", reqs)
+	}
+}
@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	appconfig "github.com/lyalindotcom/nano-banana-cli/internal/config"
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini/geminitest"
+	"github.com/lyalindotcom/nano-banana-cli/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags shared by every Gemini-backed command
+	retries      int
+	retryMaxWait time.Duration
+	baseURL      string
+	headerFlags  []string
+	safetyFlags  []string
+	proxyURL     string
+	caCertFile   string
+	backendName  string
+	gcpProject   string
+	gcpLocation  string
+	recordDir    string
+	replayDir    string
+)
+
+// addGeminiFlags registers flags common to commands that call the Gemini API.
+func addGeminiFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&retries, "retries", gemini.DefaultMaxRetries, "Retries for rate-limited or unavailable API responses (0 disables)")
+	cmd.Flags().DurationVar(&retryMaxWait, "retry-max-wait", gemini.DefaultRetryMaxWait, "Longest single wait between retries, including server-requested delays")
+	cmd.Flags().StringVar(&baseURL, "base-url", "", "API base URL including version (or NANOBANANA_BASE_URL / base_url config)")
+	cmd.Flags().StringArrayVar(&headerFlags, "header", nil, "Extra request header as Name=Value (repeatable)")
+	cmd.Flags().StringArrayVar(&safetyFlags, "safety", nil, "Safety threshold as category=threshold, e.g. dangerous=none (repeatable; categories: harassment, hate, sexual, dangerous, civic; thresholds: none, high, medium, low, off)")
+	cmd.Flags().StringVar(&proxyURL, "proxy", "", "HTTP(S) proxy URL (defaults to HTTPS_PROXY)")
+	cmd.Flags().StringVar(&caCertFile, "ca-cert", "", "PEM file with extra CA certificates to trust")
+	cmd.Flags().StringVar(&backendName, "backend", "", "API backend: rest (AI Studio, API key) or vertex (Vertex AI, application-default credentials)")
+	cmd.Flags().StringVar(&gcpProject, "project", "", "Google Cloud project for --backend vertex (or GOOGLE_CLOUD_PROJECT)")
+	cmd.Flags().StringVar(&gcpLocation, "location", "", "Google Cloud location for --backend vertex (or GOOGLE_CLOUD_LOCATION, default global)")
+	cmd.Flags().StringVar(&recordDir, "record", "", "Record API exchanges as test fixtures in this directory")
+	cmd.Flags().StringVar(&replayDir, "replay", "", "Replay API exchanges from fixtures in this directory instead of calling the API")
+	cmd.Flags().MarkHidden("record")
+	cmd.Flags().MarkHidden("replay")
+}
+
+// loadConfig returns the user config, or an empty one if it cannot be read.
+func loadConfig() *appconfig.Config {
+	cfg, err := appconfig.Load()
+	if err != nil {
+		return &appconfig.Config{}
+	}
+	return cfg
+}
+
+// usingVertex reports whether the selected backend authenticates with Google
+// Cloud credentials instead of an API key.
+func usingVertex() bool {
+	backend, err := gemini.ParseBackend(firstNonEmpty(backendName, loadConfig().Backend))
+	return err == nil && backend == gemini.BackendVertex
+}
+
+func clientErrorHint() string {
+	if usingVertex() {
+		return "Check --project/--location and run: gcloud auth application-default login"
+	}
+	return "Check your API key"
+}
+
+// newGeminiClient builds a client for the selected model using the shared
+// Gemini flags, falling back to the user config for network settings.
+func newGeminiClient(apiKey string, timeout time.Duration) (*gemini.Client, error) {
+	return newGeminiClientForModel(apiKey, GetModel(), timeout)
+}
+
+func newGeminiClientForModel(apiKey, model string, timeout time.Duration) (*gemini.Client, error) {
+	opts, err := geminiClientOptions(model)
+	if err != nil {
+		return nil, err
+	}
+	return gemini.NewClient(apiKey, model, timeout, opts...)
+}
+
+func geminiClientOptions(model string) ([]gemini.ClientOption, error) {
+	cfg := loadConfig()
+	var err error
+
+	policy := gemini.DefaultRetryPolicy()
+	policy.MaxAttempts = max(retries, 0) + 1
+	policy.MaxWait = retryMaxWait
+	opts := []gemini.ClientOption{gemini.WithRetryPolicy(policy), gemini.WithStdin(rootCmd.InOrStdin())}
+	if IsVerbose() {
+		opts = append(opts, gemini.WithTrace(os.Stderr))
+	}
+
+	if pricing, ok := configuredPricing(cfg, gemini.ResolveModelName(model)); ok {
+		opts = append(opts, gemini.WithPricing(pricing))
+	}
+
+	if url := firstNonEmpty(baseURL, cfg.BaseURL); url != "" {
+		opts = append(opts, gemini.WithBaseURL(url))
+	}
+
+	headers := map[string]string{}
+	for name, value := range cfg.Headers {
+		headers[name] = value
+	}
+	for _, raw := range headerFlags {
+		name, value, ok := strings.Cut(raw, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --header %q, expected Name=Value", raw)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	if len(headers) > 0 {
+		opts = append(opts, gemini.WithHeaders(headers))
+	}
+
+	safety, err := configuredSafety(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(safety) > 0 {
+		opts = append(opts, gemini.WithSafetySettings(safety))
+	}
+
+	if path, err := fileCachePath(); err == nil {
+		// A broken cache only costs re-uploads, so it must not stop runs
+		// that do not upload at all.
+		cache, err := gemini.OpenFileCache(path)
+		if err != nil {
+			GetFormatter().Progress("Ignoring file cache: %v", err)
+		} else {
+			opts = append(opts, gemini.WithFileCache(cache))
+		}
+	}
+
+	transportCfg := gemini.TransportConfig{
+		ProxyURL:   firstNonEmpty(proxyURL, cfg.Proxy),
+		CACertFile: firstNonEmpty(caCertFile, cfg.CACert),
+	}
+	var transport http.RoundTripper
+	if transportCfg != (gemini.TransportConfig{}) {
+		if transport, err = gemini.NewTransport(transportCfg); err != nil {
+			return nil, err
+		}
+	}
+	if recordDir != "" || replayDir != "" {
+		mode, dir := geminitest.ModeRecord, recordDir
+		if replayDir != "" {
+			mode, dir = geminitest.ModeReplay, replayDir
+		}
+		if transport, err = geminitest.NewRecorder(mode, dir, transport); err != nil {
+			return nil, err
+		}
+	}
+	if transport != nil {
+		opts = append(opts, gemini.WithTransport(transport))
+	}
+
+	backend, err := gemini.ParseBackend(firstNonEmpty(backendName, cfg.Backend))
+	if err != nil {
+		return nil, err
+	}
+	if backend == gemini.BackendVertex {
+		opts = append(opts, gemini.WithVertex(gemini.VertexConfig{
+			Project:  firstNonEmpty(gcpProject, cfg.Project),
+			Location: firstNonEmpty(gcpLocation, cfg.Location),
+		}))
+	}
+
+	return opts, nil
+}
+
+// configuredSafety returns the config's safety settings followed by the
+// --safety flags, so that a flag wins for the category it names.
+func configuredSafety(cfg *appconfig.Config) ([]gemini.SafetySetting, error) {
+	safety, err := gemini.ParseSafetyMap(cfg.Safety)
+	if err != nil {
+		return nil, fmt.Errorf("invalid safety config: %w", err)
+	}
+	flags, err := safetyFlagSettings()
+	if err != nil {
+		return nil, err
+	}
+	return append(safety, flags...), nil
+}
+
+func safetyFlagSettings() ([]gemini.SafetySetting, error) {
+	var safety []gemini.SafetySetting
+	for _, raw := range safetyFlags {
+		setting, err := gemini.ParseSafetySetting(raw)
+		if err != nil {
+			return nil, err
+		}
+		safety = append(safety, setting)
+	}
+	return safety, nil
+}
+
+// safetyMap returns settings as a category to threshold map, the form batch
+// jobs and manifests use. Later settings win for the same category.
+func safetyMap(settings []gemini.SafetySetting) map[string]string {
+	if len(settings) == 0 {
+		return nil
+	}
+	safety := map[string]string{}
+	for _, s := range settings {
+		safety[s.Category] = s.Threshold
+	}
+	return safety
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// configuredPricing returns a price override from the user config for modelID,
+// matching entries keyed by either the full ID or an alias.
+func configuredPricing(cfg *appconfig.Config, modelID string) (gemini.ModelPricing, bool) {
+	for key, p := range cfg.Pricing {
+		if gemini.ResolveModelName(key) == modelID {
+			return gemini.ModelPricing{
+				InputPerMillion:       p.InputPerMillion,
+				TextOutputPerMillion:  p.TextOutputPerMillion,
+				ImageOutputPerMillion: p.ImageOutputPerMillion,
+			}, true
+		}
+	}
+	return gemini.ModelPricing{}, false
+}
+
+// reportGenerateError prints a Generate failure, including structured API error details.
+func reportGenerateError(command string, err error) {
+	info := generateErrorInfo(err)
+	GetFormatter().ErrorWithDetails(command, info.Code, info.Message, info.Hint, info.Details)
+}
+
+// generateErrorInfo maps a Generate failure to an error code, hint and details.
+func generateErrorInfo(err error) *output.ErrorInfo {
+	var geminiErr *gemini.GeminiError
+	if !errors.As(err, &geminiErr) {
+		return &output.ErrorInfo{Code: "GENERATION_FAILED", Message: err.Error()}
+	}
+
+	hint := ""
+	switch geminiErr.Code {
+	case gemini.ErrInvalidAPIKey:
+		hint = "Check your API key at https://aistudio.google.com/apikey"
+		if usingVertex() {
+			hint = "Refresh credentials with: gcloud auth application-default login"
+		}
+	case gemini.ErrPermissionDenied:
+		hint = "Check that your API key or project has access to this model"
+	case gemini.ErrQuotaExceeded, gemini.ErrRateLimited:
+		hint = "Wait before retrying, raise --retries, or check your quota"
+	case gemini.ErrModelNotFound:
+		hint = "Check the model ID or use an alias: banana2, banana, pro; list models with: nanobanana models --refresh"
+	case gemini.ErrNotFound:
+		hint = "Check the name; it may have expired or been deleted"
+	case gemini.ErrServiceUnavailable, gemini.ErrTimeout:
+		hint = "The service is busy; try again shortly or raise --retries"
+	case gemini.ErrSafetyBlocked, gemini.ErrPromptBlocked, gemini.ErrImageSafety:
+		hint = "Try rephrasing your prompt"
+		if geminiErr.BlockedCategory != "" {
+			hint += fmt.Sprintf(", or adjust the threshold with --safety %s=high", geminiErr.BlockedCategory)
+		}
+	case gemini.ErrRecitation:
+		hint = "Ask for a more original composition instead of reproducing existing content"
+	case gemini.ErrTextOnly:
+		hint = "Ask explicitly for an image, or retry"
+	case gemini.ErrRequestTooLarge:
+		hint = "Pass --fit-inputs to downscale reference images, or use fewer or smaller ones"
+	}
+	return &output.ErrorInfo{Code: geminiErr.Code, Message: geminiErr.Message, Hint: hint, Details: geminiErr.DetailMap()}
+}
@@ -3,7 +3,9 @@ package cli
 import (
 	"bufio"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 
 	appconfig "github.com/lyalindotcom/nano-banana-cli/internal/config"
@@ -73,6 +75,14 @@ var configShowCmd = &cobra.Command{
 			"default_model":      cfg.Model,
 			"output_dir":         cfg.OutputDir,
 			"timeout":            cfg.Timeout.String(),
+			"base_url":           cfg.BaseURL,
+			"proxy":              cfg.Proxy,
+			"ca_cert":            cfg.CACert,
+			"header_count":       len(cfg.Headers),
+			"backend":            cfg.Backend,
+			"project":            cfg.Project,
+			"location":           cfg.Location,
+			"safety":             cfg.Safety,
 		}
 
 		f.Success("config show", data, nil)
@@ -85,6 +95,30 @@ var configShowCmd = &cobra.Command{
 		fmt.Printf("Default model: %s\n", cfg.Model)
 		fmt.Printf("Output dir: %s\n", cfg.OutputDir)
 		fmt.Printf("Timeout: %s\n", cfg.Timeout.String())
+		if cfg.BaseURL != "" {
+			fmt.Printf("Base URL: %s\n", cfg.BaseURL)
+		}
+		if cfg.Proxy != "" {
+			fmt.Printf("Proxy: %s\n", cfg.Proxy)
+		}
+		if cfg.CACert != "" {
+			fmt.Printf("CA cert: %s\n", cfg.CACert)
+		}
+		if cfg.Backend != "" {
+			fmt.Printf("Backend: %s\n", cfg.Backend)
+		}
+		if cfg.Project != "" {
+			fmt.Printf("Project: %s\n", cfg.Project)
+		}
+		if cfg.Location != "" {
+			fmt.Printf("Location: %s\n", cfg.Location)
+		}
+		if len(cfg.Headers) > 0 {
+			fmt.Printf("Extra headers: %d\n", len(cfg.Headers))
+		}
+		for _, category := range slices.Sorted(maps.Keys(cfg.Safety)) {
+			fmt.Printf("Safety: %s=%s\n", category, cfg.Safety[category])
+		}
 		return nil
 	},
 }
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini/geminitest"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Dev command flags
+	fakeServerAddr string
+)
+
+var devCmd = &cobra.Command{
+	Use:    "dev",
+	Short:  "Developer tools",
+	Hidden: true,
+}
+
+var devFakeServerCmd = &cobra.Command{
+	Use:   "fake-server",
+	Short: "Serve a fake Gemini API for offline testing",
+	Long: `Serve canned generateContent responses on a local port.
+
+Every call returns a solid-color PNG sized from the requested aspect ratio and
+image size. Point commands at it with --base-url or NANOBANANA_BASE_URL and any
+API key.
+
+EXAMPLES:
+  nanobanana dev fake-server --addr 127.0.0.1:8089
+  NANOBANANA_BASE_URL=http://127.0.0.1:8089/v1beta nanobanana generate "test" -o out.png --api-key fake`,
+	Args: cobra.NoArgs,
+	RunE: runDevFakeServer,
+}
+
+func init() {
+	devFakeServerCmd.Flags().StringVar(&fakeServerAddr, "addr", "127.0.0.1:8089", "Listen address")
+
+	devCmd.AddCommand(devFakeServerCmd)
+	rootCmd.AddCommand(devCmd)
+}
+
+func runDevFakeServer(cmd *cobra.Command, args []string) error {
+	listener, err := net.Listen("tcp", fakeServerAddr)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Fake Gemini API listening on http://%s/v1beta\n", listener.Addr())
+	return http.Serve(listener, geminitest.NewFake())
+}
@@ -33,11 +33,44 @@ Installation and first run:
       "$(go env GOPATH)/bin/nanobanana" docs
     Or add "$(go env GOPATH)/bin" to PATH.
 
+Network:
+  Gemini-backed commands accept --base-url, --header Name=Value (repeatable),
+  --proxy and --ca-cert. Defaults come from NANOBANANA_BASE_URL,
+  NANOBANANA_PROXY, NANOBANANA_CA_CERT, or the base_url, headers, proxy and
+  ca_cert config keys. Standard HTTPS_PROXY is honored when --proxy is unset.
+  The API key is sent in the x-goog-api-key header, never in the URL.
+  -v/--verbose prints an HTTP trace (method, URL, headers, status, timing)
+  to stderr with credentials masked.
+
+Vertex AI:
+  --backend vertex --project P [--location L] sends requests to Vertex AI using
+  application-default credentials (gcloud auth application-default login)
+  instead of an API key. Defaults come from NANOBANANA_BACKEND,
+  GOOGLE_CLOUD_PROJECT, GOOGLE_CLOUD_LOCATION, or the backend, project and
+  location config keys. Location defaults to global. --base-url does not apply.
+
+Safety settings:
+  --safety category=threshold (repeatable) on Gemini-backed commands sets the
+  block threshold for a harm category. Categories: harassment, hate, sexual,
+  dangerous, civic. Thresholds: none, high (block only high), medium, low, off.
+  The API's names (HARM_CATEGORY_DANGEROUS_CONTENT=BLOCK_NONE) also work.
+  Defaults come from the safety config key, e.g. "safety: {dangerous: none}";
+  --safety and a batch job's "safety" map override them per category.
+  When a response is blocked, the error's details name the blocked_category.
+
+Retries:
+  generate, icon, pattern and batch retry rate-limited (429) and unavailable (5xx)
+  responses with exponential backoff, honoring Retry-After and RetryInfo delays.
+  --retries N sets the retry count (default 3, 0 disables).
+  --retry-max-wait caps a single wait (default 1m).
+  JSON output reports attempts and retries in the timing block.
+
 Models:
   banana2, 3.1 -> gemini-3.1-flash-image-preview
   banana, 2.5  -> gemini-2.5-flash-image
   pro          -> gemini-3-pro-image-preview
-  Raw model IDs are also accepted.
+  Raw model IDs are also accepted. Declare new models and aliases with their
+  capabilities under the "models" config key; see: nanobanana models --help
 
 Commands:
 
@@ -49,6 +82,9 @@ Commands:
      -m, --model
      --aspect-ratio
      --image-size
+     --system
+     --system-file
+     --preset
      --ground-web
      --ground-image
      --thinking-level
@@ -56,12 +92,58 @@ Commands:
      --thoughts-dir
      --history-in
      --history-out
+     --history-format
+     --count
+     --concurrency
+     --temperature
+     --top-p
+     --top-k
+     --seed
+     --candidate-count
+     --fit-inputs
+     --upload-inputs
+     --quality
+     --no-metadata
+     --manifest
+     --stream
+     --retries
+     --retry-max-wait
+   --stream uses streamGenerateContent and reports thoughts, text and images
+   as they arrive; with --json it prints NDJSON events ("thought", "text",
+   "image", "done") followed by the final response on one line.
+   --system/--system-file send a system instruction; it is saved in
+   --history-out files and reused by later --history-in turns.
+   --temperature/--top-p/--top-k/--seed are validated against the model and
+   echoed under "sampling" in JSON output and history files; with --count,
+   candidate N is sent seed+N-1. --candidate-count asks a single request for
+   several candidates and cannot be combined with --count or --stream.
+   -i accepts a file, an http(s) URL, a data: URI or - for stdin (up to 20 MB
+   each). The MIME type is sniffed from the content; input that is not a
+   PNG, JPEG, GIF, WebP, HEIC or HEIF image is rejected with INVALID_INPUT.
+   When -i - is used, pass the prompt as an argument or with --prompt-file.
+   Requests over the model's size limit (20 MB unless max_request_bytes is
+   declared under models in config) fail with REQUEST_TOO_LARGE before they
+   are sent; --fit-inputs instead downscales and re-encodes the largest
+   references as JPEG (PNG when transparent) until they fit, listing each
+   under "input_adjustments" in JSON output.
+   --upload-inputs sends references through the Files API and reuses earlier
+   uploads of the same bytes (see files).
+   Images are converted to the format of the -o extension: .png, .jpg
+   (--quality sets the JPEG quality, default 90), .gif, .tiff, .bmp or .webp
+   (lossless). A path without an extension keeps the model's format; unknown
+   extensions fail with UNSUPPORTED_FORMAT before the request is sent.
+   PNG and JPEG outputs record how they were made (see meta show);
+   --no-metadata leaves the record out.
+   --manifest writes <output>.nb.json with the full request, the seed (one
+   is picked when --seed is not given) and reference hashes, for regenerate.
    Examples:
      nanobanana generate "a robot playing guitar" -o robot.png
      nanobanana generate "add sunglasses" -i face.png -o face-edit.png
      nanobanana generate "group photo of these people" -i p1.png -i p2.png -o group.png
+     curl -s https://example.com/a.jpg | nanobanana generate "restyle as ink" -i - -o ink.png
      nanobanana generate "weather poster for New York today" --ground-web -o weather.png
      nanobanana generate "designer perfume bottle" -m pro --image-size 4K -o bottle.png
+     nanobanana generate "hero banner of a mountain lake" --quality 80 -o hero.jpg
 
 2. icon
    Generate icons in multiple sizes.
@@ -70,6 +152,13 @@ Commands:
      --sizes
      --style
      --background
+     --quality
+     --no-metadata
+     --preset
+     --retries
+     --retry-max-wait
+   With a {size} pattern, icons take the pattern's format; a .jpg pattern
+   needs a non-transparent --background.
    Examples:
      nanobanana icon "coffee cup logo" -o ./icons/
      nanobanana icon "settings gear" -o ./icons/ --sizes 16,32,64,128
@@ -81,11 +170,86 @@ Commands:
      --size
      --style
      --type
+     --quality
+     --no-metadata
+     --preset
+     --retries
+     --retry-max-wait
+   The pattern is saved in the format of the -o extension, as with generate.
    Examples:
      nanobanana pattern "hexagon grid" -o hex.png
      nanobanana pattern "oak wood grain" -o wood.png --type texture
 
-4. transform
+4. batch
+   Generate many images from a JSONL or YAML manifest with bounded
+   concurrency. Each job takes generate's fields (prompt, output, inputs,
+   fit_inputs, upload_inputs, quality, model, aspect_ratio, image_size,
+   count, ...).
+   Results are appended to <manifest>.results.jsonl in the generate JSON
+   shape; jobs whose output exists are skipped, so reruns resume.
+   Key flags:
+     --concurrency
+     --results
+     --overwrite
+   Examples:
+     nanobanana batch jobs.jsonl
+     nanobanana batch jobs.yaml --concurrency 8 --results run.jsonl
+   Batch API (half price, asynchronous, rest backend only):
+     nanobanana batch submit jobs.jsonl       # writes jobs.batch.json
+     nanobanana batch status jobs.batch.json
+     nanobanana batch fetch jobs.batch.json --wait
+
+5. session
+   Interactive multi-turn editing. Each line is the next turn of one
+   conversation; images are saved as <name>_001.png, <name>_002.png, ...
+   Sessions are saved after every turn and resumed by name.
+   Commands: /attach FILE..., /undo, /model [NAME], /save [FILE],
+   /branch NAME, /help, /quit
+   Key flags:
+     --output-dir
+     --aspect-ratio
+     --image-size
+     --fit-inputs
+     --upload-inputs
+   Examples:
+     nanobanana session poster --output-dir art -m pro
+     printf 'a red bicycle\n/undo\na blue bicycle\n' | nanobanana session bikes --json
+
+6. history show|turns|rewind|fork|extract|migrate
+   Inspect and edit history files written by generate --history-out.
+   show summarizes a file, turns lists each prompt and reply, rewind
+   drops the last N turns, fork copies a history up to a turn, extract
+   writes every embedded image to a directory and migrate converts
+   between inline images and external files in <file>.blobs.
+   Key flags:
+     -o, --output
+     --at (fork)
+     --to inline|external (migrate)
+   Examples:
+     nanobanana history turns photo-history.json
+     nanobanana history rewind photo-history.json 2
+     nanobanana history fork photo-history.json --at 1 -o photo-v2.json
+     nanobanana history extract photo-history.json -o photo-images
+     nanobanana history migrate photo-history.json --to external
+
+7. preset save|list|show|delete
+   Named presets bundling a system instruction, model, aspect ratio, image
+   size and reference images, applied with --preset on generate, icon and
+   pattern. Flags given on the command line override the preset; icon and
+   pattern keep their own aspect ratio. Presets live in the config dir.
+   Key flags (save):
+     --system
+     --system-file
+     -m, --model
+     --aspect-ratio
+     --image-size
+     --ref (repeatable)
+     --overwrite
+   Examples:
+     nanobanana preset save brand-hero --system-file brand.txt -m pro --aspect-ratio 16:9 --ref logo.png
+     nanobanana generate "team offsite poster" --preset brand-hero -o offsite.png
+
+8. transform
    Apply local image transforms.
    Key flags:
      -o, --output
@@ -99,7 +263,7 @@ Commands:
      nanobanana transform photo.jpg -o thumb.jpg --resize 200x200
      nanobanana transform image.png -o cropped.png --crop 100,50,400,300
 
-5. transparent make
+9. transparent make
    Remove a background color and save a transparent PNG.
    Key flags:
      -o, --output
@@ -109,12 +273,12 @@ Commands:
    Example:
      nanobanana transparent make sprite.png -o sprite-clean.png
 
-6. transparent inspect
+10. transparent inspect
    Inspect transparency details for an image.
    Example:
      nanobanana transparent inspect sprite.png
 
-7. combine
+11. combine
    Combine multiple images into one strip or grid.
    Key flags:
      -o, --output
@@ -127,10 +291,69 @@ Commands:
      nanobanana combine frame1.png frame2.png frame3.png -o spritesheet.png
      nanobanana combine *.png -o grid.png --direction grid --columns 4
 
-8. version
+12. files list|delete
+   Manage reference images uploaded with the Files API. generate and
+   session take --upload-inputs (batch jobs: "upload_inputs") to send
+   references as fileData parts instead of inline. Uploads are cached in
+   files.json in the config dir by base URL, API key and content SHA-256,
+   and reused until an hour before they expire (48 hours after upload); a
+   cached upload the API rejects is uploaded again. rest backend only.
+   Subcommands:
+     list
+     delete [name...] [--all]
+   Examples:
+     nanobanana generate "edit 12" -i ref1.png -i ref2.png --upload-inputs -o e12.png
+     nanobanana files list --json
+     nanobanana files delete --all
+
+13. regenerate <manifest>
+   Re-run a generation from the sidecar written by generate --manifest:
+   same prompt, model ID, options, seed and reference images. Outputs are
+   overwritten unless -o is given, and the manifest is rewritten next to
+   them. --model refreshes the asset with another model. The recorded
+   safety settings (config defaults and --safety flags) replace the current
+   config's; --safety flags override them per category. Reference files
+   whose SHA-256 no longer matches fail with INPUT_CHANGED unless
+   --allow-changed-inputs is given.
+   Key flags:
+     -o, --output
+     --allow-changed-inputs
+     --no-metadata
+   Examples:
+     nanobanana generate "hero banner of a mountain lake" -o hero.png --manifest
+     nanobanana regenerate hero.png.nb.json
+     nanobanana regenerate hero.png.nb.json -m pro -o hero-pro.png
+
+14. meta show <file>
+   Show the provenance generate, icon and pattern embed in their outputs:
+   prompt, command, model ID, options (aspect ratio, image size, sampling,
+   system instruction, ...), a SHA-256 per reference image, the time and the
+   nanobanana version. PNGs carry it in tEXt/iTXt chunks (the JSON record is
+   the "nanobanana" iTXt chunk), JPEGs in an XMP packet; other formats are
+   saved without it. --no-metadata on those commands leaves it out. Files
+   without a record fail with NO_METADATA.
+   Examples:
+     nanobanana meta show hero.png
+     nanobanana meta show hero.jpg --json
+
+15. models [model]
+   List known models with their aliases, aspect ratios, image sizes and
+   features, or show one model. Sources: builtin, api (fetched with
+   --refresh and cached in models.json in the config dir) and config (the
+   "models" key, which wins). Once a list is cached, unlisted and undeclared
+   model IDs are rejected before any request is made.
+   Key flags:
+     --refresh
+     --all
+   Examples:
+     nanobanana models
+     nanobanana models pro --json
+     nanobanana models --refresh
+
+16. version
    Print version and build information.
 
-9. config
+17. config
    Manage persistent user-level configuration.
    Subcommands:
      path
@@ -141,7 +364,19 @@ Commands:
      nanobanana config set-api-key
      nanobanana config show
 
-10. docs
+18. usage
+   Summarize token usage and estimated cost from the local ledger.
+   Every successful generate, icon, pattern, batch and session call is
+   recorded in usage.jsonl next to the config file. Prices can be overridden per model
+   under the "pricing" config key.
+   Key flags:
+     --by day|model|command
+     --since YYYY-MM-DD
+   Examples:
+     nanobanana usage
+     nanobanana usage --by model --json
+
+19. docs
    Print this manual.
 `
 
@@ -161,12 +396,22 @@ var docsCmd = &cobra.Command{
 					"generate",
 					"icon",
 					"pattern",
+					"batch",
+					"session",
+					"history",
+					"preset",
 					"transform",
 					"transparent make",
 					"transparent inspect",
 					"combine",
+					"files list",
+					"files delete",
+					"regenerate",
+					"meta show",
+					"models",
 					"version",
 					"config",
+					"usage",
 					"docs",
 				},
 			}, nil)
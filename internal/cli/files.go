@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	appconfig "github.com/lyalindotcom/nano-banana-cli/internal/config"
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Files delete flags
+	filesDeleteAll bool
+)
+
+var filesCmd = &cobra.Command{
+	Use:   "files",
+	Short: "Manage reference images uploaded with the Files API",
+	Long: `Manage files uploaded with --upload-inputs.
+
+With --upload-inputs, generate and session send reference images through the
+Gemini Files API instead of inline. Each upload is cached in files.json in the
+config dir, keyed by the base URL, a hash of the API key and the SHA-256 of
+its content, so the same image is uploaded once per account and reused until
+shortly before it expires (the API keeps uploads for 48 hours). An upload the
+API no longer accepts is dropped from the cache and sent again. Batch jobs
+take the same setting as "upload_inputs".
+
+The Files API is only available on the rest backend.
+
+EXAMPLES:
+  nanobanana generate "put the product on a beach" -i product.png --upload-inputs -o beach.png
+  nanobanana files list
+  nanobanana files delete files/abc123
+  nanobanana files delete --all`,
+}
+
+var filesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List uploaded files",
+	Args:  cobra.NoArgs,
+	RunE:  runFilesList,
+}
+
+var filesDeleteCmd = &cobra.Command{
+	Use:   "delete [name...]",
+	Short: "Delete uploaded files and forget them in the cache",
+	Long: `Delete uploaded files and forget them in the upload cache.
+
+Names the API no longer knows, because they expired or were already deleted,
+are reported as not found and dropped from the cache; the remaining names
+are still deleted.`,
+	RunE: runFilesDelete,
+}
+
+func init() {
+	filesDeleteCmd.Flags().BoolVar(&filesDeleteAll, "all", false, "Delete every uploaded file")
+
+	for _, cmd := range []*cobra.Command{filesListCmd, filesDeleteCmd} {
+		addGeminiFlags(cmd)
+		filesCmd.AddCommand(cmd)
+	}
+	rootCmd.AddCommand(filesCmd)
+}
+
+// addUploadFlag registers --upload-inputs.
+func addUploadFlag(cmd *cobra.Command, target *bool) {
+	cmd.Flags().BoolVar(target, "upload-inputs", false, "Send reference images through the Files API, reusing earlier uploads of the same content")
+}
+
+// fileCachePath is where uploads are cached by content hash.
+func fileCachePath() (string, error) {
+	dir, err := appconfig.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "files.json"), nil
+}
+
+// newFilesClient creates a client for the files subcommands.
+func newFilesClient() (*gemini.Client, error) {
+	f := GetFormatter()
+	apiKey := GetAPIKey()
+	if apiKey == "" && !usingVertex() {
+		f.Error("files", "MISSING_API_KEY", "No API key provided", "Set GEMINI_API_KEY environment variable or use --api-key flag")
+		return nil, fmt.Errorf("missing API key")
+	}
+	client, err := newGeminiClient(apiKey, time.Minute)
+	if err != nil {
+		f.Error("files", "CLIENT_ERROR", err.Error(), clientErrorHint())
+		return nil, err
+	}
+	return client, nil
+}
+
+func openFileCache() (*gemini.FileCache, error) {
+	path, err := fileCachePath()
+	if err != nil {
+		return nil, err
+	}
+	return gemini.OpenFileCache(path)
+}
+
+func runFilesList(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	client, err := newFilesClient()
+	if err != nil {
+		return err
+	}
+	files, err := client.ListFiles(context.Background())
+	if err != nil {
+		reportGenerateError("files", err)
+		return err
+	}
+	cache, err := openFileCache()
+	if err != nil {
+		f.Error("files", "INVALID_FILE_CACHE", err.Error(), "")
+		return err
+	}
+	hashes := cache.Hashes(client.FileScope())
+
+	list := make([]map[string]any, 0, len(files))
+	for _, file := range files {
+		f.Info("%-24s %-24s %9s  expires %s", file.Name, firstNonEmpty(file.DisplayName, "-"), formatBytes(file.SizeBytes), file.ExpiresAt.Local().Format("2006-01-02 15:04"))
+		list = append(list, map[string]any{
+			"name":         file.Name,
+			"display_name": file.DisplayName,
+			"mime_type":    file.MimeType,
+			"size_bytes":   file.SizeBytes,
+			"uri":          file.URI,
+			"state":        file.State,
+			"created_at":   file.CreatedAt,
+			"expires_at":   file.ExpiresAt,
+			"cached":       hashes[file.Name] != "",
+			"content_hash": hashes[file.Name],
+		})
+	}
+	if len(files) == 0 {
+		f.Info("No uploaded files")
+	}
+	f.Success("files", map[string]any{"files": list, "count": len(list)}, nil)
+	return nil
+}
+
+func runFilesDelete(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	if len(args) == 0 && !filesDeleteAll {
+		f.Error("files", "MISSING_FILE", "Name the files to delete or pass --all", "List files with: nanobanana files list")
+		return fmt.Errorf("no files to delete")
+	}
+	client, err := newFilesClient()
+	if err != nil {
+		return err
+	}
+	cache, err := openFileCache()
+	if err != nil {
+		f.Error("files", "INVALID_FILE_CACHE", err.Error(), "")
+		return err
+	}
+
+	names := args
+	if filesDeleteAll {
+		files, err := client.ListFiles(context.Background())
+		if err != nil {
+			reportGenerateError("files", err)
+			return err
+		}
+		for _, file := range files {
+			names = append(names, file.Name)
+		}
+	}
+
+	deleted, missing := []string{}, []string{}
+	for _, name := range names {
+		// A file that expired or was already deleted only needs its cache
+		// entry dropped.
+		err := client.DeleteFile(context.Background(), name)
+		var gerr *gemini.GeminiError
+		notFound := errors.As(err, &gerr) && gerr.Code == gemini.ErrNotFound
+		if err != nil && !notFound {
+			reportGenerateError("files", err)
+			return err
+		}
+		if err := cache.Remove(client.FileScope(), name); err != nil {
+			f.Error("files", "SAVE_FAILED", err.Error(), "")
+			return err
+		}
+		if notFound {
+			f.Info("Already gone: %s", name)
+			missing = append(missing, name)
+			continue
+		}
+		f.Info("Deleted %s", name)
+		deleted = append(deleted, name)
+	}
+	f.Success("files", map[string]any{"deleted": deleted, "not_found": missing}, nil)
+	return nil
+}
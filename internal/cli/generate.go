@@ -3,8 +3,10 @@ package cli
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	"slices"
@@ -12,7 +14,9 @@ import (
 	"time"
 
 	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/image"
 	"github.com/lyalindotcom/nano-banana-cli/internal/output"
+	"github.com/lyalindotcom/nano-banana-cli/internal/provenance"
 	"github.com/spf13/cobra"
 )
 
@@ -31,6 +35,18 @@ var (
 	groundImage     bool
 	historyIn       string
 	historyOut      string
+	historyFormat   string
+	concurrency     int
+	streamOutput    bool
+	temperature     float64
+	topP            float64
+	topK            int
+	seed            int32
+	candidateCount  int
+	fitInputs       bool
+	uploadInputs    bool
+	quality         int
+	writeManifest   bool
 )
 
 var generateCmd = &cobra.Command{
@@ -65,6 +81,11 @@ IMAGE SIZES:
   Gemini 3 Pro: 1K, 2K, 4K
   Gemini 2.5: fixed 1K behavior
 
+OUTPUT FORMATS:
+  Images are converted to the format of the output extension: .png, .jpg
+  (see --quality), .gif, .tiff, .bmp or .webp (lossless). A path without an
+  extension keeps the format the model returned.
+
 EXAMPLES:
   # Generate a simple image
   nanobanana generate "a sunset over mountains" -o sunset.png
@@ -78,25 +99,41 @@ EXAMPLES:
   # Edit an existing image
   nanobanana generate "add sunglasses" -i face.png -o face-sunglasses.png
 
+  # Generate four variations, two requests at a time
+  nanobanana generate "mascot concept art" --count 4 --concurrency 2 -o mascot.png
+
+  # Save a JPEG for the web
+  nanobanana generate "hero banner of a mountain lake" --aspect-ratio 16:9 --quality 80 -o hero.jpg
+
   # Use multiple reference images
   nanobanana generate "office group photo of these people" -i person1.png -i person2.png -o group.png
 
+  # Use a reference image from a URL or piped on stdin
+  nanobanana generate "restyle as a woodcut" -i https://example.com/photo.jpg -o woodcut.png
+  curl -s https://example.com/photo.jpg | nanobanana generate "restyle as a woodcut" -i - -o woodcut.png
+
   # Ground with Google Search
   nanobanana generate "stylish graphic of today's weather in NYC" --ground-web -o weather.png
 
   # Ground with web + image search (Gemini 3.1 only)
   nanobanana generate "a detailed painting of a Timareta butterfly" --ground-image -o butterfly.png
 
+  # Show thoughts and images as they arrive (NDJSON events with --json)
+  nanobanana generate "detailed map of a fantasy city" -m pro --include-thoughts --stream -o map.png
+
   # Save and resume scripted history
   nanobanana generate "Create a colorful infographic about photosynthesis" -o photo.png --history-out photo-history.json
-  nanobanana generate "Translate the infographic to Spanish and keep everything else the same" -o photo-es.png --history-in photo-history.json --history-out photo-history.json`,
+  nanobanana generate "Translate the infographic to Spanish and keep everything else the same" -o photo-es.png --history-in photo-history.json --history-out photo-history.json
+
+  # Keep history images in photo-history.blobs/ instead of inline base64
+  nanobanana generate "Create a colorful infographic about photosynthesis" -o photo.png --history-out photo-history.json --history-format external`,
 	Args: cobra.MinimumNArgs(0),
 	RunE: runGenerate,
 }
 
 func init() {
 	generateCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Output file path (required)")
-	generateCmd.Flags().StringArrayVarP(&inputPaths, "input", "i", nil, "Input/reference image (repeat up to model limit)")
+	generateCmd.Flags().StringArrayVarP(&inputPaths, "input", "i", nil, "Input/reference image: a file, http(s) URL, data: URI or - for stdin (repeat up to model limit)")
 	generateCmd.Flags().StringVarP(&promptFile, "prompt-file", "p", "", "Read prompt from file (supports multi-line)")
 	generateCmd.Flags().IntVarP(&count, "count", "c", 1, "Number of images to generate (1-10)")
 	generateCmd.Flags().StringVar(&aspectRatio, "aspect-ratio", "1:1", "Aspect ratio")
@@ -109,6 +146,23 @@ func init() {
 	generateCmd.Flags().BoolVar(&groundImage, "ground-image", false, "Enable Google Image Search grounding (Gemini 3.1 only)")
 	generateCmd.Flags().StringVar(&historyIn, "history-in", "", "Resume a scripted image conversation from a JSON history file")
 	generateCmd.Flags().StringVar(&historyOut, "history-out", "", "Write updated conversation history to a JSON file")
+	generateCmd.Flags().StringVar(&historyFormat, "history-format", "", "History file format: inline, or external to store images next to it (default: keep --history-in's)")
+	generateCmd.Flags().IntVar(&concurrency, "concurrency", gemini.DefaultConcurrency, "Maximum parallel requests when --count is above 1")
+	generateCmd.Flags().Float64Var(&temperature, "temperature", 0, "Sampling temperature (model default when unset)")
+	generateCmd.Flags().Float64Var(&topP, "top-p", 0, "Nucleus sampling probability mass, 0-1")
+	generateCmd.Flags().IntVar(&topK, "top-k", 0, "Sample from the K most likely tokens")
+	generateCmd.Flags().Int32Var(&seed, "seed", 0, "Seed for repeatable sampling; with --count, candidate N uses seed+N-1")
+	generateCmd.Flags().IntVar(&candidateCount, "candidate-count", 1, "Candidates returned by a single request (the API's candidateCount)")
+	generateCmd.Flags().BoolVar(&fitInputs, "fit-inputs", false, "Downscale and re-encode reference images that would push the request over the model's size limit")
+	addUploadFlag(generateCmd, &uploadInputs)
+	addQualityFlag(generateCmd, &quality)
+	addMetadataFlag(generateCmd)
+	generateCmd.Flags().BoolVar(&writeManifest, "manifest", false, "Write <output>.nb.json recording the request for nanobanana regenerate")
+	generateCmd.Flags().BoolVar(&streamOutput, "stream", false, "Stream the response and report thoughts, text and images as they arrive (NDJSON with --json)")
+
+	addSystemFlags(generateCmd)
+	addPresetFlag(generateCmd)
+	addGeminiFlags(generateCmd)
 
 	generateCmd.MarkFlagRequired("output")
 }
@@ -117,6 +171,11 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	f := GetFormatter()
 	startTime := time.Now()
 
+	if slices.Contains(inputPaths, gemini.StdinInput) && promptFile == "" && (len(args) == 0 || (len(args) == 1 && args[0] == "-")) {
+		f.Error("generate", "STDIN_CONFLICT", "stdin cannot provide both the prompt and an input image", "Pass the prompt as an argument or with --prompt-file when using -i -")
+		return fmt.Errorf("stdin conflict")
+	}
+
 	prompt, err := getPrompt(args)
 	if err != nil {
 		f.Error("generate", "PROMPT_ERROR", err.Error(), "Provide prompt as argument, --prompt-file, or stdin")
@@ -128,16 +187,42 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	}
 
 	apiKey := GetAPIKey()
-	if apiKey == "" {
+	if apiKey == "" && !usingVertex() {
 		f.Error("generate", "MISSING_API_KEY", "No API key provided", "Set GEMINI_API_KEY environment variable or use --api-key flag")
 		return fmt.Errorf("missing API key")
 	}
 
+	system, err := readSystemInstruction()
+	if err != nil {
+		f.Error("generate", "INVALID_SYSTEM_INSTRUCTION", err.Error(), "")
+		return err
+	}
+	p, err := loadPreset(cmd, "generate")
+	if err != nil {
+		return err
+	}
+	inputs := inputPaths
+	if p != nil {
+		system = firstNonEmpty(system, p.System)
+		if p.AspectRatio != "" && !cmd.Flags().Changed("aspect-ratio") {
+			aspectRatio = p.AspectRatio
+		}
+		if p.ImageSize != "" && !cmd.Flags().Changed("image-size") {
+			imageSize = p.ImageSize
+		}
+		inputs = append(p.ReferencePaths(), inputPaths...)
+	}
+
 	if count < 1 || count > 10 {
 		f.Error("generate", "INVALID_COUNT", "Count must be between 1 and 10", "")
 		return fmt.Errorf("invalid count")
 	}
 
+	if concurrency < 1 {
+		f.Error("generate", "INVALID_CONCURRENCY", "Concurrency must be at least 1", "")
+		return fmt.Errorf("invalid concurrency")
+	}
+
 	if aspectRatio != "" && !gemini.IsValidAspectRatio(aspectRatio) {
 		f.Error("generate", "INVALID_ASPECT_RATIO", fmt.Sprintf("Invalid aspect ratio: %s", aspectRatio), fmt.Sprintf("Valid ratios: %s", strings.Join(gemini.ListAllAspectRatios(), ", ")))
 		return fmt.Errorf("invalid aspect ratio")
@@ -154,11 +239,49 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid thinking level")
 	}
 
-	if (historyIn != "" || historyOut != "") && count != 1 {
-		f.Error("generate", "INVALID_HISTORY_USAGE", "History files require --count 1", "Use a single scripted turn per history file update")
+	sampling := samplingFromFlags(cmd)
+	if writeManifest && sampling.Seed == nil {
+		// Pin a seed so regenerate repeats the same request.
+		s := rand.Int32()
+		sampling.Seed = &s
+	}
+	if err := gemini.ValidateSampling(gemini.ResolveModel(GetModel()).Spec, sampling); err != nil {
+		f.Error("generate", "INVALID_SAMPLING", err.Error(), "")
+		return err
+	}
+	if count > 1 && candidateCount > 1 {
+		f.Error("generate", "INVALID_SAMPLING", "Use either --count or --candidate-count", "--count sends separate requests; --candidate-count asks one request for several candidates")
+		return fmt.Errorf("invalid sampling")
+	}
+
+	if (historyIn != "" || historyOut != "") && (count != 1 || candidateCount > 1) {
+		f.Error("generate", "INVALID_HISTORY_USAGE", "History files require --count 1 and a single candidate", "Use a single scripted turn per history file update")
 		return fmt.Errorf("history requires count 1")
 	}
 
+	if writeManifest && historyIn != "" {
+		f.Error("generate", "INVALID_HISTORY_USAGE", "--manifest cannot record a turn continued with --history-in", "Write the manifest for the first turn, or keep the history file instead")
+		return fmt.Errorf("manifest with history")
+	}
+	if writeManifest && slices.Contains(inputs, gemini.StdinInput) {
+		f.Error("generate", "STDIN_CONFLICT", "--manifest cannot record an input image read from stdin", "Save the image to a file and pass it with -i")
+		return fmt.Errorf("manifest with stdin input")
+	}
+
+	if historyFormat != "" && !slices.Contains([]string{gemini.HistoryInline, gemini.HistoryExternal}, historyFormat) {
+		f.Error("generate", "INVALID_HISTORY_FORMAT", fmt.Sprintf("Invalid history format: %s", historyFormat), "Valid formats: inline, external")
+		return fmt.Errorf("invalid history format")
+	}
+
+	if err := validateOutputFormat(outputPath); err != nil {
+		f.Error("generate", "UNSUPPORTED_FORMAT", err.Error(), outputFormatHint)
+		return err
+	}
+	if err := validateQuality(quality); err != nil {
+		f.Error("generate", "INVALID_QUALITY", err.Error(), "")
+		return err
+	}
+
 	if noOverwrite {
 		if _, err := os.Stat(outputPath); err == nil {
 			f.Error("generate", "FILE_EXISTS", fmt.Sprintf("Output file already exists: %s", outputPath), "Use a different output path or remove --no-overwrite flag")
@@ -166,7 +289,10 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	for _, inputPath := range inputPaths {
+	for _, inputPath := range inputs {
+		if !gemini.IsFileInput(inputPath) {
+			continue
+		}
 		if _, err := os.Stat(inputPath); os.IsNotExist(err) {
 			f.Error("generate", "FILE_NOT_FOUND", fmt.Sprintf("Input file not found: %s", inputPath), "")
 			return err
@@ -182,85 +308,85 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	client, err := gemini.NewClient(apiKey, GetModel(), 3*time.Minute)
+	client, err := newGeminiClient(apiKey, 3*time.Minute)
 	if err != nil {
-		f.Error("generate", "CLIENT_ERROR", err.Error(), "Check your API key")
+		f.Error("generate", "CLIENT_ERROR", err.Error(), clientErrorHint())
 		return err
 	}
 	modelInfo := client.Model()
 
 	options := &gemini.GenerateOptions{
-		AspectRatio:     aspectRatio,
-		ImageSize:       selectedImageSize,
-		Count:           count,
-		InputPaths:      inputPaths,
-		GroundWeb:       groundWeb,
-		GroundImage:     groundImage,
-		IncludeThoughts: includeThoughts,
-		ThinkingLevel:   thinkingLevel,
-		History:         history,
+		AspectRatio:       aspectRatio,
+		ImageSize:         selectedImageSize,
+		Count:             count,
+		InputPaths:        inputs,
+		FitInputs:         fitInputs,
+		UploadInputs:      uploadInputs,
+		GroundWeb:         groundWeb,
+		GroundImage:       groundImage,
+		IncludeThoughts:   includeThoughts,
+		ThinkingLevel:     thinkingLevel,
+		History:           history,
+		Concurrency:       concurrency,
+		SystemInstruction: system,
+		Sampling:          sampling,
+	}
+	if streamOutput {
+		f.Stream = true
+		options.OnEvent = streamEventPrinter(f)
 	}
 
 	f.Progress("Generating image with %s...", modelInfo.Spec.ID)
 
 	result, err := client.Generate(context.Background(), prompt, options)
 	if err != nil {
-		if geminiErr, ok := err.(*gemini.GeminiError); ok {
-			hint := ""
-			switch geminiErr.Code {
-			case gemini.ErrInvalidAPIKey:
-				hint = "Check your API key at https://aistudio.google.com/apikey"
-			case gemini.ErrQuotaExceeded:
-				hint = "Wait before retrying or check your quota"
-			case gemini.ErrSafetyBlocked:
-				hint = "Try rephrasing your prompt"
-			}
-			f.Error("generate", geminiErr.Code, geminiErr.Message, hint)
-		} else {
-			f.Error("generate", "GENERATION_FAILED", err.Error(), "")
-		}
+		reportGenerateError("generate", err)
 		return err
 	}
+	reportInputAdjustments(result)
 
-	var imageResults []output.ImageResult
-	for i, img := range result.Images {
-		savePath := outputPath
-		if len(result.Images) > 1 {
-			ext := filepath.Ext(outputPath)
-			base := strings.TrimSuffix(outputPath, ext)
-			savePath = fmt.Sprintf("%s_%d%s", base, i+1, ext)
-		}
-
-		if err := client.SaveImage(img, savePath); err != nil {
-			f.Error("generate", "SAVE_FAILED", err.Error(), "")
-			return err
-		}
-
-		f.ImageSaved(savePath, img.Width, img.Height)
-		imageResults = append(imageResults, output.ImageResult{
-			Path:   savePath,
-			Format: strings.TrimPrefix(img.MimeType, "image/"),
-			Size:   &output.ImageSize{Width: img.Width, Height: img.Height},
-		})
+	provenanceOptions := generateProvenanceOptions(modelInfo.Spec, options)
+	if p != nil {
+		provenanceOptions["preset"] = p.Name
+	}
+	if historyIn != "" {
+		provenanceOptions["history_in"] = historyIn
+	}
+	rec := newProvenance("generate", prompt, result.Model, provenanceOptions, inputs, result.InputHashes)
+	imageResults, err := saveGeneratedImages(client, result, outputPath, max(count, candidateCount), quality, rec)
+	if err != nil {
+		f.Error("generate", "SAVE_FAILED", err.Error(), "")
+		return err
+	}
+	for _, img := range imageResults {
+		f.ImageSaved(img.Path, img.Size.Width, img.Size.Height)
 	}
 
 	var thoughtResults []output.ImageResult
 	if includeThoughts && thoughtsDir != "" {
 		for i, thought := range result.Thoughts {
 			thoughtPath := filepath.Join(thoughtsDir, fmt.Sprintf("thought_%02d%s", i+1, extensionForMime(thought.MimeType)))
-			if err := client.SaveImage(thought, thoughtPath); err != nil {
+			format, err := client.SaveImage(thought, thoughtPath, 0)
+			if err != nil {
 				f.Error("generate", "THOUGHT_SAVE_FAILED", err.Error(), "")
 				return err
 			}
 			thoughtResults = append(thoughtResults, output.ImageResult{
 				Path:   thoughtPath,
-				Format: strings.TrimPrefix(thought.MimeType, "image/"),
+				Format: format,
 				Size:   &output.ImageSize{Width: thought.Width, Height: thought.Height},
 			})
 		}
 	}
 
+	for _, cerr := range result.Errors {
+		f.Progress("Candidate %d failed: %v", cerr.Index+1, cerr.Err)
+	}
+
 	if historyOut != "" {
+		if historyFormat != "" && result.History != nil {
+			result.History.Format = historyFormat
+		}
 		if err := client.SaveHistory(result.History, historyOut); err != nil {
 			f.Error("generate", "HISTORY_SAVE_FAILED", err.Error(), "")
 			return err
@@ -269,7 +395,7 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	}
 
 	for _, text := range result.Texts {
-		if !text.Thought && strings.TrimSpace(text.Text) != "" {
+		if !streamOutput && !text.Thought && strings.TrimSpace(text.Text) != "" {
 			f.Info(strings.TrimSpace(text.Text))
 		}
 	}
@@ -283,30 +409,206 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	timing := &output.Timing{TotalMs: time.Since(startTime).Milliseconds()}
-	data := map[string]interface{}{
-		"prompt":             prompt,
-		"model":              result.Model,
-		"input_images":       inputPaths,
-		"aspect_ratio":       aspectRatio,
-		"image_size":         gemini.DefaultImageSize(modelInfo.Spec, selectedImageSize),
-		"images":             imageResults,
-		"grounding_metadata": result.Grounding,
-	}
-	if len(result.Texts) > 0 {
-		data["parts"] = result.Texts
+	timing := &output.Timing{
+		TotalMs:  time.Since(startTime).Milliseconds(),
+		Attempts: result.Attempts,
+		Retries:  result.Retries,
 	}
+	data := generateResultData(prompt, modelInfo.Spec, options, result, imageResults)
 	if len(thoughtResults) > 0 {
 		data["thought_images"] = thoughtResults
 	}
 	if historyOut != "" {
 		data["history_file"] = historyOut
 	}
+	if p != nil {
+		data["preset"] = p.Name
+	}
+	if writeManifest {
+		// Record the config's safety defaults as well as the flags, so that
+		// regenerate does not depend on the config at that time. Both were
+		// checked when the client was created.
+		safety, _ := configuredSafety(loadConfig())
+		job := batchJob{
+			Prompt:          prompt,
+			Output:          outputPath,
+			Inputs:          inputs,
+			FitInputs:       fitInputs,
+			UploadInputs:    uploadInputs,
+			Quality:         quality,
+			Model:           modelInfo.Spec.ID,
+			AspectRatio:     aspectRatio,
+			ImageSize:       selectedImageSize,
+			Count:           count,
+			ThinkingLevel:   thinkingLevel,
+			System:          system,
+			Temperature:     sampling.Temperature,
+			TopP:            sampling.TopP,
+			TopK:            sampling.TopK,
+			Seed:            sampling.Seed,
+			CandidateCount:  sampling.CandidateCount,
+			Safety:          safetyMap(safety),
+			IncludeThoughts: includeThoughts,
+			GroundWeb:       groundWeb,
+			GroundImage:     groundImage,
+		}
+		manifest, err := writeGenerateManifest(job, result.InputHashes, imageResults)
+		if err != nil {
+			f.Error("generate", "SAVE_FAILED", err.Error(), "")
+			return err
+		}
+		f.Info("Saved manifest: %s", manifest)
+		data["manifest"] = manifest
+	}
 
+	recordUsage("generate", result)
 	f.Success("generate", data, timing)
 	return nil
 }
 
+// streamEventPrinter reports stream events as NDJSON lines in JSON mode and as
+// dimmed progress lines otherwise. Candidates are numbered from 1.
+func streamEventPrinter(f *output.Formatter) func(gemini.StreamEvent) {
+	return func(event gemini.StreamEvent) {
+		fields := map[string]any{"candidate": event.Candidate + 1}
+		var message string
+		switch {
+		case event.Type == gemini.EventDone:
+			fields["finish_reason"] = event.FinishReason
+			message = fmt.Sprintf("Candidate %d finished (%s)", event.Candidate+1, event.FinishReason)
+		case event.Image != nil:
+			fields["mime_type"] = event.Image.MimeType
+			fields["width"] = event.Image.Width
+			fields["height"] = event.Image.Height
+			fields["bytes"] = len(event.Image.Data)
+			message = fmt.Sprintf("Received image %dx%d", event.Image.Width, event.Image.Height)
+			if event.Type == gemini.EventThought {
+				message = fmt.Sprintf("Thought image %dx%d", event.Image.Width, event.Image.Height)
+			}
+		default:
+			fields["text"] = event.Text
+			message = strings.TrimSpace(event.Text)
+			if event.Type == gemini.EventThought {
+				message = "Thinking: " + message
+			}
+		}
+		f.Event(event.Type, fields, message)
+	}
+}
+
+// saveGeneratedImages writes every final image from result next to outputPath,
+// converted to the format of its extension, with rec embedded unless it is nil.
+func saveGeneratedImages(client *gemini.Client, result *gemini.GenerateResult, outputPath string, count, quality int, rec *provenance.Record) ([]output.ImageResult, error) {
+	var images []output.ImageResult
+	savePaths := imageOutputPaths(outputPath, result.Images, count)
+	for i, img := range result.Images {
+		format, err := client.SaveImage(img, savePaths[i], quality)
+		if err != nil {
+			return nil, err
+		}
+		imgRec := rec
+		if rec != nil && count > 1 {
+			imgRec = candidateProvenance(rec, img.Candidate)
+		}
+		embedProvenance(savePaths[i], imgRec)
+		images = append(images, output.ImageResult{
+			Path:   savePaths[i],
+			Format: firstNonEmpty(format, strings.TrimPrefix(img.MimeType, "image/")),
+			Size:   &output.ImageSize{Width: img.Width, Height: img.Height},
+		})
+	}
+	return images, nil
+}
+
+// inputLabels shortens data URIs among inputs so they don't flood the output.
+func inputLabels(inputs []string) []string {
+	labels := make([]string, len(inputs))
+	for i, input := range inputs {
+		labels[i] = gemini.InputLabel(input)
+	}
+	return labels
+}
+
+// reportInputAdjustments notes each reference image that --fit-inputs
+// re-encoded.
+func reportInputAdjustments(result *gemini.GenerateResult) {
+	f := GetFormatter()
+	for _, a := range result.InputAdjustments {
+		f.Progress("Downscaled %s from %dx%d (%s) to %dx%d %s (%s) to fit the request size limit",
+			a.Input, a.OriginalWidth, a.OriginalHeight, formatBytes(int64(a.OriginalBytes)),
+			a.Width, a.Height, strings.TrimPrefix(a.MimeType, "image/"), formatBytes(int64(a.Bytes)))
+	}
+}
+
+// generateResultData builds the JSON data of a generate response. Batch job
+// results use the same shape.
+func generateResultData(prompt string, spec gemini.ModelSpec, opts *gemini.GenerateOptions, result *gemini.GenerateResult, images []output.ImageResult) map[string]any {
+	data := map[string]any{
+		"prompt":             prompt,
+		"model":              result.Model,
+		"input_images":       inputLabels(opts.InputPaths),
+		"aspect_ratio":       opts.AspectRatio,
+		"image_size":         gemini.DefaultImageSize(spec, opts.ImageSize),
+		"images":             images,
+		"grounding_metadata": result.Grounding,
+	}
+	if opts.SystemInstruction != "" {
+		data["system_instruction"] = opts.SystemInstruction
+	}
+	if !opts.Sampling.IsZero() {
+		data["sampling"] = opts.Sampling
+	}
+	if len(result.InputAdjustments) > 0 {
+		data["input_adjustments"] = result.InputAdjustments
+	}
+	if len(result.Texts) > 0 {
+		data["parts"] = result.Texts
+	}
+	if result.Usage != nil {
+		data["usage"] = result.Usage
+		data["estimated_cost_usd"] = result.EstimatedCostUSD
+	}
+	if result.FinishReason != "" {
+		data["finish_reason"] = result.FinishReason
+	}
+	if len(result.SafetyRatings) > 0 {
+		data["safety_ratings"] = result.SafetyRatings
+	}
+	if result.PromptFeedback != nil {
+		data["prompt_feedback"] = result.PromptFeedback
+	}
+	if len(result.Errors) > 0 {
+		data["failed_candidates"] = candidateErrors(result.Errors)
+	}
+	return data
+}
+
+// samplingFromFlags collects the sampling flags that were given; the rest keep
+// the model's defaults.
+func samplingFromFlags(cmd *cobra.Command) gemini.Sampling {
+	var s gemini.Sampling
+	if cmd.Flags().Changed("temperature") {
+		v := temperature
+		s.Temperature = &v
+	}
+	if cmd.Flags().Changed("top-p") {
+		v := topP
+		s.TopP = &v
+	}
+	if cmd.Flags().Changed("top-k") {
+		v := topK
+		s.TopK = &v
+	}
+	if cmd.Flags().Changed("seed") {
+		v := seed
+		s.Seed = &v
+	}
+	if candidateCount != 1 {
+		s.CandidateCount = candidateCount
+	}
+	return s
+}
+
 func getPrompt(args []string) (string, error) {
 	if promptFile != "" {
 		data, err := os.ReadFile(promptFile)
@@ -362,6 +664,75 @@ func extensionForMime(mime string) string {
 	}
 }
 
+// outputFormatHint lists the output extensions validateOutputFormat accepts.
+const outputFormatHint = "Use a .png, .jpg, .gif, .tiff, .bmp or .webp output path"
+
+// addQualityFlag registers --quality.
+func addQualityFlag(cmd *cobra.Command, target *int) {
+	cmd.Flags().IntVar(target, "quality", 0, fmt.Sprintf("JPEG quality 1-100 for .jpg output (0 uses the default, %d)", image.DefaultJPEGQuality))
+}
+
+// validateQuality checks a --quality value; 0 means the default.
+func validateQuality(quality int) error {
+	if quality < 0 || quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100, or 0 for the default (%d)", image.DefaultJPEGQuality)
+	}
+	return nil
+}
+
+// validateOutputFormat rejects an output path whose format cannot be written,
+// before any API call is made.
+func validateOutputFormat(path string) error {
+	_, err := image.FormatForPath(path)
+	return err
+}
+
+// imageOutputPaths names each image. With --count above 1 the suffix is the
+// candidate number, so a failed candidate leaves a gap instead of shifting names.
+func imageOutputPaths(outputPath string, images []*gemini.GeneratedImage, count int) []string {
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+
+	perCandidate := map[int]int{}
+	for _, img := range images {
+		perCandidate[img.Candidate]++
+	}
+
+	paths := make([]string, len(images))
+	seen := map[int]int{}
+	for i, img := range images {
+		switch {
+		case count > 1 && perCandidate[img.Candidate] > 1:
+			seen[img.Candidate]++
+			paths[i] = fmt.Sprintf("%s_%d_%d%s", base, img.Candidate+1, seen[img.Candidate], ext)
+		case count > 1:
+			paths[i] = fmt.Sprintf("%s_%d%s", base, img.Candidate+1, ext)
+		case len(images) > 1:
+			paths[i] = fmt.Sprintf("%s_%d%s", base, i+1, ext)
+		default:
+			paths[i] = outputPath
+		}
+	}
+	return paths
+}
+
+func candidateErrors(errs []gemini.CandidateError) []map[string]any {
+	out := make([]map[string]any, 0, len(errs))
+	for _, cerr := range errs {
+		entry := map[string]any{"candidate": cerr.Index + 1, "message": cerr.Err.Error()}
+		var geminiErr *gemini.GeminiError
+		if errors.As(cerr.Err, &geminiErr) {
+			entry["code"] = geminiErr.Code
+			entry["message"] = geminiErr.Message
+			if details := geminiErr.DetailMap(); details != nil {
+				entry["details"] = details
+			}
+		}
+		out = append(out, entry)
+	}
+	return out
+}
+
 func dedupeGroundingSources(in []gemini.GroundingSource) []gemini.GroundingSource {
 	seen := map[string]bool{}
 	var out []gemini.GroundingSource
@@ -0,0 +1,379 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// History command flags
+	historyOutput string
+	historyForkAt int
+	historyTo     string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Inspect and edit conversation history files",
+	Long: `Inspect and edit the JSON history files written by generate --history-out
+and by sessions.
+
+A turn is one user prompt (with its reference images) and the model's reply.
+Histories are either inline, with images embedded as base64, or external, with
+images stored as content-addressed files in a <file>.blobs directory next to
+the history. Every command reads both formats.
+
+EXAMPLES:
+  nanobanana history show photo-history.json
+  nanobanana history turns photo-history.json
+  nanobanana history rewind photo-history.json 2
+  nanobanana history fork photo-history.json --at 3 -o photo-v2.json
+  nanobanana history extract photo-history.json -o photo-images
+  nanobanana history migrate photo-history.json --to external`,
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <file>",
+	Short: "Summarize a history file",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runHistoryShow,
+}
+
+var historyTurnsCmd = &cobra.Command{
+	Use:   "turns <file>",
+	Short: "List turns with their prompts and replies",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runHistoryTurns,
+}
+
+var historyRewindCmd = &cobra.Command{
+	Use:   "rewind <file> [N]",
+	Short: "Drop the last N turns (default 1)",
+	Long: `Drop the last N turns (default 1) so the next generate --history-in
+continues from an earlier result. The file is rewritten in place unless -o is
+given.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runHistoryRewind,
+}
+
+var historyForkCmd = &cobra.Command{
+	Use:   "fork <file>",
+	Short: "Copy a history up to a given turn",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runHistoryFork,
+}
+
+var historyExtractCmd = &cobra.Command{
+	Use:   "extract <file>",
+	Short: "Write every embedded image to a directory",
+	Long: `Write every image embedded in a history to a directory, named by turn and
+role: turn01_input_1.png for reference images, turn01_model_1.png for
+generated images and turn01_thought_1.png for thought images.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistoryExtract,
+}
+
+var historyMigrateCmd = &cobra.Command{
+	Use:   "migrate <file>",
+	Short: "Convert a history between inline and external images",
+	Long: `Convert a history between the inline format, with images embedded as
+base64, and the external format, with images stored as files in <file>.blobs
+named by their SHA-256. The file is rewritten in place unless -o is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistoryMigrate,
+}
+
+func init() {
+	historyRewindCmd.Flags().StringVarP(&historyOutput, "output", "o", "", "Write the result here instead of in place")
+	historyForkCmd.Flags().StringVarP(&historyOutput, "output", "o", "", "Output history file (required)")
+	historyForkCmd.Flags().IntVar(&historyForkAt, "at", 0, "Last turn to keep (default: all)")
+	historyForkCmd.MarkFlagRequired("output")
+	historyExtractCmd.Flags().StringVarP(&historyOutput, "output", "o", "", "Output directory (default <file>-images)")
+	historyMigrateCmd.Flags().StringVarP(&historyOutput, "output", "o", "", "Write the result here instead of in place")
+	historyMigrateCmd.Flags().StringVar(&historyTo, "to", "", "Target format: inline or external (required)")
+	historyMigrateCmd.MarkFlagRequired("to")
+
+	historyCmd.AddCommand(historyShowCmd, historyTurnsCmd, historyRewindCmd, historyForkCmd, historyExtractCmd, historyMigrateCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+// loadHistoryArg loads a history file, reporting failures as INVALID_HISTORY.
+func loadHistoryArg(path string) (*gemini.ConversationHistory, error) {
+	history, err := gemini.LoadHistory(path)
+	if err != nil {
+		GetFormatter().Error("history", "INVALID_HISTORY", err.Error(), "Pass a file written by generate --history-out")
+		return nil, err
+	}
+	return history, nil
+}
+
+func runHistoryShow(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	history, err := loadHistoryArg(args[0])
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(args[0])
+	if err != nil {
+		f.Error("history", "FILE_NOT_FOUND", err.Error(), "")
+		return err
+	}
+
+	counts := map[string]int{}
+	imageBytes := 0
+	for _, img := range history.Images() {
+		switch {
+		case img.Thought:
+			counts["thought"]++
+		case img.Role == "user":
+			counts["input"]++
+		default:
+			counts["model"]++
+		}
+		imageBytes += len(img.Data)
+	}
+	turns := history.Turns()
+	format := firstNonEmpty(history.Format, gemini.HistoryInline)
+
+	f.Info("File:           %s (%s)", args[0], formatBytes(stat.Size()))
+	f.Info("Format:         %s", format)
+	f.Info("Model:          %s", history.Model)
+	f.Info("Turns:          %d", len(turns))
+	f.Info("Images:         %d generated, %d reference, %d thought (%s)", counts["model"], counts["input"], counts["thought"], formatBytes(int64(imageBytes)))
+	if len(turns) > 0 {
+		f.Info("Last prompt:    %s", turns[len(turns)-1].Prompt)
+	}
+
+	if history.Sampling != nil && history.Sampling.Seed != nil {
+		f.Info("Last seed:      %d", *history.Sampling.Seed)
+	}
+
+	data := map[string]any{
+		"file":           args[0],
+		"model":          history.Model,
+		"format":         format,
+		"turns":          len(turns),
+		"contents":       len(history.Contents),
+		"images":         counts["model"],
+		"input_images":   counts["input"],
+		"thought_images": counts["thought"],
+		"image_bytes":    imageBytes,
+		"file_bytes":     stat.Size(),
+	}
+	if history.Sampling != nil {
+		data["sampling"] = history.Sampling
+	}
+	f.Success("history", data, nil)
+	return nil
+}
+
+func runHistoryTurns(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	history, err := loadHistoryArg(args[0])
+	if err != nil {
+		return err
+	}
+
+	turns := history.Turns()
+	for _, turn := range turns {
+		f.Info("%2d  user:  %s%s", turn.Turn, turn.Prompt, plural(turn.InputImages, " (+%d image)", " (+%d images)"))
+		reply := plural(turn.Images, "%d image", "%d images")
+		switch {
+		case !turn.Answered:
+			reply = "(no reply)"
+		case turn.Images == 0:
+			reply = "no image"
+		}
+		if len(turn.Texts) > 0 {
+			reply = strings.TrimSpace(reply + "  " + strings.Join(turn.Texts, " "))
+		}
+		f.Info("    model: %s", reply)
+	}
+
+	f.Success("history", map[string]any{
+		"file":  args[0],
+		"model": history.Model,
+		"turns": turns,
+	}, nil)
+	return nil
+}
+
+func runHistoryRewind(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	drop := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			f.Error("history", "INVALID_TURN", fmt.Sprintf("Invalid turn count: %s", args[1]), "Pass a positive number of turns to drop")
+			return fmt.Errorf("invalid turn count")
+		}
+		drop = n
+	}
+
+	history, err := loadHistoryArg(args[0])
+	if err != nil {
+		return err
+	}
+	total := len(history.Turns())
+	if drop >= total {
+		f.Error("history", "INVALID_TURN", fmt.Sprintf("Cannot drop %d of %d turns", drop, total), "A history must keep at least one turn")
+		return fmt.Errorf("invalid turn count")
+	}
+
+	out := firstNonEmpty(historyOutput, args[0])
+	return writeTruncatedHistory(history, total-drop, args[0], out)
+}
+
+func runHistoryFork(cmd *cobra.Command, args []string) error {
+	history, err := loadHistoryArg(args[0])
+	if err != nil {
+		return err
+	}
+	keep := historyForkAt
+	if keep == 0 {
+		keep = len(history.Turns())
+	}
+	return writeTruncatedHistory(history, keep, args[0], historyOutput)
+}
+
+func writeTruncatedHistory(history *gemini.ConversationHistory, keep int, in, out string) error {
+	f := GetFormatter()
+	truncated, err := history.Truncate(keep)
+	if err != nil {
+		f.Error("history", "INVALID_TURN", err.Error(), "See: nanobanana history turns "+in)
+		return err
+	}
+	if err := truncated.Save(out); err != nil {
+		f.Error("history", "HISTORY_SAVE_FAILED", err.Error(), "")
+		return err
+	}
+
+	f.Info("Wrote %d turns to %s", keep, out)
+	f.Success("history", map[string]any{
+		"file":    in,
+		"output":  out,
+		"turns":   keep,
+		"removed": len(history.Turns()) - keep,
+	}, nil)
+	return nil
+}
+
+func runHistoryExtract(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	history, err := loadHistoryArg(args[0])
+	if err != nil {
+		return err
+	}
+
+	dir := historyOutput
+	if dir == "" {
+		dir = strings.TrimSuffix(args[0], filepath.Ext(args[0])) + "-images"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		f.Error("history", "SAVE_FAILED", err.Error(), "")
+		return err
+	}
+
+	var images []map[string]any
+	for _, img := range history.Images() {
+		kind := "model"
+		switch {
+		case img.Thought:
+			kind = "thought"
+		case img.Role == "user":
+			kind = "input"
+		}
+		path := filepath.Join(dir, fmt.Sprintf("turn%02d_%s_%d%s", img.Turn, kind, img.Index, extensionForMime(img.MimeType)))
+		if err := os.WriteFile(path, img.Data, 0644); err != nil {
+			f.Error("history", "SAVE_FAILED", err.Error(), "")
+			return err
+		}
+		f.Info("  %s", path)
+		images = append(images, map[string]any{
+			"path":   path,
+			"turn":   img.Turn,
+			"kind":   kind,
+			"format": strings.TrimPrefix(img.MimeType, "image/"),
+			"bytes":  len(img.Data),
+		})
+	}
+
+	f.Info("Extracted %d images to %s", len(images), dir)
+	f.Success("history", map[string]any{
+		"file":      args[0],
+		"directory": dir,
+		"images":    images,
+	}, nil)
+	return nil
+}
+
+func runHistoryMigrate(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	if historyTo != gemini.HistoryInline && historyTo != gemini.HistoryExternal {
+		f.Error("history", "INVALID_HISTORY_FORMAT", fmt.Sprintf("Invalid history format: %s", historyTo), "Valid formats: inline, external")
+		return fmt.Errorf("invalid history format")
+	}
+	history, err := loadHistoryArg(args[0])
+	if err != nil {
+		return err
+	}
+
+	from := firstNonEmpty(history.Format, gemini.HistoryInline)
+	out := firstNonEmpty(historyOutput, args[0])
+	history.Format = historyTo
+	if err := history.Save(out); err != nil {
+		f.Error("history", "HISTORY_SAVE_FAILED", err.Error(), "")
+		return err
+	}
+	stat, err := os.Stat(out)
+	if err != nil {
+		f.Error("history", "HISTORY_SAVE_FAILED", err.Error(), "")
+		return err
+	}
+
+	data := map[string]any{
+		"file":       args[0],
+		"output":     out,
+		"from":       from,
+		"to":         historyTo,
+		"images":     len(history.Images()),
+		"file_bytes": stat.Size(),
+	}
+	if historyTo == gemini.HistoryExternal {
+		data["blob_dir"] = gemini.BlobDir(out)
+		f.Info("Wrote %s (%s) with images in %s", out, formatBytes(stat.Size()), gemini.BlobDir(out))
+	} else {
+		f.Info("Wrote %s (%s) with inline images", out, formatBytes(stat.Size()))
+	}
+	f.Success("history", data, nil)
+	return nil
+}
+
+func plural(n int, one, many string) string {
+	switch n {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf(one, n)
+	default:
+		return fmt.Sprintf(many, n)
+	}
+}
+
+// formatBytes renders a byte count as B, KB or MB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -21,6 +21,7 @@ var (
 	iconSizes      []int
 	iconStyle      string
 	iconBackground string
+	iconQuality    int
 )
 
 var iconCmd = &cobra.Command{
@@ -52,7 +53,9 @@ SIZES:
 
 OUTPUT:
   If output is a directory, files are named: icon_<size>.png
-  If output is a file pattern with {size}, it's replaced with the size
+  If output is a file pattern with {size}, it's replaced with the size, and
+  icons are saved in the format of its extension (.png, .jpg, .gif, .tiff,
+  .bmp or .webp). JPEG cannot store a transparent background.
 
 EXAMPLES:
   # Generate icons in default sizes
@@ -65,7 +68,10 @@ EXAMPLES:
   nanobanana icon "play button" -o ./icons/ --style flat --background white
 
   # Custom naming pattern
-  nanobanana icon "app logo" -o ./icons/myapp_{size}.png --sizes 128,256,512`,
+  nanobanana icon "app logo" -o ./icons/myapp_{size}.png --sizes 128,256,512
+
+  # Apply a saved preset's system instruction, model and references
+  nanobanana icon "settings gear" -o ./icons/ --preset brand-icons`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runIcon,
 }
@@ -75,6 +81,11 @@ func init() {
 	iconCmd.Flags().IntSliceVar(&iconSizes, "sizes", []int{64, 128, 256, 512}, "Icon sizes in pixels")
 	iconCmd.Flags().StringVar(&iconStyle, "style", "modern", "Style: modern, flat, minimal, detailed")
 	iconCmd.Flags().StringVar(&iconBackground, "background", "transparent", "Background: transparent, white, black, or #RRGGBB")
+	addQualityFlag(iconCmd, &iconQuality)
+	addMetadataFlag(iconCmd)
+
+	addPresetFlag(iconCmd)
+	addGeminiFlags(iconCmd)
 
 	iconCmd.MarkFlagRequired("output")
 
@@ -88,12 +99,23 @@ func runIcon(cmd *cobra.Command, args []string) error {
 
 	// Validate API key
 	apiKey := GetAPIKey()
-	if apiKey == "" {
+	if apiKey == "" && !usingVertex() {
 		f.Error("icon", "MISSING_API_KEY", "No API key provided",
 			"Set GEMINI_API_KEY environment variable or use --api-key flag")
 		return fmt.Errorf("missing API key")
 	}
 
+	p, err := loadPreset(cmd, "icon")
+	if err != nil {
+		return err
+	}
+	presetOpts := &gemini.GenerateOptions{}
+	if p != nil {
+		presetOpts.SystemInstruction = p.System
+		presetOpts.ImageSize = p.ImageSize
+		presetOpts.InputPaths = p.ReferencePaths()
+	}
+
 	// Validate sizes
 	for _, size := range iconSizes {
 		if size < 16 || size > 2048 {
@@ -120,12 +142,27 @@ func runIcon(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid style")
 	}
 
+	if strings.Contains(iconOutput, "{size}") {
+		if err := validateOutputFormat(iconOutput); err != nil {
+			f.Error("icon", "UNSUPPORTED_FORMAT", err.Error(), outputFormatHint)
+			return err
+		}
+		if format, _ := image.FormatForPath(iconOutput); format == "jpeg" && iconBackground == "transparent" {
+			f.Error("icon", "UNSUPPORTED_FORMAT", "JPEG cannot store a transparent background", "Use a .png pattern or pass --background white")
+			return fmt.Errorf("unsupported format")
+		}
+	}
+	if err := validateQuality(iconQuality); err != nil {
+		f.Error("icon", "INVALID_QUALITY", err.Error(), "")
+		return err
+	}
+
 	// Build enhanced prompt for icon generation
 	enhancedPrompt := buildIconPrompt(prompt, iconStyle, iconBackground)
 
-	client, err := gemini.NewClient(apiKey, GetModel(), 2*time.Minute)
+	client, err := newGeminiClient(apiKey, 2*time.Minute)
 	if err != nil {
-		f.Error("icon", "CLIENT_ERROR", err.Error(), "Check your API key")
+		f.Error("icon", "CLIENT_ERROR", err.Error(), clientErrorHint())
 		return err
 	}
 	modelInfo := client.Model()
@@ -141,16 +178,16 @@ func runIcon(cmd *cobra.Command, args []string) error {
 	f.Progress("Generating base icon with %s...", modelInfo.Spec.ID)
 
 	ctx := context.Background()
-	result, err := client.Generate(ctx, enhancedPrompt, &gemini.GenerateOptions{
-		AspectRatio: "1:1",
-		Count:       1,
-	})
+	genOpts := &gemini.GenerateOptions{
+		AspectRatio:       "1:1",
+		ImageSize:         presetOpts.ImageSize,
+		Count:             1,
+		InputPaths:        presetOpts.InputPaths,
+		SystemInstruction: presetOpts.SystemInstruction,
+	}
+	result, err := client.Generate(ctx, enhancedPrompt, genOpts)
 	if err != nil {
-		if geminiErr, ok := err.(*gemini.GeminiError); ok {
-			f.Error("icon", geminiErr.Code, geminiErr.Message, "")
-		} else {
-			f.Error("icon", "GENERATION_FAILED", err.Error(), "")
-		}
+		reportGenerateError("icon", err)
 		return err
 	}
 
@@ -185,12 +222,21 @@ func runIcon(cmd *cobra.Command, args []string) error {
 
 	// Save base image to temp location
 	tempFile := filepath.Join(outputDir, "_temp_base.png")
-	if err := client.SaveImage(result.Images[0], tempFile); err != nil {
+	if _, err := client.SaveImage(result.Images[0], tempFile, 0); err != nil {
 		f.Error("icon", "SAVE_FAILED", err.Error(), "")
 		return err
 	}
 	defer os.Remove(tempFile)
 
+	provenanceOptions := generateProvenanceOptions(modelInfo.Spec, genOpts)
+	provenanceOptions["style"] = iconStyle
+	provenanceOptions["background"] = iconBackground
+	provenanceOptions["sizes"] = iconSizes
+	if p != nil {
+		provenanceOptions["preset"] = p.Name
+	}
+	rec := newProvenance("icon", prompt, result.Model, provenanceOptions, genOpts.InputPaths, result.InputHashes)
+
 	// Generate all sizes
 	var results []output.ImageResult
 	for _, size := range iconSizes {
@@ -204,19 +250,21 @@ func runIcon(cmd *cobra.Command, args []string) error {
 
 		// Resize the base image
 		opts := &image.TransformOptions{
-			Resize: fmt.Sprintf("%dx%d", size, size),
-			Fit:    "cover",
+			Resize:  fmt.Sprintf("%dx%d", size, size),
+			Fit:     "cover",
+			Quality: iconQuality,
 		}
-		_, err := image.Transform(tempFile, outputPath, opts)
+		resized, err := image.Transform(tempFile, outputPath, opts)
 		if err != nil {
 			f.Error("icon", "RESIZE_FAILED", err.Error(), "")
 			return err
 		}
+		embedProvenance(outputPath, rec)
 
 		f.ImageSaved(outputPath, size, size)
 		results = append(results, output.ImageResult{
 			Path:   outputPath,
-			Format: "png",
+			Format: resized.Format,
 			Size:   &output.ImageSize{Width: size, Height: size},
 		})
 	}
@@ -224,7 +272,9 @@ func runIcon(cmd *cobra.Command, args []string) error {
 	// Output success
 	elapsed := time.Since(startTime)
 	timing := &output.Timing{
-		TotalMs: elapsed.Milliseconds(),
+		TotalMs:  elapsed.Milliseconds(),
+		Attempts: result.Attempts,
+		Retries:  result.Retries,
 	}
 
 	data := map[string]interface{}{
@@ -234,7 +284,16 @@ func runIcon(cmd *cobra.Command, args []string) error {
 		"sizes":  iconSizes,
 		"images": results,
 	}
+	if p != nil {
+		data["preset"] = p.Name
+	}
+
+	if result.Usage != nil {
+		data["usage"] = result.Usage
+		data["estimated_cost_usd"] = result.EstimatedCostUSD
+	}
 
+	recordUsage("icon", result)
 	f.Success("icon", data, timing)
 	return nil
 }
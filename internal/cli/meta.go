@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/provenance"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Shared by generate, icon and pattern
+	noMetadata bool
+)
+
+var metaCmd = &cobra.Command{
+	Use:   "meta",
+	Short: "Read the provenance embedded in generated images",
+	Long: `Read the provenance embedded in generated images.
+
+generate, icon and pattern record how each image was made: the prompt,
+model ID, options, a SHA-256 of every reference image, the time and the
+nanobanana version. PNGs carry it in tEXt/iTXt chunks (the record is the
+"nanobanana" iTXt chunk, with the prompt also under "Description"); JPEGs in
+an XMP packet. Other formats are saved without it. Pass --no-metadata to
+leave it out.
+
+EXAMPLES:
+  nanobanana meta show hero.png
+  nanobanana meta show hero.jpg --json`,
+}
+
+var metaShowCmd = &cobra.Command{
+	Use:   "show <file>",
+	Short: "Show how an image was generated",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runMetaShow,
+}
+
+func init() {
+	metaCmd.AddCommand(metaShowCmd)
+	rootCmd.AddCommand(metaCmd)
+}
+
+// addMetadataFlag registers --no-metadata.
+func addMetadataFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noMetadata, "no-metadata", false, "Do not embed the prompt, model and options in the output (see meta show)")
+}
+
+// newProvenance records a generation for embedding in its outputs. inputs
+// and hashes are the reference images and their SHA-256, in request order.
+func newProvenance(command, prompt, model string, options map[string]any, inputs, hashes []string) *provenance.Record {
+	rec := &provenance.Record{
+		Tool:      "nanobanana " + Version,
+		Command:   command,
+		Prompt:    prompt,
+		Model:     model,
+		Options:   options,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	for i, hash := range hashes {
+		name := ""
+		if i < len(inputs) {
+			name = gemini.InputLabel(inputs[i])
+		}
+		rec.Inputs = append(rec.Inputs, provenance.Input{Name: name, SHA256: hash})
+	}
+	return rec
+}
+
+// generateProvenanceOptions lists the GenerateOptions that shape an image.
+func generateProvenanceOptions(spec gemini.ModelSpec, opts *gemini.GenerateOptions) map[string]any {
+	options := map[string]any{
+		"aspect_ratio": opts.AspectRatio,
+		"image_size":   gemini.DefaultImageSize(spec, opts.ImageSize),
+	}
+	if opts.SystemInstruction != "" {
+		options["system_instruction"] = opts.SystemInstruction
+	}
+	if opts.ThinkingLevel != "" {
+		options["thinking_level"] = opts.ThinkingLevel
+	}
+	if opts.GroundWeb {
+		options["ground_web"] = true
+	}
+	if opts.GroundImage {
+		options["ground_image"] = true
+	}
+	if !opts.Sampling.IsZero() {
+		options["sampling"] = opts.Sampling
+	}
+	return options
+}
+
+// candidateProvenance is rec for one of several --count candidates, which
+// were sent the seed advanced by their index.
+func candidateProvenance(rec *provenance.Record, candidate int) *provenance.Record {
+	out := *rec
+	out.Options = maps.Clone(rec.Options)
+	out.Options["candidate"] = candidate + 1
+	if s, ok := out.Options["sampling"].(gemini.Sampling); ok && s.Seed != nil && s.CandidateCount <= 1 {
+		seed := *s.Seed + int32(candidate)
+		s.Seed = &seed
+		out.Options["sampling"] = s
+	}
+	return &out
+}
+
+// embedProvenance writes rec into the image at path unless --no-metadata was
+// given. The image is already saved, so formats that cannot carry the record
+// and failures to write it are only reported.
+func embedProvenance(path string, rec *provenance.Record) {
+	if noMetadata || rec == nil {
+		return
+	}
+	err := provenance.WriteFile(path, rec)
+	switch {
+	case errors.Is(err, provenance.ErrUnsupportedFormat):
+		GetFormatter().Progress("Not embedding provenance in %s: only PNG and JPEG can carry it", path)
+	case err != nil:
+		GetFormatter().Progress("Could not embed provenance in %s: %v", path, err)
+	}
+}
+
+func runMetaShow(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	path := args[0]
+	if _, err := os.Stat(path); err != nil {
+		f.Error("meta", "FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", path), "")
+		return err
+	}
+	meta, err := provenance.ReadFile(path)
+	switch {
+	case errors.Is(err, provenance.ErrUnsupportedFormat):
+		f.Error("meta", "UNSUPPORTED_FORMAT", err.Error(), "")
+		return err
+	case errors.Is(err, provenance.ErrNotFound):
+		f.Error("meta", "NO_METADATA", fmt.Sprintf("%s has no nanobanana provenance", path), "It was not generated by nanobanana, was saved with --no-metadata, or its metadata was stripped")
+		return err
+	case err != nil:
+		f.Error("meta", "INVALID_METADATA", err.Error(), "")
+		return err
+	}
+
+	rec := meta.Record
+	f.Info("File:      %s (%s)", path, meta.Format)
+	f.Info("Command:   %s", rec.Command)
+	f.Info("Model:     %s", rec.Model)
+	f.Info("Created:   %s by %s", rec.CreatedAt.Local().Format("2006-01-02 15:04:05"), rec.Tool)
+	f.Info("Prompt:    %s", rec.Prompt)
+	if len(rec.Options) > 0 {
+		f.Info("Options:")
+	}
+	for _, key := range slices.Sorted(maps.Keys(rec.Options)) {
+		f.Info("  %-16s %v", key+":", rec.Options[key])
+	}
+	for _, input := range rec.Inputs {
+		f.Info("Input:     %s (sha256 %s)", input.Name, input.SHA256)
+	}
+
+	data := map[string]any{
+		"file":       path,
+		"format":     meta.Format,
+		"tool":       rec.Tool,
+		"command":    rec.Command,
+		"prompt":     rec.Prompt,
+		"model":      rec.Model,
+		"options":    rec.Options,
+		"inputs":     rec.Inputs,
+		"created_at": rec.CreatedAt,
+	}
+	if len(meta.Text) > 0 {
+		data["text"] = meta.Text
+	}
+	f.Success("meta", data, nil)
+	return nil
+}
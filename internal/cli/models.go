@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	appconfig "github.com/lyalindotcom/nano-banana-cli/internal/config"
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Models command flags
+	modelsRefresh bool
+	modelsAll     bool
+)
+
+var aspectRatioPattern = regexp.MustCompile(`^[1-9][0-9]*:[1-9][0-9]*$`)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models [model]",
+	Short: "List known models and their capabilities",
+	Long: `List the models nanobanana knows about, with their aliases, aspect ratios,
+image sizes and features. Pass a model ID or alias to show just that model.
+
+Models come from three places, later ones winning:
+  builtin   Models shipped with this release
+  api       Models fetched from the API's models.list with --refresh and
+            cached in models.json in the config dir; their capabilities are
+            not known, so every option is allowed and the API decides.
+            Listed models without "image" in their ID are text-only and
+            rejected by generate and friends (shown with --all)
+  config    Models declared under "models" in the config file
+
+Once a model list has been cached, generate and friends reject model IDs that
+are neither listed nor declared, instead of failing at the API.
+
+Declare a new preview model, or narrow a known one, in config.yaml:
+
+  models:
+    gemini-4-flash-image-preview:
+      aliases: [banana4]
+      aspect_ratios: ["1:1", "16:9", "9:16"]
+      image_sizes: [1K, 2K]
+      default_image_size: 1K
+      grounding: true
+      image_search: false
+      thinking: true
+      thinking_level: false
+      max_input_images: 10
+      max_request_bytes: 20000000
+
+EXAMPLES:
+  nanobanana models
+  nanobanana models pro
+  nanobanana models --refresh
+  nanobanana models --refresh --all`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runModels,
+}
+
+func init() {
+	modelsCmd.Flags().BoolVar(&modelsRefresh, "refresh", false, "Fetch the model list from the API and cache it")
+	modelsCmd.Flags().BoolVar(&modelsAll, "all", false, "Include listed models that do not generate images")
+	addGeminiFlags(modelsCmd)
+	rootCmd.AddCommand(modelsCmd)
+}
+
+// modelCachePath is where models --refresh caches the API's model list.
+func modelCachePath() (string, error) {
+	dir, err := appconfig.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "models.json"), nil
+}
+
+// loadModelRegistry registers the cached model list, then the models
+// declared in config so that they take precedence.
+func loadModelRegistry() error {
+	gemini.ResetModels()
+	if path, err := modelCachePath(); err == nil {
+		if listing, err := gemini.LoadModelListing(path); err == nil {
+			gemini.RegisterListing(listing)
+		}
+	}
+	specs, err := configuredModels(loadConfig())
+	if err != nil {
+		return err
+	}
+	gemini.RegisterModels(specs...)
+	return nil
+}
+
+// configuredModels builds specs for the models declared in config, starting
+// from the known spec for the same ID.
+func configuredModels(cfg *appconfig.Config) ([]gemini.ModelSpec, error) {
+	var specs []gemini.ModelSpec
+	for _, id := range slices.Sorted(maps.Keys(cfg.Models)) {
+		m := cfg.Models[id]
+		base := gemini.ResolveModel(id)
+		if base.Known && !strings.EqualFold(base.Spec.ID, id) {
+			return nil, fmt.Errorf("models: %s is an alias of %s; declare models by their full ID", id, base.Spec.ID)
+		}
+
+		spec := base.Spec
+		spec.ID = id
+		spec.Source = gemini.SourceConfig
+		spec.DisplayName = firstNonEmpty(m.DisplayName, spec.DisplayName)
+		spec.Aliases = append(slices.Clone(spec.Aliases), m.Aliases...)
+		if len(m.AspectRatios) > 0 {
+			for _, ratio := range m.AspectRatios {
+				if !aspectRatioPattern.MatchString(ratio) {
+					return nil, fmt.Errorf("models: %s: invalid aspect ratio %q", id, ratio)
+				}
+			}
+			spec.SupportedAspectRatios = m.AspectRatios
+		}
+		if len(m.ImageSizes) > 0 {
+			spec.SupportedImageSizes = nil
+			for _, size := range m.ImageSizes {
+				size = strings.ToUpper(strings.TrimSpace(size))
+				if !gemini.IsValidImageSize(size) {
+					return nil, fmt.Errorf("models: %s: invalid image size %q (valid: %s)", id, size, strings.Join(gemini.ListAllImageSizes(), ", "))
+				}
+				spec.SupportedImageSizes = append(spec.SupportedImageSizes, size)
+			}
+			if !slices.Contains(spec.SupportedImageSizes, spec.DefaultImageSize) {
+				spec.DefaultImageSize = spec.SupportedImageSizes[0]
+			}
+		}
+		if m.DefaultImageSize != "" {
+			size := strings.ToUpper(strings.TrimSpace(m.DefaultImageSize))
+			if !slices.Contains(spec.SupportedImageSizes, size) {
+				return nil, fmt.Errorf("models: %s: default image size %s is not in its image sizes", id, size)
+			}
+			spec.DefaultImageSize = size
+		}
+		for _, flag := range []struct {
+			value *bool
+			field *bool
+		}{
+			{m.Grounding, &spec.SupportsGrounding},
+			{m.ImageSearch, &spec.SupportsImageSearch},
+			{m.Thinking, &spec.SupportsThinking},
+			{m.ThinkingLevel, &spec.SupportsThinkingLevel},
+		} {
+			if flag.value != nil {
+				*flag.field = *flag.value
+			}
+		}
+		if m.MaxInputImages > 0 {
+			spec.MaxInputImages = m.MaxInputImages
+		}
+		if m.MaxRequestBytes > 0 {
+			spec.MaxRequestBytes = m.MaxRequestBytes
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+func runModels(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	data := map[string]any{}
+
+	if modelsRefresh {
+		apiKey := GetAPIKey()
+		if apiKey == "" && !usingVertex() {
+			f.Error("models", "MISSING_API_KEY", "No API key provided", "Set GEMINI_API_KEY environment variable or use --api-key flag")
+			return fmt.Errorf("missing API key")
+		}
+		client, err := newGeminiClient(apiKey, time.Minute)
+		if err != nil {
+			f.Error("models", "CLIENT_ERROR", err.Error(), clientErrorHint())
+			return err
+		}
+		f.Progress("Fetching model list...")
+		listing, err := client.ListModels(context.Background())
+		if err != nil {
+			reportGenerateError("models", err)
+			return err
+		}
+		path, err := modelCachePath()
+		if err == nil {
+			err = listing.Save(path)
+		}
+		if err != nil {
+			f.Error("models", "SAVE_FAILED", err.Error(), "")
+			return err
+		}
+		if err := loadModelRegistry(); err != nil {
+			f.Error("models", "INVALID_MODEL_CONFIG", err.Error(), "Fix the models section of the config file")
+			return err
+		}
+		f.Info("Cached %d models in %s", len(listing.Models), path)
+		data["cache"] = path
+		data["refreshed_at"] = listing.RefreshedAt
+	}
+
+	if len(args) == 1 {
+		resolved := gemini.ResolveModel(args[0])
+		if !resolved.Known {
+			f.Error("models", "MODEL_NOT_FOUND", fmt.Sprintf("Unknown model: %s", args[0]), "Refresh the list with: nanobanana models --refresh")
+			return fmt.Errorf("unknown model")
+		}
+		spec := resolved.Spec
+		f.Info("ID:             %s", spec.ID)
+		f.Info("Name:           %s", firstNonEmpty(spec.DisplayName, "-"))
+		f.Info("Source:         %s", spec.Source)
+		f.Info("Aliases:        %s", firstNonEmpty(strings.Join(spec.Aliases, ", "), "-"))
+		f.Info("Aspect ratios:  %s", strings.Join(spec.SupportedAspectRatios, ", "))
+		f.Info("Image sizes:    %s (default %s)", strings.Join(spec.SupportedImageSizes, ", "), spec.DefaultImageSize)
+		f.Info("Input images:   up to %d, %s per request", spec.MaxInputImages, formatBytes(spec.MaxRequestBytes))
+		f.Info("Features:       %s", firstNonEmpty(strings.Join(modelFeatures(spec), ", "), "-"))
+		data["model"] = modelData(spec)
+		f.Success("models", data, nil)
+		return nil
+	}
+
+	var models []map[string]any
+	for _, spec := range gemini.Models() {
+		if !modelsAll && !gemini.IsImageModel(spec.ID) {
+			continue
+		}
+		f.Info("%-34s %-8s %-16s %s", spec.ID, spec.Source, firstNonEmpty(strings.Join(spec.Aliases, ","), "-"), strings.Join(spec.SupportedImageSizes, "/"))
+		models = append(models, modelData(spec))
+	}
+	if path, err := modelCachePath(); err == nil && !modelsRefresh {
+		if listing, err := gemini.LoadModelListing(path); err == nil {
+			data["refreshed_at"] = listing.RefreshedAt
+		} else if errors.Is(err, os.ErrNotExist) {
+			f.Info("Run nanobanana models --refresh to add models listed by the API")
+		}
+	}
+	data["models"] = models
+	f.Success("models", data, nil)
+	return nil
+}
+
+func modelFeatures(spec gemini.ModelSpec) []string {
+	var features []string
+	for _, feature := range []struct {
+		name string
+		ok   bool
+	}{
+		{"grounding", spec.SupportsGrounding},
+		{"image search", spec.SupportsImageSearch},
+		{"thoughts", spec.SupportsThinking},
+		{"thinking level", spec.SupportsThinkingLevel},
+	} {
+		if feature.ok {
+			features = append(features, feature.name)
+		}
+	}
+	return features
+}
+
+func modelData(spec gemini.ModelSpec) map[string]any {
+	return map[string]any{
+		"id":                 spec.ID,
+		"display_name":       spec.DisplayName,
+		"source":             spec.Source,
+		"aliases":            spec.Aliases,
+		"aspect_ratios":      spec.SupportedAspectRatios,
+		"image_sizes":        spec.SupportedImageSizes,
+		"default_image_size": spec.DefaultImageSize,
+		"grounding":          spec.SupportsGrounding,
+		"image_search":       spec.SupportsImageSearch,
+		"thinking":           spec.SupportsThinking,
+		"thinking_level":     spec.SupportsThinkingLevel,
+		"max_input_images":   spec.MaxInputImages,
+		"max_request_bytes":  spec.MaxRequestBytes,
+		"text_only":          spec.TextOnly,
+	}
+}
@@ -14,10 +14,11 @@ import (
 
 var (
 	// Pattern command flags
-	patternOutput string
-	patternSize   string
-	patternStyle  string
-	patternType   string
+	patternOutput  string
+	patternSize    string
+	patternStyle   string
+	patternType    string
+	patternQuality int
 )
 
 var patternCmd = &cobra.Command{
@@ -47,6 +48,10 @@ SIZE:
   Default: 512x512 pixels
   Format: WxH (e.g., 256x256, 1024x512)
 
+OUTPUT:
+  The pattern is saved in the format of the output extension (.png, .jpg,
+  .gif, .tiff, .bmp or .webp); --quality sets the JPEG quality.
+
 EXAMPLES:
   # Generate a seamless geometric pattern
   nanobanana pattern "hexagon grid" -o hex-pattern.png
@@ -57,8 +62,14 @@ EXAMPLES:
   # Floral wallpaper pattern
   nanobanana pattern "vintage roses" -o roses.png --type wallpaper --style floral
 
+  # JPEG texture for a web page background
+  nanobanana pattern "linen fabric" -o linen.jpg --type texture --quality 85
+
   # Large abstract pattern
-  nanobanana pattern "colorful waves" -o waves.png --size 1024x1024 --style abstract`,
+  nanobanana pattern "colorful waves" -o waves.png --size 1024x1024 --style abstract
+
+  # Apply a saved preset's system instruction, model and references
+  nanobanana pattern "brand confetti" -o confetti.png --preset brand-hero`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: runPattern,
 }
@@ -68,6 +79,11 @@ func init() {
 	patternCmd.Flags().StringVar(&patternSize, "size", "512x512", "Pattern tile size WxH")
 	patternCmd.Flags().StringVar(&patternStyle, "style", "", "Style: geometric, organic, abstract, floral, tech")
 	patternCmd.Flags().StringVar(&patternType, "type", "seamless", "Type: seamless, texture, wallpaper")
+	addQualityFlag(patternCmd, &patternQuality)
+	addMetadataFlag(patternCmd)
+
+	addPresetFlag(patternCmd)
+	addGeminiFlags(patternCmd)
 
 	patternCmd.MarkFlagRequired("output")
 
@@ -81,12 +97,23 @@ func runPattern(cmd *cobra.Command, args []string) error {
 
 	// Validate API key
 	apiKey := GetAPIKey()
-	if apiKey == "" {
+	if apiKey == "" && !usingVertex() {
 		f.Error("pattern", "MISSING_API_KEY", "No API key provided",
 			"Set GEMINI_API_KEY environment variable or use --api-key flag")
 		return fmt.Errorf("missing API key")
 	}
 
+	p, err := loadPreset(cmd, "pattern")
+	if err != nil {
+		return err
+	}
+	presetOpts := &gemini.GenerateOptions{}
+	if p != nil {
+		presetOpts.SystemInstruction = p.System
+		presetOpts.ImageSize = p.ImageSize
+		presetOpts.InputPaths = p.ReferencePaths()
+	}
+
 	// Parse size
 	var width, height int
 	parts := strings.Split(strings.ToLower(patternSize), "x")
@@ -96,7 +123,6 @@ func runPattern(cmd *cobra.Command, args []string) error {
 			"Use format WxH (e.g., 512x512)")
 		return fmt.Errorf("invalid size")
 	}
-	var err error
 	width, err = strconv.Atoi(strings.TrimSpace(parts[0]))
 	if err != nil {
 		f.Error("pattern", "INVALID_SIZE", "Invalid width value", "")
@@ -114,6 +140,15 @@ func runPattern(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid size")
 	}
 
+	if err := validateOutputFormat(patternOutput); err != nil {
+		f.Error("pattern", "UNSUPPORTED_FORMAT", err.Error(), outputFormatHint)
+		return err
+	}
+	if err := validateQuality(patternQuality); err != nil {
+		f.Error("pattern", "INVALID_QUALITY", err.Error(), "")
+		return err
+	}
+
 	// Validate type
 	validTypes := []string{"seamless", "texture", "wallpaper"}
 	typeValid := false
@@ -151,9 +186,9 @@ func runPattern(cmd *cobra.Command, args []string) error {
 	// Build enhanced prompt for pattern generation
 	enhancedPrompt := buildPatternPrompt(prompt, patternType, patternStyle)
 
-	client, err := gemini.NewClient(apiKey, GetModel(), 2*time.Minute)
+	client, err := newGeminiClient(apiKey, 2*time.Minute)
 	if err != nil {
-		f.Error("pattern", "CLIENT_ERROR", err.Error(), "Check your API key")
+		f.Error("pattern", "CLIENT_ERROR", err.Error(), clientErrorHint())
 		return err
 	}
 	modelInfo := client.Model()
@@ -178,16 +213,16 @@ func runPattern(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	result, err := client.Generate(ctx, enhancedPrompt, &gemini.GenerateOptions{
-		AspectRatio: aspectRatio,
-		Count:       1,
-	})
+	genOpts := &gemini.GenerateOptions{
+		AspectRatio:       aspectRatio,
+		ImageSize:         presetOpts.ImageSize,
+		Count:             1,
+		InputPaths:        presetOpts.InputPaths,
+		SystemInstruction: presetOpts.SystemInstruction,
+	}
+	result, err := client.Generate(ctx, enhancedPrompt, genOpts)
 	if err != nil {
-		if geminiErr, ok := err.(*gemini.GeminiError); ok {
-			f.Error("pattern", geminiErr.Code, geminiErr.Message, "")
-		} else {
-			f.Error("pattern", "GENERATION_FAILED", err.Error(), "")
-		}
+		reportGenerateError("pattern", err)
 		return err
 	}
 
@@ -197,17 +232,29 @@ func runPattern(cmd *cobra.Command, args []string) error {
 	}
 
 	// Save the pattern
-	if err := client.SaveImage(result.Images[0], patternOutput); err != nil {
+	format, err := client.SaveImage(result.Images[0], patternOutput, patternQuality)
+	if err != nil {
 		f.Error("pattern", "SAVE_FAILED", err.Error(), "")
 		return err
 	}
+	provenanceOptions := generateProvenanceOptions(modelInfo.Spec, genOpts)
+	provenanceOptions["type"] = patternType
+	provenanceOptions["style"] = patternStyle
+	provenanceOptions["size"] = patternSize
+	if p != nil {
+		provenanceOptions["preset"] = p.Name
+	}
+	rec := newProvenance("pattern", prompt, result.Model, provenanceOptions, genOpts.InputPaths, result.InputHashes)
+	embedProvenance(patternOutput, rec)
 
 	f.ImageSaved(patternOutput, width, height)
 
 	// Output success
 	elapsed := time.Since(startTime)
 	timing := &output.Timing{
-		TotalMs: elapsed.Milliseconds(),
+		TotalMs:  elapsed.Milliseconds(),
+		Attempts: result.Attempts,
+		Retries:  result.Retries,
 	}
 
 	data := map[string]interface{}{
@@ -218,11 +265,20 @@ func runPattern(cmd *cobra.Command, args []string) error {
 		"size":   patternSize,
 		"image": output.ImageResult{
 			Path:   patternOutput,
-			Format: "png",
+			Format: format,
 			Size:   &output.ImageSize{Width: width, Height: height},
 		},
 	}
+	if p != nil {
+		data["preset"] = p.Name
+	}
+
+	if result.Usage != nil {
+		data["usage"] = result.Usage
+		data["estimated_cost_usd"] = result.EstimatedCostUSD
+	}
 
+	recordUsage("pattern", result)
 	f.Success("pattern", data, timing)
 	return nil
 }
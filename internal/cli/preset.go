@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/preset"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Flags shared by generate, icon, pattern and preset save
+	presetName string
+	systemText string
+	systemFile string
+
+	// Preset save flags
+	presetAspectRatio string
+	presetImageSize   string
+	presetRefs        []string
+	presetOverwrite   bool
+)
+
+var presetCmd = &cobra.Command{
+	Use:   "preset",
+	Short: "Manage reusable generation presets",
+	Long: `Manage named presets that bundle a system instruction, model, aspect ratio,
+image size and reference images. Apply one with --preset NAME on generate, icon
+and pattern; flags given on the command line override the preset.
+
+Presets are stored in the presets directory of the config dir, and reference
+images are copied into the preset so it keeps working if the originals move.
+
+EXAMPLES:
+  nanobanana preset save brand-hero --system-file brand.txt -m pro --aspect-ratio 16:9 --ref logo.png
+  nanobanana preset list
+  nanobanana generate "a team offsite poster" --preset brand-hero -o offsite.png
+  nanobanana preset delete brand-hero`,
+}
+
+var presetSaveCmd = &cobra.Command{
+	Use:   "save <name>",
+	Short: "Save a preset from flags",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPresetSave,
+}
+
+var presetListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved presets",
+	Args:  cobra.NoArgs,
+	RunE:  runPresetList,
+}
+
+var presetShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a preset's settings",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPresetShow,
+}
+
+var presetDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a preset and its reference images",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPresetDelete,
+}
+
+func init() {
+	addSystemFlags(presetSaveCmd)
+	presetSaveCmd.Flags().StringVar(&presetAspectRatio, "aspect-ratio", "", "Aspect ratio")
+	presetSaveCmd.Flags().StringVar(&presetImageSize, "image-size", "", "Image size: 512, 1K, 2K, 4K")
+	presetSaveCmd.Flags().StringArrayVar(&presetRefs, "ref", nil, "Reference image to include with every prompt (repeatable)")
+	presetSaveCmd.Flags().BoolVar(&presetOverwrite, "overwrite", false, "Replace an existing preset")
+
+	presetCmd.AddCommand(presetSaveCmd, presetListCmd, presetShowCmd, presetDeleteCmd)
+	rootCmd.AddCommand(presetCmd)
+}
+
+// addSystemFlags registers --system and --system-file.
+func addSystemFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&systemText, "system", "", "System instruction sent with the prompt")
+	cmd.Flags().StringVar(&systemFile, "system-file", "", "Read the system instruction from a file")
+}
+
+// addPresetFlag registers --preset.
+func addPresetFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&presetName, "preset", "", "Apply a saved preset (see: nanobanana preset list)")
+}
+
+// readSystemInstruction returns the instruction from --system or --system-file.
+func readSystemInstruction() (string, error) {
+	if systemFile == "" {
+		return strings.TrimSpace(systemText), nil
+	}
+	if systemText != "" {
+		return "", fmt.Errorf("use either --system or --system-file, not both")
+	}
+	data, err := os.ReadFile(systemFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read system instruction file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// loadPreset loads the --preset preset, if any, and uses its model unless
+// --model was given. Other settings are applied by each command.
+func loadPreset(cmd *cobra.Command, command string) (*preset.Preset, error) {
+	if presetName == "" {
+		return nil, nil
+	}
+	p, err := preset.Load(presetName)
+	if err != nil {
+		GetFormatter().Error(command, "INVALID_PRESET", err.Error(), "List presets with: nanobanana preset list")
+		return nil, err
+	}
+	if p.Model != "" && !cmd.Flags().Changed("model") {
+		model = p.Model
+	}
+	return p, nil
+}
+
+func runPresetSave(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	name := args[0]
+
+	if presetAspectRatio != "" && !gemini.IsValidAspectRatio(presetAspectRatio) {
+		f.Error("preset", "INVALID_ASPECT_RATIO", fmt.Sprintf("Invalid aspect ratio: %s", presetAspectRatio), fmt.Sprintf("Valid ratios: %s", strings.Join(gemini.ListAllAspectRatios(), ", ")))
+		return fmt.Errorf("invalid aspect ratio")
+	}
+	if !gemini.IsValidImageSize(presetImageSize) {
+		f.Error("preset", "INVALID_IMAGE_SIZE", fmt.Sprintf("Invalid image size: %s", presetImageSize), "Valid sizes: 512, 1K, 2K, 4K")
+		return fmt.Errorf("invalid image size")
+	}
+	system, err := readSystemInstruction()
+	if err != nil {
+		f.Error("preset", "INVALID_SYSTEM_INSTRUCTION", err.Error(), "")
+		return err
+	}
+	for _, ref := range presetRefs {
+		if _, err := os.Stat(ref); err != nil {
+			f.Error("preset", "FILE_NOT_FOUND", fmt.Sprintf("Reference image not found: %s", ref), "")
+			return err
+		}
+	}
+
+	p, err := preset.New(name)
+	if err != nil {
+		f.Error("preset", "INVALID_PRESET", err.Error(), "")
+		return err
+	}
+	if preset.Exists(name) && !presetOverwrite {
+		f.Error("preset", "PRESET_EXISTS", fmt.Sprintf("Preset %s already exists", name), "Use --overwrite to replace it")
+		return fmt.Errorf("preset exists")
+	}
+	p.System = system
+	p.AspectRatio = presetAspectRatio
+	p.ImageSize = presetImageSize
+	if cmd.Flags().Changed("model") {
+		p.Model = GetModel()
+	}
+	if err := p.Save(presetRefs); err != nil {
+		f.Error("preset", "PRESET_SAVE_FAILED", err.Error(), "")
+		return err
+	}
+
+	f.Info("Saved preset %s", name)
+	f.Success("preset", map[string]any{"preset": p}, nil)
+	return nil
+}
+
+func runPresetList(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	presets, err := preset.List()
+	if err != nil {
+		f.Error("preset", "PRESET_LIST_FAILED", err.Error(), "")
+		return err
+	}
+
+	if len(presets) == 0 {
+		f.Info("No presets saved. Create one with: nanobanana preset save NAME")
+	}
+	for _, p := range presets {
+		var settings []string
+		for _, s := range []string{p.Model, p.AspectRatio, p.ImageSize} {
+			if s != "" {
+				settings = append(settings, s)
+			}
+		}
+		if p.System != "" {
+			settings = append(settings, "system instruction")
+		}
+		if n := len(p.References); n > 0 {
+			settings = append(settings, plural(n, "%d reference", "%d references"))
+		}
+		f.Info("%-20s %s", p.Name, strings.Join(settings, ", "))
+	}
+	f.Success("preset", map[string]any{"presets": presets}, nil)
+	return nil
+}
+
+func runPresetShow(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	p, err := preset.Load(args[0])
+	if err != nil {
+		f.Error("preset", "INVALID_PRESET", err.Error(), "List presets with: nanobanana preset list")
+		return err
+	}
+
+	f.Info("Name:           %s", p.Name)
+	f.Info("Model:          %s", firstNonEmpty(p.Model, "(command default)"))
+	f.Info("Aspect ratio:   %s", firstNonEmpty(p.AspectRatio, "(command default)"))
+	f.Info("Image size:     %s", firstNonEmpty(p.ImageSize, "(command default)"))
+	for _, ref := range p.ReferencePaths() {
+		f.Info("Reference:      %s", ref)
+	}
+	if p.System != "" {
+		f.Info("System instruction:\n%s", p.System)
+	}
+	f.Success("preset", map[string]any{"preset": p, "reference_paths": p.ReferencePaths()}, nil)
+	return nil
+}
+
+func runPresetDelete(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	if err := preset.Delete(args[0]); err != nil {
+		code := "PRESET_DELETE_FAILED"
+		if errors.Is(err, os.ErrNotExist) {
+			code = "INVALID_PRESET"
+		}
+		f.Error("preset", code, err.Error(), "")
+		return err
+	}
+	f.Info("Deleted preset %s", args[0])
+	f.Success("preset", map[string]any{"deleted": args[0]}, nil)
+	return nil
+}
@@ -0,0 +1,298 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/output"
+	"github.com/lyalindotcom/nano-banana-cli/internal/provenance"
+	"github.com/spf13/cobra"
+)
+
+// manifestSuffix is appended to generate's output path to name its sidecar
+// manifest.
+const manifestSuffix = ".nb.json"
+
+// manifestVersion is the sidecar manifest format version.
+const manifestVersion = 1
+
+var (
+	// Regenerate command flags
+	regenerateOutput       string
+	regenerateAllowChanged bool
+)
+
+var regenerateCmd = &cobra.Command{
+	Use:   "regenerate <manifest>",
+	Short: "Re-run a generation recorded by generate --manifest",
+	Long: `Re-run the request recorded in a sidecar manifest.
+
+generate --manifest writes <output>.nb.json next to its output, holding the
+prompt, model ID, every generation option (in batch job form), the seed and
+the SHA-256 of each reference image. When no --seed is given, --manifest
+picks one and sends it, so the request can be repeated.
+
+regenerate sends the same request again and overwrites the recorded outputs,
+or writes to -o instead, then rewrites the manifest next to them. Pass
+--model to refresh assets with a newer model while keeping everything else.
+The manifest records the safety settings from the config as well as the
+--safety flags, and the current config's safety defaults are not applied;
+--safety flags given to regenerate override the recorded ones per category.
+Reference files whose content no longer matches the recorded hash fail
+with INPUT_CHANGED unless --allow-changed-inputs is given; URL inputs are
+fetched again as they are now.
+
+EXAMPLES:
+  nanobanana generate "hero banner of a mountain lake" --aspect-ratio 16:9 -o hero.png --manifest
+  nanobanana regenerate hero.png.nb.json
+  nanobanana regenerate hero.png.nb.json -m pro -o hero-pro.png`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRegenerate,
+}
+
+func init() {
+	regenerateCmd.Flags().StringVarP(&regenerateOutput, "output", "o", "", "Output file path (default: the manifest's output)")
+	regenerateCmd.Flags().BoolVar(&regenerateAllowChanged, "allow-changed-inputs", false, "Regenerate even if reference files changed since the manifest was written")
+
+	addMetadataFlag(regenerateCmd)
+	addGeminiFlags(regenerateCmd)
+
+	rootCmd.AddCommand(regenerateCmd)
+}
+
+// generateManifest is the sidecar written by generate --manifest. Paths in
+// Request are relative to the manifest's directory.
+type generateManifest struct {
+	Version   int       `json:"version"`
+	Tool      string    `json:"tool"`
+	CreatedAt time.Time `json:"created_at"`
+	// Request is the generation in batch job form; Model is the resolved
+	// model ID and Seed the seed that was sent.
+	Request batchJob `json:"request"`
+	// Inputs holds the SHA-256 of each reference image, in request order.
+	Inputs []provenance.Input `json:"inputs,omitempty"`
+	Images []string           `json:"images"`
+}
+
+// manifestPath names the sidecar manifest of an output path.
+func manifestPath(outputPath string) string {
+	return outputPath + manifestSuffix
+}
+
+// relativeTo expresses path relative to dir when possible, so manifests
+// survive moving the directory they describe.
+func relativeTo(dir, path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(absDir, abs)
+	if err != nil {
+		return abs
+	}
+	return rel
+}
+
+// writeGenerateManifest writes job as the sidecar manifest of its output and
+// returns the manifest path.
+func writeGenerateManifest(job batchJob, hashes []string, images []output.ImageResult) (string, error) {
+	path := manifestPath(job.Output)
+	dir := filepath.Dir(path)
+
+	m := generateManifest{
+		Version:   manifestVersion,
+		Tool:      "nanobanana " + Version,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Request:   job,
+		Images:    []string{},
+	}
+	m.Request.Output = relativeTo(dir, job.Output)
+	m.Request.Inputs = make([]string, len(job.Inputs))
+	for i, input := range job.Inputs {
+		m.Request.Inputs[i] = input
+		if gemini.IsFileInput(input) {
+			m.Request.Inputs[i] = relativeTo(dir, input)
+		}
+	}
+	for i, hash := range hashes {
+		name := ""
+		if i < len(job.Inputs) {
+			name = gemini.InputLabel(m.Request.Inputs[i])
+		}
+		m.Inputs = append(m.Inputs, provenance.Input{Name: name, SHA256: hash})
+	}
+	for _, img := range images {
+		m.Images = append(m.Images, relativeTo(dir, img.Path))
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return path, nil
+}
+
+// loadGenerateManifest reads a sidecar manifest, resolving its paths against
+// the manifest's directory.
+func loadGenerateManifest(path string) (*generateManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var m generateManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	dir := filepath.Dir(path)
+	m.Request.Output = resolveManifestPath(dir, m.Request.Output)
+	for i, input := range m.Request.Inputs {
+		if gemini.IsFileInput(input) {
+			m.Request.Inputs[i] = resolveManifestPath(dir, input)
+		}
+	}
+	return &m, nil
+}
+
+// changedInputs lists the reference files whose content no longer matches
+// the manifest. Other inputs cannot be checked before the request.
+func (m *generateManifest) changedInputs() []string {
+	var changed []string
+	for i, input := range m.Request.Inputs {
+		if i >= len(m.Inputs) || !gemini.IsFileInput(input) {
+			continue
+		}
+		data, err := os.ReadFile(input)
+		if err != nil {
+			changed = append(changed, input)
+			continue
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != m.Inputs[i].SHA256 {
+			changed = append(changed, input)
+		}
+	}
+	return changed
+}
+
+func runRegenerate(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	startTime := time.Now()
+
+	m, err := loadGenerateManifest(args[0])
+	if err != nil {
+		f.Error("regenerate", "INVALID_MANIFEST", err.Error(), "Pass a .nb.json file written by generate --manifest")
+		return err
+	}
+	job := m.Request
+	if regenerateOutput != "" {
+		job.Output = regenerateOutput
+	}
+	if cmd.Flags().Changed("model") {
+		job.Model = GetModel()
+	}
+
+	apiKey := GetAPIKey()
+	if apiKey == "" && !usingVertex() {
+		f.Error("regenerate", "MISSING_API_KEY", "No API key provided", "Set GEMINI_API_KEY environment variable or use --api-key flag")
+		return fmt.Errorf("missing API key")
+	}
+
+	if err := job.validate(); err != nil {
+		f.Error("regenerate", "INVALID_MANIFEST", err.Error(), "")
+		return err
+	}
+	if changed := m.changedInputs(); len(changed) > 0 {
+		if !regenerateAllowChanged {
+			f.Error("regenerate", "INPUT_CHANGED", fmt.Sprintf("Reference images changed since the manifest was written: %s", strings.Join(changed, ", ")),
+				"Restore the original files, or pass --allow-changed-inputs to use them as they are")
+			return fmt.Errorf("inputs changed")
+		}
+		f.Progress("Using changed reference images: %s", strings.Join(changed, ", "))
+	}
+
+	clientOpts, err := geminiClientOptions(job.Model)
+	if err != nil {
+		f.Error("regenerate", "CLIENT_ERROR", err.Error(), clientErrorHint())
+		return err
+	}
+	// The manifest holds every safety setting the original request sent, so
+	// the config's current defaults must not add to them; --safety flags
+	// still override it per category.
+	clientOpts = append(clientOpts, gemini.WithoutSafetySettings())
+	client, err := gemini.NewClient(apiKey, job.Model, 3*time.Minute, clientOpts...)
+	if err != nil {
+		f.Error("regenerate", "CLIENT_ERROR", err.Error(), clientErrorHint())
+		return err
+	}
+	if flags, _ := safetyFlagSettings(); len(flags) > 0 {
+		recorded, _ := gemini.ParseSafetyMap(job.Safety)
+		job.Safety = safetyMap(append(recorded, flags...))
+	}
+	modelInfo := client.Model()
+	job.Model = modelInfo.Spec.ID
+
+	opts := batchJobOptions(job)
+	opts.Concurrency = gemini.DefaultConcurrency
+	if err := gemini.ValidateSampling(modelInfo.Spec, opts.Sampling); err != nil {
+		f.Error("regenerate", "INVALID_SAMPLING", err.Error(), "")
+		return err
+	}
+
+	f.Progress("Regenerating %s with %s...", job.Output, modelInfo.Spec.ID)
+	result, err := client.Generate(context.Background(), job.Prompt, opts)
+	if err != nil {
+		reportGenerateError("regenerate", err)
+		return err
+	}
+	reportInputAdjustments(result)
+
+	rec := newProvenance("generate", job.Prompt, result.Model, generateProvenanceOptions(modelInfo.Spec, opts), job.Inputs, result.InputHashes)
+	images, err := saveGeneratedImages(client, result, job.Output, job.candidates(), job.Quality, rec)
+	if err != nil {
+		f.Error("regenerate", "SAVE_FAILED", err.Error(), "")
+		return err
+	}
+	for _, img := range images {
+		f.ImageSaved(img.Path, img.Size.Width, img.Size.Height)
+	}
+	for _, cerr := range result.Errors {
+		f.Progress("Candidate %d failed: %v", cerr.Index+1, cerr.Err)
+	}
+
+	manifest, err := writeGenerateManifest(job, result.InputHashes, images)
+	if err != nil {
+		f.Error("regenerate", "SAVE_FAILED", err.Error(), "")
+		return err
+	}
+	f.Info("Saved manifest: %s", manifest)
+
+	timing := &output.Timing{
+		TotalMs:  time.Since(startTime).Milliseconds(),
+		Attempts: result.Attempts,
+		Retries:  result.Retries,
+	}
+	data := generateResultData(job.Prompt, modelInfo.Spec, opts, result, images)
+	data["manifest"] = manifest
+	data["source_manifest"] = args[0]
+
+	recordUsage("regenerate", result)
+	f.Success("regenerate", data, timing)
+	return nil
+}
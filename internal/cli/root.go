@@ -74,7 +74,7 @@ For detailed help on any command:
 
 For a single-command manual covering the whole CLI:
   nanobanana docs`,
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			godotenv.Load()
 			formatter = output.NewFormatter(jsonMode, quiet, noColor)
 
@@ -82,6 +82,11 @@ For a single-command manual covering the whole CLI:
 				apiKey = appconfig.GetAPIKey()
 			}
 
+			if err := loadModelRegistry(); err != nil {
+				formatter.Error(cmd.Name(), "INVALID_MODEL_CONFIG", err.Error(), "Fix the models section of the config file")
+				return err
+			}
+			return nil
 		},
 	}
 )
@@ -97,7 +102,7 @@ func init() {
 	)
 	rootCmd.PersistentFlags().BoolVar(&jsonMode, "json", false, "Output in JSON format")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress non-essential output")
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output, including an HTTP trace of API calls on stderr (keys masked)")
 	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output")
 
 	rootCmd.AddCommand(generateCmd)
@@ -0,0 +1,391 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/output"
+	"github.com/lyalindotcom/nano-banana-cli/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Session command flags
+	sessionOutputDir   string
+	sessionAspectRatio string
+	sessionImageSize   string
+	sessionFitInputs   bool
+	sessionUpload      bool
+)
+
+const sessionHelp = `Commands:
+  /attach FILE...   Attach reference images to the next prompt
+  /undo             Drop the last turn
+  /model [NAME]     Show the model, or switch and continue from the latest image
+  /save [FILE]      Save the session, or export its history for --history-in
+  /branch NAME      Copy the session and continue in the copy
+  /help             Show this help
+  /quit             Save and exit
+Anything else is sent as the next prompt.`
+
+var sessionCmd = &cobra.Command{
+	Use:   "session [name]",
+	Short: "Edit an image over several turns in an interactive session",
+	Long: `Start or resume an interactive multi-turn editing session.
+
+Each line you type is sent as the next turn of one conversation, so every
+prompt edits the previous result. Images are saved to the output directory as
+<name>_001.png, <name>_002.png, ... The session (settings, turns and
+conversation history) is saved after every turn in the config directory and
+resumed by running "nanobanana session <name>" again.
+
+` + sessionHelp + `
+
+With --json, every turn prints a generate-style response and every command an
+event object, one JSON object per line.
+
+EXAMPLES:
+  # Start a named session, saving images under ./art
+  nanobanana session poster --output-dir art -m pro
+
+  # Resume it later
+  nanobanana session poster
+
+  # Script a session
+  printf 'a red bicycle\n/undo\na blue bicycle\n' | nanobanana session bikes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSession,
+}
+
+func init() {
+	sessionCmd.Flags().StringVar(&sessionOutputDir, "output-dir", ".", "Directory for session images (new sessions only)")
+	sessionCmd.Flags().StringVar(&sessionAspectRatio, "aspect-ratio", "", "Aspect ratio for every turn")
+	sessionCmd.Flags().StringVar(&sessionImageSize, "image-size", "", "Image size for every turn: 512, 1K, 2K, 4K")
+	addUploadFlag(sessionCmd, &sessionUpload)
+	sessionCmd.Flags().BoolVar(&sessionFitInputs, "fit-inputs", false, "Downscale attachments that would push a turn over the model's request size limit")
+
+	addGeminiFlags(sessionCmd)
+
+	rootCmd.AddCommand(sessionCmd)
+}
+
+// sessionREPL holds the state of a running session.
+type sessionREPL struct {
+	f       *output.Formatter
+	apiKey  string
+	session *session.Session
+	history *gemini.ConversationHistory
+	client  *gemini.Client
+}
+
+func runSession(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+	f.Stream = true
+
+	name := "session-" + time.Now().Format("20060102-150405")
+	if len(args) == 1 {
+		name = args[0]
+	}
+
+	apiKey := GetAPIKey()
+	if apiKey == "" && !usingVertex() {
+		f.Error("session", "MISSING_API_KEY", "No API key provided", "Set GEMINI_API_KEY environment variable or use --api-key flag")
+		return fmt.Errorf("missing API key")
+	}
+
+	r := &sessionREPL{f: f, apiKey: apiKey}
+	if err := r.open(name, cmd); err != nil {
+		f.Error("session", "SESSION_ERROR", err.Error(), "")
+		return err
+	}
+
+	if len(r.session.Turns) > 0 {
+		f.Info("Resumed session %s (%s, %d turns)", r.session.Name, r.client.Model().Spec.ID, len(r.session.Turns))
+	} else {
+		f.Info("Session %s (%s). Type /help for commands.", r.session.Name, r.client.Model().Spec.ID)
+	}
+
+	interactive := false
+	if stat, err := os.Stdin.Stat(); err == nil && cmd.InOrStdin() == os.Stdin {
+		interactive = stat.Mode()&os.ModeCharDevice != 0
+	}
+
+	scanner := bufio.NewScanner(cmd.InOrStdin())
+	for {
+		if interactive && !f.JSONMode {
+			fmt.Printf("%s> ", r.session.Name)
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "/") {
+			quit, err := r.command(line)
+			if err != nil {
+				f.Error("session", "SESSION_COMMAND_FAILED", err.Error(), "Type /help for commands")
+			}
+			if quit {
+				break
+			}
+			continue
+		}
+		r.turn(line)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return r.session.Save()
+}
+
+// open loads the named session, or creates it from the command flags.
+func (r *sessionREPL) open(name string, cmd *cobra.Command) error {
+	s, err := session.Load(name)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s, err = session.New(name, GetModel(), sessionOutputDir)
+		if err != nil {
+			return err
+		}
+		s.AspectRatio = sessionAspectRatio
+		s.ImageSize = sessionImageSize
+	case err != nil:
+		return err
+	default:
+		if cmd.Flags().Changed("model") {
+			s.Model = GetModel()
+		}
+		if cmd.Flags().Changed("aspect-ratio") {
+			s.AspectRatio = sessionAspectRatio
+		}
+		if cmd.Flags().Changed("image-size") {
+			s.ImageSize = sessionImageSize
+		}
+		if _, err := os.Stat(s.HistoryPath()); err == nil {
+			if r.history, err = gemini.LoadHistory(s.HistoryPath()); err != nil {
+				return err
+			}
+		}
+	}
+	r.session = s
+
+	client, err := newGeminiClientForModel(r.apiKey, s.Model, 3*time.Minute)
+	if err != nil {
+		return err
+	}
+	r.client = client
+	if r.history != nil && r.history.Model != client.Model().Spec.ID {
+		r.restartConversation()
+	}
+	return r.session.Save()
+}
+
+// turn sends one prompt and saves the resulting image.
+func (r *sessionREPL) turn(prompt string) {
+	startTime := time.Now()
+	s := r.session
+	opts := &gemini.GenerateOptions{
+		AspectRatio:  s.AspectRatio,
+		ImageSize:    s.ImageSize,
+		Count:        1,
+		InputPaths:   s.Attachments,
+		FitInputs:    sessionFitInputs,
+		UploadInputs: sessionUpload,
+		History:      r.history,
+	}
+
+	r.f.Progress("Generating turn %d with %s...", len(s.Turns)+1, r.client.Model().Spec.ID)
+	result, err := r.client.Generate(context.Background(), prompt, opts)
+	if err != nil {
+		reportGenerateError("session", err)
+		return
+	}
+	reportInputAdjustments(result)
+
+	images, err := saveGeneratedImages(r.client, result, s.NextImagePath(extensionForMime(result.Images[0].MimeType)), 1, 0, nil)
+	if err != nil {
+		r.f.Error("session", "SAVE_FAILED", err.Error(), "")
+		return
+	}
+
+	historyLen := 0
+	if r.history != nil {
+		historyLen = len(r.history.Contents)
+	}
+	turn := session.Turn{Prompt: prompt, Attachments: s.Attachments, Time: time.Now().UTC(), HistoryLen: historyLen}
+	for _, img := range images {
+		turn.Images = append(turn.Images, img.Path)
+		r.f.ImageSaved(img.Path, img.Size.Width, img.Size.Height)
+	}
+	s.Turns = append(s.Turns, turn)
+	s.Attachments = nil
+	r.history = result.History
+
+	for _, text := range result.Texts {
+		if !text.Thought && strings.TrimSpace(text.Text) != "" {
+			r.f.Info(strings.TrimSpace(text.Text))
+		}
+	}
+
+	if err := r.persist(); err != nil {
+		r.f.Error("session", "SESSION_SAVE_FAILED", err.Error(), "")
+		return
+	}
+	recordUsage("session", result)
+
+	data := generateResultData(prompt, r.client.Model().Spec, opts, result, images)
+	data["session"] = s.Name
+	data["turn"] = len(s.Turns)
+	if r.f.JSONMode {
+		r.f.Success("session", data, &output.Timing{
+			TotalMs:  time.Since(startTime).Milliseconds(),
+			Attempts: result.Attempts,
+			Retries:  result.Retries,
+		})
+	}
+}
+
+// command runs a slash command and reports whether the session should end.
+func (r *sessionREPL) command(line string) (bool, error) {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+	s := r.session
+
+	switch name {
+	case "/quit", "/exit":
+		return true, nil
+
+	case "/help":
+		if r.f.JSONMode {
+			r.f.Event("help", map[string]any{"text": sessionHelp}, "")
+		} else {
+			fmt.Println(sessionHelp)
+		}
+
+	case "/attach":
+		if len(args) == 0 {
+			return false, fmt.Errorf("usage: /attach FILE...")
+		}
+		for _, path := range args {
+			if path == gemini.StdinInput {
+				return false, fmt.Errorf("stdin is the session's input; attach a file, URL or data: URI instead")
+			}
+			if !gemini.IsFileInput(path) {
+				continue
+			}
+			if _, err := os.Stat(path); err != nil {
+				return false, fmt.Errorf("input file not found: %s", path)
+			}
+		}
+		s.Attachments = append(s.Attachments, args...)
+		r.note("attach", map[string]any{"attachments": s.Attachments}, "Attached %s to the next prompt", strings.Join(args, ", "))
+
+	case "/undo":
+		if len(s.Turns) == 0 {
+			return false, fmt.Errorf("nothing to undo")
+		}
+		last := s.Turns[len(s.Turns)-1]
+		s.Turns = s.Turns[:len(s.Turns)-1]
+		if last.HistoryLen == 0 {
+			r.history = nil
+		} else {
+			r.history.Contents = r.history.Contents[:last.HistoryLen]
+		}
+		r.note("undo", map[string]any{"turns": len(s.Turns), "prompt": last.Prompt}, "Undid turn %d (%q); its image is kept", len(s.Turns)+1, last.Prompt)
+
+	case "/model":
+		if len(args) == 0 {
+			r.note("model", map[string]any{"model": r.client.Model().Spec.ID}, "Model: %s", r.client.Model().Spec.ID)
+			return false, nil
+		}
+		client, err := newGeminiClientForModel(r.apiKey, args[0], 3*time.Minute)
+		if err != nil {
+			return false, err
+		}
+		r.client = client
+		s.Model = args[0]
+		restarted := r.history != nil
+		message := "Switched to %s"
+		if restarted {
+			r.restartConversation()
+			message += "; the next prompt continues from the latest image"
+		}
+		r.note("model", map[string]any{"model": client.Model().Spec.ID, "restarted": restarted, "attachments": s.Attachments},
+			message, client.Model().Spec.ID)
+
+	case "/save":
+		if len(args) == 0 {
+			if err := r.persist(); err != nil {
+				return false, err
+			}
+			r.note("save", map[string]any{"session": s.Name}, "Saved session %s", s.Name)
+			return false, nil
+		}
+		if r.history == nil {
+			return false, fmt.Errorf("no history to export yet")
+		}
+		if err := r.client.SaveHistory(r.history, args[0]); err != nil {
+			return false, err
+		}
+		r.note("save", map[string]any{"session": s.Name, "history_file": args[0]}, "Exported history to %s", args[0])
+
+	case "/branch":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: /branch NAME")
+		}
+		if err := r.persist(); err != nil {
+			return false, err
+		}
+		branch, err := s.Branch(args[0])
+		if err != nil {
+			return false, err
+		}
+		r.session = branch
+		r.note("branch", map[string]any{"session": branch.Name, "parent": branch.Parent}, "Branched %s into %s", branch.Parent, branch.Name)
+
+	default:
+		return false, fmt.Errorf("unknown command %s", name)
+	}
+	return false, r.persist()
+}
+
+// restartConversation drops the history, which is tied to the model that
+// produced it, and attaches the latest image to the next prompt instead.
+func (r *sessionREPL) restartConversation() {
+	s := r.session
+	if n := len(s.Turns); n > 0 && len(s.Turns[n-1].Images) > 0 {
+		s.Attachments = append([]string{s.Turns[n-1].Images[0]}, s.Attachments...)
+	}
+	s.Turns = nil
+	r.history = nil
+	os.Remove(s.HistoryPath())
+}
+
+// persist saves the session state and history.
+func (r *sessionREPL) persist() error {
+	if r.history == nil {
+		os.Remove(r.session.HistoryPath())
+	} else if err := r.client.SaveHistory(r.history, r.session.HistoryPath()); err != nil {
+		return err
+	}
+	return r.session.Save()
+}
+
+// note reports a command's outcome as an event in JSON mode and a line of
+// text otherwise.
+func (r *sessionREPL) note(event string, fields map[string]any, format string, args ...any) {
+	if r.f.JSONMode {
+		r.f.Event(event, fields, "")
+		return
+	}
+	r.f.Info(format, args...)
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini"
+	"github.com/lyalindotcom/nano-banana-cli/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Usage command flags
+	usageBy    string
+	usageSince string
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Summarize token usage and estimated cost from the local ledger",
+	Long: `Summarize token usage and estimated cost of Gemini calls.
+
+Every successful generate, icon, pattern, batch and session call appends a
+line to a local ledger (usage.jsonl in the config directory) with the model,
+command, token counts and estimated cost. This command aggregates that ledger.
+
+Costs are estimates from the built-in price table. Override prices per model
+in the config file:
+
+  pricing:
+    gemini-3-pro-image-preview:
+      input_per_million: 2
+      text_output_per_million: 12
+      image_output_per_million: 120
+
+EXAMPLES:
+  # Usage per day
+  nanobanana usage
+
+  # Usage per model since a date
+  nanobanana usage --by model --since 2026-01-01
+
+  # Per-command totals as JSON
+  nanobanana usage --by command --json`,
+	Args: cobra.NoArgs,
+	RunE: runUsage,
+}
+
+func init() {
+	usageCmd.Flags().StringVar(&usageBy, "by", "day", "Group by: day, model, command")
+	usageCmd.Flags().StringVar(&usageSince, "since", "", "Only include calls on or after this date (YYYY-MM-DD)")
+
+	rootCmd.AddCommand(usageCmd)
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	f := GetFormatter()
+
+	path, err := usage.LedgerPath()
+	if err != nil {
+		f.Error("usage", "LEDGER_PATH_ERROR", err.Error(), "")
+		return err
+	}
+
+	entries, err := usage.Load(path)
+	if err != nil {
+		f.Error("usage", "LEDGER_READ_ERROR", err.Error(), "")
+		return err
+	}
+
+	if usageSince != "" {
+		since, err := time.ParseInLocation("2006-01-02", usageSince, time.Local)
+		if err != nil {
+			f.Error("usage", "INVALID_SINCE", fmt.Sprintf("Invalid date: %s", usageSince), "Use YYYY-MM-DD")
+			return err
+		}
+		filtered := entries[:0]
+		for _, e := range entries {
+			if !e.Time.Before(since) {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+
+	summaries, err := usage.Summarize(entries, usageBy)
+	if err != nil {
+		f.Error("usage", "INVALID_GROUPING", err.Error(), "Valid values: day, model, command")
+		return err
+	}
+
+	var total usage.Summary
+	total.Key = "total"
+	for _, s := range summaries {
+		total.Calls += s.Calls
+		total.Images += s.Images
+		total.PromptTokens += s.PromptTokens
+		total.CandidateTokens += s.CandidateTokens
+		total.ThoughtTokens += s.ThoughtTokens
+		total.TotalTokens += s.TotalTokens
+		total.EstimatedCostUSD += s.EstimatedCostUSD
+	}
+
+	if f.JSONMode {
+		f.Success("usage", map[string]any{
+			"ledger": path,
+			"by":     usageBy,
+			"since":  usageSince,
+			"groups": summaries,
+			"total":  total,
+		}, nil)
+		return nil
+	}
+
+	if len(summaries) == 0 {
+		fmt.Printf("No usage recorded in %s\n", path)
+		return nil
+	}
+
+	fmt.Printf("%-32s %6s %7s %12s %12s %10s\n", usageBy, "calls", "images", "in tokens", "out tokens", "est. USD")
+	for _, s := range append(summaries, total) {
+		fmt.Printf("%-32s %6d %7d %12d %12d %10.4f\n",
+			s.Key, s.Calls, s.Images, s.PromptTokens, s.CandidateTokens+s.ThoughtTokens, s.EstimatedCostUSD)
+	}
+	return nil
+}
+
+// recordUsage appends a successful Gemini call to the usage ledger. Ledger
+// failures never fail the command itself.
+func recordUsage(command string, result *gemini.GenerateResult) {
+	if result == nil || result.Usage == nil {
+		return
+	}
+	path, err := usage.LedgerPath()
+	if err != nil {
+		return
+	}
+	_ = usage.Append(path, usage.Entry{
+		Time:             time.Now(),
+		Command:          command,
+		Model:            result.Model,
+		Images:           len(result.Images),
+		PromptTokens:     result.Usage.PromptTokens,
+		CandidateTokens:  result.Usage.CandidateTokens,
+		ThoughtTokens:    result.Usage.ThoughtTokens,
+		TotalTokens:      result.Usage.TotalTokens,
+		EstimatedCostUSD: result.EstimatedCostUSD,
+	})
+}
@@ -2,39 +2,129 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
 )
 
 type Config struct {
-	APIKey    string        `mapstructure:"api_key"`
-	Model     string        `mapstructure:"model"`
-	Timeout   time.Duration `mapstructure:"timeout"`
-	OutputDir string        `mapstructure:"output_dir"`
+	APIKey    string           `mapstructure:"api_key"`
+	Model     string           `mapstructure:"model"`
+	Timeout   time.Duration    `mapstructure:"timeout"`
+	OutputDir string           `mapstructure:"output_dir"`
+	Pricing   map[string]Price `mapstructure:"pricing"`
+
+	BaseURL string            `mapstructure:"base_url"`
+	Headers map[string]string `mapstructure:"headers"`
+	Proxy   string            `mapstructure:"proxy"`
+	CACert  string            `mapstructure:"ca_cert"`
+
+	Backend  string `mapstructure:"backend"`
+	Project  string `mapstructure:"project"`
+	Location string `mapstructure:"location"`
+
+	// Safety maps harm categories to default block thresholds, e.g.
+	// dangerous: none. --safety flags override it per category.
+	Safety map[string]string `mapstructure:"safety"`
+
+	// Models declares custom models, or overrides built-in ones, keyed by
+	// model ID.
+	Models map[string]ModelConfig `mapstructure:"models"`
+}
+
+// ModelConfig describes a model's aliases and capabilities. Unset fields keep
+// the built-in model's values, or allow everything for a new model.
+type ModelConfig struct {
+	DisplayName      string   `mapstructure:"display_name" yaml:"display_name,omitempty"`
+	Aliases          []string `mapstructure:"aliases" yaml:"aliases,omitempty"`
+	AspectRatios     []string `mapstructure:"aspect_ratios" yaml:"aspect_ratios,omitempty"`
+	ImageSizes       []string `mapstructure:"image_sizes" yaml:"image_sizes,omitempty"`
+	DefaultImageSize string   `mapstructure:"default_image_size" yaml:"default_image_size,omitempty"`
+	Grounding        *bool    `mapstructure:"grounding" yaml:"grounding,omitempty"`
+	ImageSearch      *bool    `mapstructure:"image_search" yaml:"image_search,omitempty"`
+	Thinking         *bool    `mapstructure:"thinking" yaml:"thinking,omitempty"`
+	ThinkingLevel    *bool    `mapstructure:"thinking_level" yaml:"thinking_level,omitempty"`
+	MaxInputImages   int      `mapstructure:"max_input_images" yaml:"max_input_images,omitempty"`
+	MaxRequestBytes  int64    `mapstructure:"max_request_bytes" yaml:"max_request_bytes,omitempty"`
+}
+
+// Price overrides the built-in per-million-token USD prices for a model ID or alias.
+type Price struct {
+	InputPerMillion       float64 `mapstructure:"input_per_million"`
+	TextOutputPerMillion  float64 `mapstructure:"text_output_per_million"`
+	ImageOutputPerMillion float64 `mapstructure:"image_output_per_million"`
 }
 
 const DefaultModel = "gemini-3.1-flash-image-preview"
 const ProModel = "gemini-3-pro-image-preview"
 const DefaultTimeout = 2 * time.Minute
 
+// keyDelimiter replaces viper's default "." so model IDs like
+// gemini-2.5-flash-image can be used as map keys.
+const keyDelimiter = "::"
+
 func ConfigFilePath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// ConfigDir returns the directory holding the config file and other per-user state.
+func ConfigDir() (string, error) {
 	if customDir := strings.TrimSpace(os.Getenv("NANOBANANA_CONFIG_DIR")); customDir != "" {
-		return filepath.Join(customDir, "config.yaml"), nil
+		return customDir, nil
 	}
 	configDir, err := os.UserConfigDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(configDir, "nanobanana", "config.yaml"), nil
+	return filepath.Join(configDir, "nanobanana"), nil
+}
+
+// validName matches names that are safe as a directory name under the config
+// dir. They cannot start with '.', which leaves hidden names free for
+// temporary directories.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// StateDir returns the directory under the config dir that holds one kind of
+// saved item, such as "sessions" or "presets", one subdirectory per name.
+func StateDir(kind string) (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, kind), nil
+}
+
+// ValidateName rejects a name for a saved item, such as a session or preset,
+// that is not safe as a directory name in its StateDir.
+func ValidateName(kind, name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid %s name %q (use letters, digits, '.', '_' and '-')", kind, name)
+	}
+	return nil
 }
 
 func Load() (*Config, error) {
-	v := newViper()
+	return unmarshal(newViper())
+}
 
+// loadFile reads the config file without environment overrides, so saving
+// what it returns does not persist values that only came from the
+// environment.
+func loadFile() (*Config, error) {
+	return unmarshal(newFileViper())
+}
+
+func unmarshal(v *viper.Viper) (*Config, error) {
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, err
@@ -55,11 +145,37 @@ func Save(cfg *Config) error {
 		return err
 	}
 
-	v := viper.New()
+	v := viper.NewWithOptions(viper.KeyDelimiter(keyDelimiter))
 	v.Set("api_key", cfg.APIKey)
 	v.Set("model", cfg.Model)
 	v.Set("timeout", cfg.Timeout.String())
 	v.Set("output_dir", cfg.OutputDir)
+	if len(cfg.Pricing) > 0 {
+		pricing := map[string]any{}
+		for model, p := range cfg.Pricing {
+			pricing[model] = map[string]any{
+				"input_per_million":        p.InputPerMillion,
+				"text_output_per_million":  p.TextOutputPerMillion,
+				"image_output_per_million": p.ImageOutputPerMillion,
+			}
+		}
+		v.Set("pricing", pricing)
+	}
+	setIfNotEmpty(v, "base_url", cfg.BaseURL)
+	setIfNotEmpty(v, "proxy", cfg.Proxy)
+	setIfNotEmpty(v, "ca_cert", cfg.CACert)
+	setIfNotEmpty(v, "backend", cfg.Backend)
+	setIfNotEmpty(v, "project", cfg.Project)
+	setIfNotEmpty(v, "location", cfg.Location)
+	if len(cfg.Headers) > 0 {
+		v.Set("headers", cfg.Headers)
+	}
+	if len(cfg.Safety) > 0 {
+		v.Set("safety", cfg.Safety)
+	}
+	if len(cfg.Models) > 0 {
+		v.Set("models", modelsToMap(cfg.Models))
+	}
 	v.SetConfigFile(path)
 	v.SetConfigType("yaml")
 	return v.WriteConfigAs(path)
@@ -71,7 +187,7 @@ func SetAPIKey(apiKey string) error {
 		return errors.New("api key cannot be empty")
 	}
 
-	cfg, err := Load()
+	cfg, err := loadFile()
 	if err != nil {
 		return err
 	}
@@ -89,7 +205,7 @@ func SetAPIKey(apiKey string) error {
 }
 
 func ClearAPIKey() error {
-	cfg, err := Load()
+	cfg, err := loadFile()
 	if err != nil {
 		return err
 	}
@@ -128,21 +244,51 @@ func ResolveModel(model string) string {
 }
 
 func newViper() *viper.Viper {
-	v := viper.New()
-	v.SetDefault("model", DefaultModel)
-	v.SetDefault("timeout", DefaultTimeout)
-	v.SetDefault("output_dir", ".")
-
+	v := newFileViper()
 	v.SetEnvPrefix("NANOBANANA")
 	v.AutomaticEnv()
 	_ = v.BindEnv("api_key", "GEMINI_API_KEY", "NANOBANANA_API_KEY", "GOOGLE_API_KEY")
 	_ = v.BindEnv("model", "NANOBANANA_MODEL")
+	_ = v.BindEnv("base_url", "NANOBANANA_BASE_URL", "GEMINI_BASE_URL")
+	_ = v.BindEnv("proxy", "NANOBANANA_PROXY")
+	_ = v.BindEnv("ca_cert", "NANOBANANA_CA_CERT")
+	_ = v.BindEnv("backend", "NANOBANANA_BACKEND")
+	_ = v.BindEnv("project", "NANOBANANA_PROJECT", "GOOGLE_CLOUD_PROJECT")
+	_ = v.BindEnv("location", "NANOBANANA_LOCATION", "GOOGLE_CLOUD_LOCATION", "GOOGLE_CLOUD_REGION")
+	return v
+}
+
+// newFileViper reads the config file over the defaults.
+func newFileViper() *viper.Viper {
+	v := viper.NewWithOptions(viper.KeyDelimiter(keyDelimiter))
+	v.SetDefault("model", DefaultModel)
+	v.SetDefault("timeout", DefaultTimeout)
+	v.SetDefault("output_dir", ".")
 
 	if path, err := ConfigFilePath(); err == nil {
 		v.SetConfigFile(path)
 		v.SetConfigType("yaml")
 		_ = v.ReadInConfig()
 	}
-
 	return v
 }
+
+// modelsToMap converts model declarations to plain maps for viper, leaving
+// out unset fields.
+func modelsToMap(models map[string]ModelConfig) map[string]any {
+	out := map[string]any{}
+	for id, m := range models {
+		entry := map[string]any{}
+		if data, err := yaml.Marshal(m); err == nil {
+			_ = yaml.Unmarshal(data, &entry)
+		}
+		out[id] = entry
+	}
+	return out
+}
+
+func setIfNotEmpty(v *viper.Viper, key, value string) {
+	if value != "" {
+		v.Set(key, value)
+	}
+}
@@ -23,6 +23,17 @@ func TestConfigFilePath(t *testing.T) {
 	}
 }
 
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"", "../x", "a/b", ".hidden"} {
+		if ValidateName("session", name) == nil {
+			t.Errorf("ValidateName(%q) accepted", name)
+		}
+	}
+	if err := ValidateName("preset", "brand.tmp"); err != nil {
+		t.Errorf("ValidateName(brand.tmp) = %v", err)
+	}
+}
+
 func TestSetAndLoadAPIKey(t *testing.T) {
 	t.Setenv("NANOBANANA_CONFIG_DIR", t.TempDir())
 
@@ -46,6 +57,36 @@ func TestSetAndLoadAPIKey(t *testing.T) {
 	}
 }
 
+func TestSetAPIKeyIgnoresEnvironment(t *testing.T) {
+	t.Setenv("NANOBANANA_CONFIG_DIR", t.TempDir())
+	t.Setenv("NANOBANANA_BASE_URL", "http://proxy.internal")
+	t.Setenv("NANOBANANA_PROXY", "http://127.0.0.1:3128")
+	t.Setenv("NANOBANANA_BACKEND", "vertex")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "env-project")
+	t.Setenv("NANOBANANA_MODEL", "pro")
+
+	if err := Save(&Config{Model: "banana", Location: "europe-west4"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := SetAPIKey("test-key"); err != nil {
+		t.Fatalf("SetAPIKey() error = %v", err)
+	}
+	if err := ClearAPIKey(); err != nil {
+		t.Fatalf("ClearAPIKey() error = %v", err)
+	}
+
+	cfg, err := loadFile()
+	if err != nil {
+		t.Fatalf("loadFile() error = %v", err)
+	}
+	if cfg.BaseURL != "" || cfg.Proxy != "" || cfg.Backend != "" || cfg.Project != "" {
+		t.Fatalf("environment values were saved: %+v", cfg)
+	}
+	if cfg.Model != "banana" || cfg.Location != "europe-west4" {
+		t.Fatalf("file values were lost: %+v", cfg)
+	}
+}
+
 func TestClearAPIKey(t *testing.T) {
 	t.Setenv("NANOBANANA_CONFIG_DIR", t.TempDir())
 
@@ -71,3 +112,64 @@ func TestClearAPIKey(t *testing.T) {
 		t.Fatalf("loaded.APIKey = %q, want empty", loaded.APIKey)
 	}
 }
+
+func TestPricingSurvivesSave(t *testing.T) {
+	t.Setenv("NANOBANANA_CONFIG_DIR", t.TempDir())
+
+	cfg := &Config{
+		Model:   DefaultModel,
+		Timeout: DefaultTimeout,
+		Pricing: map[string]Price{
+			"gemini-2.5-flash-image": {InputPerMillion: 0.1, ImageOutputPerMillion: 20},
+		},
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := SetAPIKey("test-key"); err != nil {
+		t.Fatalf("SetAPIKey() error = %v", err)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	price, ok := loaded.Pricing["gemini-2.5-flash-image"]
+	if !ok {
+		t.Fatalf("pricing for dotted model ID was not preserved: %v", loaded.Pricing)
+	}
+	if price.ImageOutputPerMillion != 20 {
+		t.Fatalf("ImageOutputPerMillion = %v, want 20", price.ImageOutputPerMillion)
+	}
+}
+
+func TestModelsSurviveSave(t *testing.T) {
+	t.Setenv("NANOBANANA_CONFIG_DIR", t.TempDir())
+
+	grounding := false
+	cfg := &Config{
+		Model:   DefaultModel,
+		Timeout: DefaultTimeout,
+		Models: map[string]ModelConfig{
+			"gemini-4.0-flash-image-preview": {Aliases: []string{"banana4"}, ImageSizes: []string{"1K", "2K"}, Grounding: &grounding},
+		},
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := SetAPIKey("test-key"); err != nil {
+		t.Fatalf("SetAPIKey() error = %v", err)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	m, ok := loaded.Models["gemini-4.0-flash-image-preview"]
+	if !ok {
+		t.Fatalf("model with dotted ID was not preserved: %v", loaded.Models)
+	}
+	if len(m.Aliases) != 1 || len(m.ImageSizes) != 2 || m.Grounding == nil || *m.Grounding || m.Thinking != nil {
+		t.Fatalf("model = %+v", m)
+	}
+}
@@ -0,0 +1,170 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Backend names accepted by ParseBackend.
+const (
+	BackendREST   = "rest"
+	BackendVertex = "vertex"
+)
+
+// ParseBackend normalizes a backend name, defaulting to REST.
+func ParseBackend(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", BackendREST:
+		return BackendREST, nil
+	case BackendVertex:
+		return BackendVertex, nil
+	default:
+		return "", fmt.Errorf("unknown backend %q (valid: %s, %s)", name, BackendREST, BackendVertex)
+	}
+}
+
+// backend performs a single generateContent call. Request building, retries,
+// candidate fan-out and result extraction stay in Client so every backend
+// behaves the same. streamGenerateContent calls yield with each partial
+// response as it arrives and stops at the first error yield returns.
+type backend interface {
+	generateContent(ctx context.Context, model string, req *apiGenerateContentRequest) (*apiGenerateContentResponse, error)
+	streamGenerateContent(ctx context.Context, model string, req *apiGenerateContentRequest, yield func(*apiGenerateContentResponse) error) error
+}
+
+// restBackend calls the AI Studio REST API with an API key.
+type restBackend struct {
+	c *Client
+}
+
+func (b restBackend) generateContent(ctx context.Context, model string, payload *apiGenerateContentRequest) (*apiGenerateContentResponse, error) {
+	var parsed apiGenerateContentResponse
+	url := fmt.Sprintf("%s/models/%s:generateContent", b.c.baseURL, model)
+	if err := b.c.doJSON(ctx, http.MethodPost, url, payload, &parsed); err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func (b restBackend) streamGenerateContent(ctx context.Context, model string, payload *apiGenerateContentRequest, yield func(*apiGenerateContentResponse) error) error {
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", b.c.baseURL, model)
+	resp, err := b.c.send(ctx, http.MethodPost, url, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	// Image parts arrive as a single base64 event.
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var chunk apiGenerateContentResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream event: %w", err)
+		}
+		if chunk.Error != nil {
+			gerr := &GeminiError{HTTPStatus: chunk.Error.Code, Status: chunk.Error.Status, RawMessage: chunk.Error.Message, Details: chunk.Error.Details}
+			gerr.retryable = isRetryableStatus(chunk.Error.Code)
+			gerr.Code, gerr.Message = errorCodeFor(gerr)
+			return gerr
+		}
+		if err := yield(&chunk); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return b.c.classifyError(err)
+	}
+	return nil
+}
+
+// doJSON sends payload (if any) to an AI Studio REST endpoint and decodes the
+// response into out. Failures are returned as *GeminiError where possible.
+func (c *Client) doJSON(ctx context.Context, method, url string, payload, out any) error {
+	respBody, err := c.do(ctx, method, url, payload)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse API response: %w", err)
+	}
+	return nil
+}
+
+// do performs an authenticated REST call and returns the raw response body.
+func (c *Client) do(ctx context.Context, method, url string, payload any) ([]byte, error) {
+	resp, err := c.send(ctx, method, url, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API response: %w", err)
+	}
+	return respBody, nil
+}
+
+// send performs an authenticated REST call. Error statuses are returned as
+// *GeminiError; otherwise the caller must close the response body.
+func (c *Client) send(ctx context.Context, method, url string, payload any) (*http.Response, error) {
+	var body io.Reader
+	header := http.Header{}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(data)
+		header.Set("Content-Type", "application/json")
+	}
+	return c.sendBody(ctx, method, url, body, header)
+}
+
+// sendBody is send with a raw body and extra request headers.
+func (c *Client) sendBody(ctx context.Context, method, url string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, values := range c.headers {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	for k, values := range header {
+		req.Header[k] = values
+	}
+	req.Header.Set("x-goog-api-key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, c.classifyError(err)
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API response: %w", err)
+		}
+		return nil, c.classifyResponse(resp, respBody)
+	}
+	return resp, nil
+}
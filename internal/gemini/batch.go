@@ -0,0 +1,463 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BatchDiscount is the fraction of the interactive price charged for Batch API requests.
+const BatchDiscount = 0.5
+
+// Batch job states, normalized from the API's BATCH_STATE_* / JOB_STATE_* enums.
+const (
+	BatchPending   = "PENDING"
+	BatchRunning   = "RUNNING"
+	BatchSucceeded = "SUCCEEDED"
+	BatchFailed    = "FAILED"
+	BatchCancelled = "CANCELLED"
+	BatchExpired   = "EXPIRED"
+)
+
+// inlineBatchLimit is the largest batchGenerateContent body the API accepts
+// with the requests inlined. Larger batches are uploaded as a JSONL file.
+var inlineBatchLimit = 20 * 1024 * 1024
+
+// BatchRequest is one prompt submitted through the Batch API. Key identifies
+// its results; Options.Count expands into that many requests.
+type BatchRequest struct {
+	Key     string
+	Prompt  string
+	Options *GenerateOptions
+}
+
+// BatchStats counts requests in a batch job by outcome.
+type BatchStats struct {
+	RequestCount           int `json:"request_count"`
+	SuccessfulRequestCount int `json:"successful_request_count"`
+	FailedRequestCount     int `json:"failed_request_count"`
+	PendingRequestCount    int `json:"pending_request_count"`
+}
+
+// BatchJob describes a submitted batch.
+type BatchJob struct {
+	Name        string     `json:"name"`
+	DisplayName string     `json:"display_name,omitempty"`
+	Model       string     `json:"model,omitempty"`
+	State       string     `json:"state"`
+	Done        bool       `json:"done"`
+	CreateTime  time.Time  `json:"create_time,omitzero"`
+	UpdateTime  time.Time  `json:"update_time,omitzero"`
+	Stats       BatchStats `json:"stats"`
+	Error       string     `json:"error,omitempty"`
+
+	inlined       []apiInlinedResponse
+	responsesFile string
+}
+
+// BatchResult is the merged outcome of one BatchRequest. Err is set when every
+// candidate for the key failed; partial failures are in Result.Errors.
+type BatchResult struct {
+	Key    string
+	Result *GenerateResult
+	Err    error
+}
+
+type apiBatchRequest struct {
+	Batch apiBatch `json:"batch"`
+}
+
+type apiBatch struct {
+	DisplayName string         `json:"display_name,omitempty"`
+	InputConfig apiBatchInputs `json:"input_config"`
+}
+
+type apiBatchInputs struct {
+	Requests *apiInlinedRequests `json:"requests,omitempty"`
+	FileName string              `json:"file_name,omitempty"`
+}
+
+type apiInlinedRequests struct {
+	Requests []apiInlinedRequest `json:"requests"`
+}
+
+type apiInlinedRequest struct {
+	Request  *apiGenerateContentRequest `json:"request"`
+	Metadata map[string]string          `json:"metadata,omitempty"`
+}
+
+// apiBatchFileLine is one line of a JSONL batch input file.
+type apiBatchFileLine struct {
+	Key     string                     `json:"key"`
+	Request *apiGenerateContentRequest `json:"request"`
+}
+
+type apiOperation struct {
+	Name     string            `json:"name"`
+	Done     bool              `json:"done"`
+	Metadata *apiBatchMetadata `json:"metadata,omitempty"`
+	Response *apiBatchOutput   `json:"response,omitempty"`
+	Error    *apiErrorBody     `json:"error,omitempty"`
+}
+
+type apiBatchMetadata struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Model       string `json:"model"`
+	State       string `json:"state"`
+	CreateTime  string `json:"createTime"`
+	UpdateTime  string `json:"updateTime"`
+	BatchStats  struct {
+		RequestCount           string `json:"requestCount"`
+		SuccessfulRequestCount string `json:"successfulRequestCount"`
+		FailedRequestCount     string `json:"failedRequestCount"`
+		PendingRequestCount    string `json:"pendingRequestCount"`
+	} `json:"batchStats"`
+}
+
+type apiBatchOutput struct {
+	ResponsesFile    string `json:"responsesFile,omitempty"`
+	InlinedResponses *struct {
+		InlinedResponses []apiInlinedResponse `json:"inlinedResponses"`
+	} `json:"inlinedResponses,omitempty"`
+}
+
+type apiInlinedResponse struct {
+	Response *apiGenerateContentResponse `json:"response,omitempty"`
+	Error    *apiErrorBody               `json:"error,omitempty"`
+	Metadata map[string]string           `json:"metadata,omitempty"`
+	// Lines of a responses file carry the key at the top level.
+	Key string `json:"key,omitempty"`
+}
+
+// SubmitBatch validates every request like Generate and submits them as one
+// asynchronous batch job for the client's model. Requests are inlined unless
+// that exceeds the API's request size limit, in which case they are uploaded
+// as a JSONL file with the Files API first.
+func (c *Client) SubmitBatch(ctx context.Context, displayName string, requests []BatchRequest) (*BatchJob, error) {
+	if err := c.requireREST("batch submission"); err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, &GeminiError{Code: ErrInvalidInput, Message: "a batch needs at least one request"}
+	}
+
+	inlined := &apiInlinedRequests{}
+	for _, req := range requests {
+		opts := req.Options
+		if opts == nil {
+			opts = &GenerateOptions{}
+		}
+		if opts.Count <= 0 {
+			opts.Count = 1
+		}
+		if opts.History != nil {
+			return nil, &GeminiError{Code: ErrInvalidInput, Message: fmt.Sprintf("%s: history is not supported in batches", req.Key)}
+		}
+		if err := c.validateOptions(opts); err != nil {
+			return nil, fmt.Errorf("%s: %w", req.Key, err)
+		}
+		userContent, err := c.buildUserContent(ctx, req.Prompt, opts.InputPaths)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", req.Key, err)
+		}
+		if opts.UploadInputs {
+			if _, err := c.uploadInputs(ctx, userContent, opts.InputPaths); err != nil {
+				return nil, fmt.Errorf("%s: %w", req.Key, err)
+			}
+		}
+		if _, err := c.fitInputs(userContent, opts); err != nil {
+			return nil, fmt.Errorf("%s: %w", req.Key, err)
+		}
+		for i := 0; i < opts.Count; i++ {
+			inlined.Requests = append(inlined.Requests, apiInlinedRequest{
+				Request: &apiGenerateContentRequest{
+					Contents:          []*apiContent{cloneContent(userContent)},
+					SystemInstruction: buildSystemInstruction(opts.SystemInstruction),
+					GenerationConfig:  c.buildGenerationConfig(opts, opts.Sampling.withSeedOffset(i)),
+					Tools:             c.buildTools(opts),
+					SafetySettings:    c.buildSafetySettings(opts),
+				},
+				Metadata: map[string]string{"key": batchKey(req.Key, i)},
+			})
+		}
+	}
+
+	payload := apiBatchRequest{Batch: apiBatch{DisplayName: displayName, InputConfig: apiBatchInputs{Requests: inlined}}}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal batch: %w", err)
+	}
+	if len(body) > inlineBatchLimit {
+		file, err := c.uploadBatchInput(ctx, displayName, inlined.Requests)
+		if err != nil {
+			return nil, err
+		}
+		payload.Batch.InputConfig = apiBatchInputs{FileName: file.Name}
+	}
+
+	var op apiOperation
+	url := fmt.Sprintf("%s/models/%s:batchGenerateContent", c.baseURL, c.model.Spec.ID)
+	if err := c.doJSON(ctx, http.MethodPost, url, payload, &op); err != nil {
+		return nil, err
+	}
+	return translateBatch(&op), nil
+}
+
+// uploadBatchInput uploads requests as a JSONL batch input file, one keyed
+// request per line.
+func (c *Client) uploadBatchInput(ctx context.Context, displayName string, requests []apiInlinedRequest) (*File, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, req := range requests {
+		if err := enc.Encode(apiBatchFileLine{Key: req.Metadata["key"], Request: req.Request}); err != nil {
+			return nil, fmt.Errorf("failed to marshal batch request: %w", err)
+		}
+	}
+	name := "batch-input.jsonl"
+	if displayName != "" {
+		name = displayName + ".jsonl"
+	}
+	file, err := c.UploadFile(ctx, buf.Bytes(), "application/jsonl", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload batch input: %w", err)
+	}
+	return file, nil
+}
+
+// GetBatch returns the current state of a batch job, e.g. "batches/abc123".
+func (c *Client) GetBatch(ctx context.Context, name string) (*BatchJob, error) {
+	if err := c.requireREST("batch status"); err != nil {
+		return nil, err
+	}
+	var op apiOperation
+	if err := c.doJSON(ctx, http.MethodGet, fmt.Sprintf("%s/%s", c.baseURL, batchName(name)), nil, &op); err != nil {
+		return nil, err
+	}
+	return translateBatch(&op), nil
+}
+
+// WaitBatch polls a batch job every interval until it finishes or ctx is
+// done. progress, if set, is called after each poll.
+func (c *Client) WaitBatch(ctx context.Context, name string, interval time.Duration, progress func(*BatchJob)) (*BatchJob, error) {
+	for {
+		job, err := c.GetBatch(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(job)
+		}
+		if job.Done {
+			return job, nil
+		}
+		if err := c.sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// BatchResults downloads the responses of a finished batch job and merges
+// candidates per request key, in key order.
+func (c *Client) BatchResults(ctx context.Context, job *BatchJob) ([]BatchResult, error) {
+	if !job.Done {
+		return nil, fmt.Errorf("batch %s is not finished (state %s)", job.Name, job.State)
+	}
+	if job.State != BatchSucceeded {
+		return nil, fmt.Errorf("batch %s ended with state %s: %s", job.Name, job.State, job.Error)
+	}
+
+	responses := job.inlined
+	if job.responsesFile != "" {
+		lines, err := c.downloadResponses(ctx, job.responsesFile)
+		if err != nil {
+			return nil, err
+		}
+		responses = lines
+	}
+
+	outcomes := map[string][]candidateOutcome{}
+	for _, r := range responses {
+		key := r.Key
+		if key == "" {
+			key = r.Metadata["key"]
+		}
+		base, index := splitBatchKey(key)
+		list := outcomes[base]
+		for len(list) <= index {
+			list = append(list, candidateOutcome{})
+		}
+
+		outcome := candidateOutcome{attempts: 1}
+		switch {
+		case r.Error != nil:
+			gerr := &GeminiError{HTTPStatus: r.Error.Code, Status: r.Error.Status, RawMessage: r.Error.Message, Details: r.Error.Details}
+			gerr.Code, gerr.Message = errorCodeFor(gerr)
+			outcome.err = gerr
+		default:
+			outcome.result, outcome.err = c.extractResult(r.Response)
+		}
+		list[index] = outcome
+		outcomes[base] = list
+	}
+
+	keys := make([]string, 0, len(outcomes))
+	for key := range outcomes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := make([]BatchResult, 0, len(keys))
+	for _, key := range keys {
+		list := outcomes[key]
+		// A candidate whose response is missing from the output still
+		// occupies its slot so later candidates keep their index.
+		for i, outcome := range list {
+			if outcome.result == nil && outcome.err == nil {
+				list[i].err = &GeminiError{Code: ErrAPIError, Message: fmt.Sprintf("no response for candidate %d", i+1)}
+			}
+		}
+		merged := c.mergeCandidates(list)
+		merged.EstimatedCostUSD *= BatchDiscount
+		if len(merged.Images) == 0 {
+			results = append(results, BatchResult{Key: key, Err: firstCandidateError(merged.Errors)})
+			continue
+		}
+		results = append(results, BatchResult{Key: key, Result: merged})
+	}
+	return results, nil
+}
+
+func (c *Client) downloadResponses(ctx context.Context, file string) ([]apiInlinedResponse, error) {
+	downloadURL, err := c.downloadURL(file)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out []apiInlinedResponse
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 256*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var r apiInlinedResponse
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, fmt.Errorf("failed to parse batch responses file: %w", err)
+		}
+		out = append(out, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch responses file: %w", err)
+	}
+	return out, nil
+}
+
+// downloadURL is the media download endpoint for file, which puts /download
+// in front of the API version, the last segment of the client's base URL.
+func (c *Client) downloadURL(file string) (string, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	root, version := path.Split(strings.TrimSuffix(u.Path, "/"))
+	u.Path = path.Join("/", root, "download", version, file) + ":download"
+	u.RawQuery = "alt=media"
+	return u.String(), nil
+}
+
+func (c *Client) requireREST(feature string) error {
+	if _, ok := c.backend.(restBackend); !ok {
+		return &GeminiError{Code: ErrInvalidInput, Message: feature + " is only available on the rest backend"}
+	}
+	return nil
+}
+
+func translateBatch(op *apiOperation) *BatchJob {
+	job := &BatchJob{Name: op.Name, Done: op.Done}
+	if meta := op.Metadata; meta != nil {
+		if job.Name == "" {
+			job.Name = meta.Name
+		}
+		job.DisplayName = meta.DisplayName
+		job.Model = strings.TrimPrefix(meta.Model, "models/")
+		job.State = normalizeBatchState(meta.State)
+		job.CreateTime, _ = time.Parse(time.RFC3339Nano, meta.CreateTime)
+		job.UpdateTime, _ = time.Parse(time.RFC3339Nano, meta.UpdateTime)
+		job.Stats = BatchStats{
+			RequestCount:           atoi(meta.BatchStats.RequestCount),
+			SuccessfulRequestCount: atoi(meta.BatchStats.SuccessfulRequestCount),
+			FailedRequestCount:     atoi(meta.BatchStats.FailedRequestCount),
+			PendingRequestCount:    atoi(meta.BatchStats.PendingRequestCount),
+		}
+	}
+	if op.Error != nil {
+		job.Error = op.Error.Message
+		if job.State == "" {
+			job.State = BatchFailed
+		}
+	}
+	if out := op.Response; out != nil {
+		job.responsesFile = out.ResponsesFile
+		if out.InlinedResponses != nil {
+			job.inlined = out.InlinedResponses.InlinedResponses
+		}
+	}
+	return job
+}
+
+func normalizeBatchState(state string) string {
+	state = strings.TrimPrefix(state, "BATCH_STATE_")
+	return strings.TrimPrefix(state, "JOB_STATE_")
+}
+
+// batchName accepts either "batches/ID" or a bare ID.
+func batchName(name string) string {
+	if strings.HasPrefix(name, "batches/") {
+		return name
+	}
+	return "batches/" + name
+}
+
+func batchKey(key string, candidate int) string {
+	return fmt.Sprintf("%s#%d", key, candidate+1)
+}
+
+func splitBatchKey(key string) (string, int) {
+	i := strings.LastIndex(key, "#")
+	if i < 0 {
+		return key, 0
+	}
+	n, err := strconv.Atoi(key[i+1:])
+	if err != nil || n < 1 {
+		return key, 0
+	}
+	return key[:i], n - 1
+}
+
+func firstCandidateError(errs []CandidateError) error {
+	if len(errs) == 0 {
+		return &GeminiError{Code: ErrNoImageGenerated, Message: "no response for this request"}
+	}
+	return errs[0].Err
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
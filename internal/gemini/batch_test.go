@@ -0,0 +1,212 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini/geminitest"
+)
+
+func TestSubmitBatchExpandsCount(t *testing.T) {
+	var body apiBatchRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-3.1-flash-image-preview:batchGenerateContent" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		io.WriteString(w, `{"name":"batches/b1","metadata":{"model":"models/gemini-3.1-flash-image-preview","state":"BATCH_STATE_PENDING","batchStats":{"requestCount":"3","pendingRequestCount":"3"}}}`)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("k", "banana2", time.Second, WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	job, err := client.SubmitBatch(context.Background(), "nightly", []BatchRequest{
+		{Key: "a", Prompt: "one", Options: &GenerateOptions{Count: 2}},
+		{Key: "b", Prompt: "two"},
+	})
+	if err != nil {
+		t.Fatalf("SubmitBatch: %v", err)
+	}
+	if job.Name != "batches/b1" || job.State != BatchPending || job.Stats.RequestCount != 3 || job.Done {
+		t.Fatalf("job = %+v", job)
+	}
+
+	var keys []string
+	for _, req := range body.Batch.InputConfig.Requests.Requests {
+		keys = append(keys, req.Metadata["key"])
+	}
+	if strings.Join(keys, ",") != "a#1,a#2,b#1" || body.Batch.DisplayName != "nightly" {
+		t.Fatalf("submitted keys = %v, display name = %q", keys, body.Batch.DisplayName)
+	}
+}
+
+func TestSubmitLargeBatchUploadsInputFile(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	limit := inlineBatchLimit
+	inlineBatchLimit = 1024
+	defer func() { inlineBatchLimit = limit }()
+
+	client, err := NewClient("k", "banana2", 30*time.Second, WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	requests := []BatchRequest{{Key: "small", Prompt: "one"}}
+	if _, err := client.SubmitBatch(context.Background(), "small", requests); err != nil {
+		t.Fatalf("SubmitBatch: %v", err)
+	}
+	if srv.Uploads() != 0 {
+		t.Fatalf("a batch under the limit was uploaded")
+	}
+
+	for i := range 20 {
+		requests = append(requests, BatchRequest{Key: fmt.Sprintf("k%02d", i), Prompt: strings.Repeat("a long prompt ", 10), Options: &GenerateOptions{Count: 2}})
+	}
+	job, err := client.SubmitBatch(context.Background(), "large", requests)
+	if err != nil {
+		t.Fatalf("SubmitBatch: %v", err)
+	}
+	if srv.Uploads() != 1 {
+		t.Fatalf("uploads = %d, want the batch input file", srv.Uploads())
+	}
+	if job, err = client.GetBatch(context.Background(), job.Name); err != nil || job.responsesFile == "" {
+		t.Fatalf("GetBatch = %+v, %v", job, err)
+	}
+	results, err := client.BatchResults(context.Background(), job)
+	if err != nil {
+		t.Fatalf("BatchResults: %v", err)
+	}
+	if len(results) != 21 || results[0].Key != "k00" || results[20].Key != "small" {
+		t.Fatalf("results = %d, first %q", len(results), results[0].Key)
+	}
+	if r := results[0]; r.Err != nil || len(r.Result.Images) != 2 {
+		t.Fatalf("k00 = %+v", r)
+	}
+}
+
+func TestBatchResultsFromResponsesFile(t *testing.T) {
+	imageData := testPNG(t)
+	image := func(key string) string {
+		resp, _ := json.Marshal(apiGenerateContentResponse{
+			Candidates: []apiCandidate{{Content: &apiContent{
+				Role:  "model",
+				Parts: []*apiPart{{InlineData: &apiBlob{MIMEType: "image/png", Data: imageData}}},
+			}}},
+			UsageMetadata: &apiUsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 1290, TotalTokenCount: 1300},
+		})
+		return fmt.Sprintf(`{"key":%q,"response":%s}`, key, resp)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1beta/batches/b1":
+			io.WriteString(w, `{"name":"batches/b1","done":true,"metadata":{"state":"BATCH_STATE_SUCCEEDED"},"response":{"responsesFile":"files/out-1"}}`)
+		case "/download/v1beta/files/out-1:download":
+			lines := []string{
+				image("a#2"),
+				image("a#1"),
+				`{"key":"b#1","error":{"code":400,"message":"bad prompt","status":"INVALID_ARGUMENT"}}`,
+			}
+			io.WriteString(w, strings.Join(lines, "\n")+"\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("k", "banana2", time.Second, WithBaseURL(srv.URL+"/v1beta"))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	job, err := client.GetBatch(context.Background(), "b1")
+	if err != nil {
+		t.Fatalf("GetBatch: %v", err)
+	}
+	results, err := client.BatchResults(context.Background(), job)
+	if err != nil {
+		t.Fatalf("BatchResults: %v", err)
+	}
+	if len(results) != 2 || results[0].Key != "a" || results[1].Key != "b" {
+		t.Fatalf("results = %+v", results)
+	}
+
+	a := results[0].Result
+	if a == nil || len(a.Images) != 2 || a.Images[1].Candidate != 1 {
+		t.Fatalf("a = %+v", a)
+	}
+	if full := client.pricing.EstimateCost(a.Usage); a.EstimatedCostUSD != full*BatchDiscount {
+		t.Fatalf("cost = %v, want %v", a.EstimatedCostUSD, full*BatchDiscount)
+	}
+	var gerr *GeminiError
+	if !errors.As(results[1].Err, &gerr) || gerr.Code != ErrInvalidInput {
+		t.Fatalf("b error = %v", results[1].Err)
+	}
+}
+
+func TestDownloadURL(t *testing.T) {
+	for base, want := range map[string]string{
+		DefaultBaseURL:                          "https://generativelanguage.googleapis.com/download/v1beta/files/out-1:download?alt=media",
+		"https://proxy.example/gemini/v1alpha/": "https://proxy.example/gemini/download/v1alpha/files/out-1:download?alt=media",
+		"http://127.0.0.1:8080":                 "http://127.0.0.1:8080/download/files/out-1:download?alt=media",
+	} {
+		client, err := NewClient("test-key", "banana2", time.Second, WithBaseURL(base))
+		if err != nil {
+			t.Fatalf("NewClient: %v", err)
+		}
+		if got, _ := client.downloadURL("files/out-1"); got != want {
+			t.Errorf("downloadURL(%s) = %s, want %s", base, got, want)
+		}
+	}
+}
+
+func TestBatchResultsMissingCandidate(t *testing.T) {
+	imageData := testPNG(t)
+	resp, _ := json.Marshal(apiGenerateContentResponse{
+		Candidates: []apiCandidate{{Content: &apiContent{
+			Role:  "model",
+			Parts: []*apiPart{{InlineData: &apiBlob{MIMEType: "image/png", Data: imageData}}},
+		}}},
+	})
+
+	client, err := NewClient("k", "banana2", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	var inlined []apiInlinedResponse
+	if err := json.Unmarshal([]byte(fmt.Sprintf(`[{"metadata":{"key":"a#2"},"response":%s}]`, resp)), &inlined); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	job := &BatchJob{Name: "batches/b1", State: BatchSucceeded, Done: true, inlined: inlined}
+
+	results, err := client.BatchResults(context.Background(), job)
+	if err != nil {
+		t.Fatalf("BatchResults: %v", err)
+	}
+	a := results[0].Result
+	if len(results) != 1 || a == nil || len(a.Images) != 1 || a.Images[0].Candidate != 1 {
+		t.Fatalf("results = %+v", results)
+	}
+	var gerr *GeminiError
+	if len(a.Errors) != 1 || a.Errors[0].Index != 0 || !errors.As(a.Errors[0].Err, &gerr) || gerr.Code != ErrAPIError {
+		t.Fatalf("errors = %+v", a.Errors)
+	}
+}
+
+func TestBatchUnavailableOnVertex(t *testing.T) {
+	client := &Client{backend: &vertexBackend{}}
+	_, err := client.SubmitBatch(context.Background(), "", []BatchRequest{{Key: "a", Prompt: "x"}})
+	var gerr *GeminiError
+	if !errors.As(err, &gerr) || gerr.Code != ErrInvalidInput {
+		t.Fatalf("err = %v", err)
+	}
+}
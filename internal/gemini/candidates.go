@@ -0,0 +1,142 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+const DefaultConcurrency = 4
+
+// CandidateError records a failed candidate in a multi-candidate request.
+type CandidateError struct {
+	Index int
+	Err   error
+}
+
+type candidateOutcome struct {
+	result   *GenerateResult
+	attempts int
+	err      error
+}
+
+// generateParallel issues opts.Count independent requests with at most
+// opts.Concurrency in flight. Failed candidates are reported in
+// GenerateResult.Errors; an error is returned only if every candidate failed,
+// a request-wide failure occurred, or ctx was cancelled.
+func (c *Client) generateParallel(ctx context.Context, userContent *apiContent, opts *GenerateOptions) (*GenerateResult, error) {
+	limit := opts.Concurrency
+	if limit <= 0 {
+		limit = DefaultConcurrency
+	}
+	limit = min(limit, opts.Count)
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	outcomes := make([]candidateOutcome, opts.Count)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i := range outcomes {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			outcomes[i] = candidateOutcome{err: ctx.Err()}
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			outcome := c.generateCandidate(ctx, i, userContent, opts)
+			outcomes[i] = outcome
+			if isRequestWideError(outcome.err) {
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var firstErr error
+	for _, outcome := range outcomes {
+		if outcome.err == nil {
+			continue
+		}
+		if isRequestWideError(outcome.err) {
+			return nil, outcome.err
+		}
+		if firstErr == nil {
+			firstErr = outcome.err
+		}
+	}
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+
+	merged := c.mergeCandidates(outcomes)
+	if len(merged.Images) == 0 {
+		return nil, firstErr
+	}
+	return merged, nil
+}
+
+// mergeCandidates combines per-candidate outcomes in index order.
+func (c *Client) mergeCandidates(outcomes []candidateOutcome) *GenerateResult {
+	merged := &GenerateResult{Model: c.model.Spec.ID}
+	var first *GenerateResult
+
+	for i, outcome := range outcomes {
+		merged.Attempts += outcome.attempts
+		if outcome.attempts > 0 {
+			merged.Retries += outcome.attempts - 1
+		}
+		if outcome.err != nil {
+			merged.Errors = append(merged.Errors, CandidateError{Index: i, Err: outcome.err})
+			continue
+		}
+
+		result := outcome.result
+		if first == nil {
+			first = result
+		}
+		// Images from one request keep the index of the native candidate
+		// they came from; Count and CandidateCount are never both above 1.
+		for _, img := range result.Images {
+			img.Candidate += i
+		}
+		for _, img := range result.Thoughts {
+			img.Candidate += i
+		}
+		merged.Images = append(merged.Images, result.Images...)
+		merged.Thoughts = append(merged.Thoughts, result.Thoughts...)
+		merged.Texts = append(merged.Texts, result.Texts...)
+		merged.Grounding = result.Grounding
+		merged.History = result.History
+		merged.Usage = merged.Usage.Add(result.Usage)
+	}
+
+	if first != nil {
+		merged.FinishReason = first.FinishReason
+		merged.SafetyRatings = first.SafetyRatings
+		merged.PromptFeedback = first.PromptFeedback
+	}
+	merged.EstimatedCostUSD = c.pricing.EstimateCost(merged.Usage)
+	return merged
+}
+
+// isRequestWideError reports failures that every candidate would hit, such as a
+// bad API key or an invalid request, so in-flight siblings can be cancelled.
+func isRequestWideError(err error) bool {
+	var gerr *GeminiError
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	switch gerr.Code {
+	case ErrInvalidAPIKey, ErrPermissionDenied, ErrInvalidInput, ErrModelNotFound, ErrNotFound, ErrRequestTooLarge:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,164 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGenerateParallelPartialFailure(t *testing.T) {
+	imageData := testPNG(t)
+	var (
+		mu       sync.Mutex
+		calls    int
+		inFlight atomic.Int32
+		peak     atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		calls++
+		call := calls
+		mu.Unlock()
+
+		if call == 2 {
+			w.Write([]byte(`{"candidates":[{"finishReason":"IMAGE_SAFETY"}]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(apiGenerateContentResponse{
+			Candidates: []apiCandidate{{Content: &apiContent{
+				Role:  "model",
+				Parts: []*apiPart{{InlineData: &apiBlob{MIMEType: "image/png", Data: imageData}}},
+			}}},
+		})
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("test-key", "banana2", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.baseURL = srv.URL
+
+	result, err := client.Generate(context.Background(), "a banana", &GenerateOptions{Count: 4, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(result.Images) != 3 || len(result.Errors) != 1 {
+		t.Fatalf("images = %d, errors = %d; want 3 and 1", len(result.Images), len(result.Errors))
+	}
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("peak concurrency = %d, want <= 2", got)
+	}
+	for i := 1; i < len(result.Images); i++ {
+		if result.Images[i].Candidate <= result.Images[i-1].Candidate {
+			t.Fatalf("images are not in candidate order: %d then %d", result.Images[i-1].Candidate, result.Images[i].Candidate)
+		}
+	}
+}
+
+func TestGenerateParallelAbortsOnRequestWideError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":401,"message":"bad key","status":"UNAUTHENTICATED"}}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("test-key", "banana2", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.baseURL = srv.URL
+
+	_, err = client.Generate(context.Background(), "a banana", &GenerateOptions{Count: 6, Concurrency: 1})
+	gerr, ok := err.(*GeminiError)
+	if !ok || gerr.Code != ErrInvalidAPIKey {
+		t.Fatalf("Generate error = %v, want %s", err, ErrInvalidAPIKey)
+	}
+	if got := calls.Load(); got >= 6 {
+		t.Fatalf("calls = %d, want remaining candidates to be skipped", got)
+	}
+}
+
+func TestGenerateSendsSampling(t *testing.T) {
+	imageData := testPNG(t)
+	var (
+		mu    sync.Mutex
+		seeds []int32
+		last  apiGenerateContentRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req apiGenerateContentRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		if req.GenerationConfig.Seed != nil {
+			seeds = append(seeds, *req.GenerationConfig.Seed)
+		}
+		last = req
+		mu.Unlock()
+
+		candidates := max(req.GenerationConfig.CandidateCount, 1)
+		resp := apiGenerateContentResponse{}
+		for range candidates {
+			resp.Candidates = append(resp.Candidates, apiCandidate{Content: &apiContent{
+				Role:  "model",
+				Parts: []*apiPart{{InlineData: &apiBlob{MIMEType: "image/png", Data: imageData}}},
+			}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("test-key", "banana2", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.baseURL = srv.URL
+
+	sampling := Sampling{Temperature: ptr(0.4), TopP: ptr(0.8), TopK: ptr(20), Seed: ptr(int32(7))}
+	result, err := client.Generate(context.Background(), "a banana", &GenerateOptions{Count: 3, Concurrency: 1, Sampling: sampling})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(result.Images) != 3 {
+		t.Fatalf("images = %d, want 3", len(result.Images))
+	}
+	if len(seeds) != 3 || seeds[0] != 7 || seeds[1] != 8 || seeds[2] != 9 {
+		t.Fatalf("seeds = %v, want [7 8 9]", seeds)
+	}
+	config := last.GenerationConfig
+	if *config.Temperature != 0.4 || *config.TopP != 0.8 || *config.TopK != 20 || config.CandidateCount != 0 {
+		t.Fatalf("generationConfig = %+v", config)
+	}
+
+	sampling.CandidateCount = 2
+	result, err = client.Generate(context.Background(), "a banana", &GenerateOptions{Count: 1, Sampling: sampling})
+	if err != nil {
+		t.Fatalf("Generate with candidateCount: %v", err)
+	}
+	if last.GenerationConfig.CandidateCount != 2 {
+		t.Fatalf("candidateCount = %d, want 2", last.GenerationConfig.CandidateCount)
+	}
+	if len(result.Images) != 2 || result.Images[0].Candidate != 0 || result.Images[1].Candidate != 1 {
+		t.Fatalf("images = %+v, want candidates 0 and 1", result.Images)
+	}
+	if result.History == nil || result.History.Sampling == nil || *result.History.Sampling.Seed != 7 {
+		t.Fatalf("history sampling = %+v", result.History)
+	}
+}
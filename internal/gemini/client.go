@@ -10,37 +10,95 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	_ "golang.org/x/image/webp"
 )
 
-const apiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+// DefaultBaseURL is the AI Studio REST endpoint used when no override is set.
+const DefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
 
 type Client struct {
 	httpClient *http.Client
 	apiKey     string
+	baseURL    string
+	headers    http.Header
 	model      ValidatedModel
 	timeout    time.Duration
+	retry      RetryPolicy
+	pricing    ModelPricing
+	trace      io.Writer
+	vertex     *VertexConfig
+	safety     []SafetySetting
+	files      *FileCache
+	backend    backend
+
+	maxInputBytes int64
+	stdin         io.Reader
+	stdinOnce     sync.Once
+	stdinData     []byte
+	stdinErr      error
+	sleep         func(context.Context, time.Duration) error
+}
+
+// ClientOption customizes a Client created by NewClient.
+type ClientOption func(*Client)
+
+// WithPricing overrides the model's built-in price table used for cost estimates.
+func WithPricing(pricing ModelPricing) ClientOption {
+	return func(c *Client) {
+		c.pricing = pricing
+	}
+}
+
+// WithRetryPolicy overrides the default retry behavior for transient API failures.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = policy.normalized()
+	}
 }
 
 type GenerateOptions struct {
-	AspectRatio     string
-	ImageSize       string
-	Count           int
-	InputPaths      []string
+	AspectRatio string
+	ImageSize   string
+	Count       int
+	// InputPaths are reference images: file paths, http(s) URLs, data URIs
+	// or StdinInput.
+	InputPaths []string
+	// FitInputs downscales and re-encodes reference images when the request
+	// would exceed the model's MaxRequestBytes, instead of failing.
+	FitInputs bool
+	// UploadInputs sends reference images through the Files API instead of
+	// inline, reusing uploads recorded in the client's FileCache.
+	UploadInputs    bool
 	GroundWeb       bool
 	GroundImage     bool
 	IncludeThoughts bool
 	ThinkingLevel   string
-	History         *ConversationHistory
+	// SystemInstruction is sent as the request's systemInstruction. When
+	// empty, a continued History's instruction is reused.
+	SystemInstruction string
+	Sampling          Sampling
+	// SafetySettings override the client's WithSafetySettings defaults per
+	// category.
+	SafetySettings []SafetySetting
+	History        *ConversationHistory
+	// Concurrency bounds in-flight requests when Count > 1 without History.
+	Concurrency int
+	// OnEvent, when set, switches to streamGenerateContent and receives parts
+	// as they arrive. Calls are serialized across candidates.
+	OnEvent func(StreamEvent)
 }
 
 type GeneratedImage struct {
+	Candidate        int
 	Data             []byte
 	MimeType         string
 	Width            int
@@ -79,32 +137,65 @@ type GenerateResult struct {
 	Texts     []TextPart
 	Grounding *GroundingMetadata
 	History   *ConversationHistory
+	Attempts  int
+	Retries   int
+
+	FinishReason   string
+	SafetyRatings  []SafetyRating
+	PromptFeedback *PromptFeedback
+
+	Usage            *Usage
+	EstimatedCostUSD float64
+
+	// Errors lists candidates that failed while others succeeded (Count > 1 only).
+	Errors []CandidateError
+	// InputAdjustments lists reference images re-encoded by FitInputs.
+	InputAdjustments []InputAdjustment
+	// InputHashes holds the SHA-256 of each reference image as read, before
+	// FitInputs or UploadInputs change how it is sent.
+	InputHashes []string
 }
 
 type ConversationHistory struct {
-	Model    string        `json:"model"`
+	Model string `json:"model"`
+	// Format is how Save stores images: HistoryInline (the default) or
+	// HistoryExternal.
+	Format string `json:"format,omitempty"`
+	// SystemInstruction is the instruction the conversation was started with.
+	SystemInstruction string `json:"system_instruction,omitempty"`
+	// Sampling records the parameters of the latest turn, including its
+	// effective seed, so the turn can be regenerated.
+	Sampling *Sampling     `json:"sampling,omitempty"`
 	Contents []*apiContent `json:"contents"`
 }
 
 type apiGenerateContentRequest struct {
-	Contents         []*apiContent        `json:"contents"`
-	Tools            []apiTool            `json:"tools,omitempty"`
-	GenerationConfig *apiGenerationConfig `json:"generationConfig,omitempty"`
+	Contents          []*apiContent        `json:"contents"`
+	SystemInstruction *apiContent          `json:"systemInstruction,omitempty"`
+	Tools             []apiTool            `json:"tools,omitempty"`
+	SafetySettings    []apiSafetySetting   `json:"safetySettings,omitempty"`
+	GenerationConfig  *apiGenerationConfig `json:"generationConfig,omitempty"`
 }
 
 type apiGenerateContentResponse struct {
-	Candidates []apiCandidate `json:"candidates"`
-	Error      *apiErrorBody  `json:"error,omitempty"`
+	Candidates     []apiCandidate     `json:"candidates"`
+	PromptFeedback *apiPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *apiUsageMetadata  `json:"usageMetadata,omitempty"`
+	Error          *apiErrorBody      `json:"error,omitempty"`
 }
 
 type apiErrorBody struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Status  string `json:"status"`
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Status  string           `json:"status"`
+	Details []map[string]any `json:"details,omitempty"`
 }
 
 type apiCandidate struct {
 	Content           *apiContent           `json:"content"`
+	FinishReason      string                `json:"finishReason,omitempty"`
+	FinishMessage     string                `json:"finishMessage,omitempty"`
+	SafetyRatings     []apiSafetyRating     `json:"safetyRatings,omitempty"`
 	GroundingMetadata *apiGroundingMetadata `json:"groundingMetadata,omitempty"`
 }
 
@@ -114,15 +205,20 @@ type apiContent struct {
 }
 
 type apiPart struct {
-	Text             string   `json:"text,omitempty"`
-	InlineData       *apiBlob `json:"inline_data,omitempty"`
-	Thought          bool     `json:"thought,omitempty"`
-	ThoughtSignature string   `json:"thought_signature,omitempty"`
+	Text             string       `json:"text,omitempty"`
+	InlineData       *apiBlob     `json:"inline_data,omitempty"`
+	FileData         *apiFileData `json:"file_data,omitempty"`
+	Thought          bool         `json:"thought,omitempty"`
+	ThoughtSignature string       `json:"thought_signature,omitempty"`
 }
 
 type apiBlob struct {
 	MIMEType string `json:"mime_type,omitempty"`
 	Data     []byte `json:"data,omitempty"`
+	// SHA256 and File reference Data stored next to an externalized history
+	// file. They only appear on disk; LoadHistory reads the file back into Data.
+	SHA256 string `json:"sha256,omitempty"`
+	File   string `json:"file,omitempty"`
 }
 
 type apiTool struct {
@@ -140,6 +236,11 @@ type apiSearchTypes struct {
 
 type apiGenerationConfig struct {
 	ResponseModalities []string           `json:"responseModalities,omitempty"`
+	Temperature        *float64           `json:"temperature,omitempty"`
+	TopP               *float64           `json:"topP,omitempty"`
+	TopK               *int               `json:"topK,omitempty"`
+	Seed               *int32             `json:"seed,omitempty"`
+	CandidateCount     int                `json:"candidateCount,omitempty"`
 	ImageConfig        *apiImageConfig    `json:"imageConfig,omitempty"`
 	ThinkingConfig     *apiThinkingConfig `json:"thinkingConfig,omitempty"`
 }
@@ -181,20 +282,50 @@ type apiSearchEntryPoint struct {
 	RenderedContent string `json:"renderedContent,omitempty"`
 }
 
-func NewClient(apiKey, model string, timeout time.Duration) (*Client, error) {
-	if strings.TrimSpace(apiKey) == "" {
-		return nil, fmt.Errorf("API key is required")
-	}
+// NewClient creates a client for model. apiKey is required for the default
+// REST backend and ignored when WithVertex is set.
+func NewClient(apiKey, model string, timeout time.Duration, opts ...ClientOption) (*Client, error) {
 	if timeout <= 0 {
 		timeout = 2 * time.Minute
 	}
 
-	return &Client{
+	c := &Client{
 		httpClient: &http.Client{Timeout: timeout},
 		apiKey:     apiKey,
+		baseURL:    DefaultBaseURL,
 		model:      ResolveModel(model),
 		timeout:    timeout,
-	}, nil
+		retry:      DefaultRetryPolicy(),
+		sleep:      sleepContext,
+
+		maxInputBytes: DefaultMaxInputBytes,
+	}
+	c.pricing = c.model.Spec.Pricing
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.trace != nil {
+		base := c.httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		c.httpClient.Transport = &traceTransport{base: base, out: c.trace, secrets: []string{c.apiKey}}
+	}
+
+	if c.vertex != nil {
+		b, err := newVertexBackend(context.Background(), c, *c.vertex)
+		if err != nil {
+			return nil, err
+		}
+		c.backend = b
+		return c, nil
+	}
+
+	if strings.TrimSpace(apiKey) == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+	c.backend = restBackend{c}
+	return c, nil
 }
 
 func (c *Client) Model() ValidatedModel {
@@ -213,102 +344,184 @@ func (c *Client) Generate(ctx context.Context, prompt string, opts *GenerateOpti
 		return nil, err
 	}
 
-	userContent, err := c.buildUserContent(prompt, opts.InputPaths)
+	userContent, err := c.buildUserContent(ctx, prompt, opts.InputPaths)
 	if err != nil {
 		return nil, err
 	}
-
-	var (
-		allImages   []*GeneratedImage
-		allThoughts []*GeneratedImage
-		allTexts    []TextPart
-		lastGround  *GroundingMetadata
-		lastHistory *ConversationHistory
-	)
-
-	for i := 0; i < opts.Count; i++ {
-		history := opts.History
-		if history != nil {
-			history = history.Clone()
-		}
-
-		contents := []*apiContent{}
-		if history != nil {
-			contents = append(contents, history.Contents...)
+	var hashes []string
+	for _, part := range userContent.Parts {
+		if part.InlineData != nil {
+			hashes = append(hashes, sha256Hex(part.InlineData.Data))
 		}
-		contents = append(contents, cloneContent(userContent))
+	}
+	sent, reused, adjustments, err := c.prepareInputs(ctx, userContent, opts)
+	if err != nil {
+		return nil, err
+	}
 
-		reqBody := &apiGenerateContentRequest{
-			Contents:         contents,
-			GenerationConfig: c.buildGenerationConfig(opts),
-			Tools:            c.buildTools(opts),
+	if onEvent := opts.OnEvent; onEvent != nil {
+		var mu sync.Mutex
+		serialized := *opts
+		serialized.OnEvent = func(event StreamEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			onEvent(event)
 		}
+		opts = &serialized
+	}
 
-		resp, err := c.callGenerateContent(ctx, reqBody)
-		if err != nil {
+	result, err := c.generateContents(ctx, sent, opts)
+	if err != nil && len(reused) > 0 && isStaleUpload(err, reused) {
+		// A cached upload expired, was deleted or is not visible to this
+		// key; forget it and upload the inputs again.
+		c.files.Evict(c.FileScope(), slices.Collect(maps.Keys(reused)))
+		if sent, _, adjustments, err = c.prepareInputs(ctx, userContent, opts); err != nil {
 			return nil, err
 		}
+		result, err = c.generateContents(ctx, sent, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.InputAdjustments = adjustments
+	result.InputHashes = hashes
+	return result, nil
+}
 
-		result, err := c.extractResult(resp)
-		if err != nil {
-			return nil, err
+// prepareInputs returns the user turn as it is sent: with references
+// uploaded when opts.UploadInputs is set, then fitted to the request limit.
+// It also returns the uploads reused from the file cache by content hash.
+func (c *Client) prepareInputs(ctx context.Context, userContent *apiContent, opts *GenerateOptions) (*apiContent, map[string]File, []InputAdjustment, error) {
+	sent := userContent
+	var reused map[string]File
+	if opts.UploadInputs {
+		sent = cloneContent(userContent)
+		var err error
+		if reused, err = c.uploadInputs(ctx, sent, opts.InputPaths); err != nil {
+			return nil, nil, nil, err
 		}
+	}
+	adjustments, err := c.fitInputs(sent, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return sent, reused, adjustments, nil
+}
 
-		history = &ConversationHistory{
-			Model:    c.model.Spec.ID,
-			Contents: append(contents, resp.firstContent()...),
+// generateContents runs opts.Count candidates of the user turn, in parallel
+// when there is no history.
+func (c *Client) generateContents(ctx context.Context, userContent *apiContent, opts *GenerateOptions) (*GenerateResult, error) {
+	if opts.History == nil && opts.Count > 1 {
+		return c.generateParallel(ctx, userContent, opts)
+	}
+	outcomes := make([]candidateOutcome, 0, opts.Count)
+	for i := 0; i < opts.Count; i++ {
+		outcome := c.generateCandidate(ctx, i, userContent, opts)
+		if outcome.err != nil {
+			return nil, outcome.err
 		}
+		outcomes = append(outcomes, outcome)
+	}
+	return c.mergeCandidates(outcomes), nil
+}
 
-		allImages = append(allImages, result.Images...)
-		allThoughts = append(allThoughts, result.Thoughts...)
-		allTexts = append(allTexts, result.Texts...)
-		lastGround = result.Grounding
-		lastHistory = history
+// generateCandidate performs one generateContent round-trip for the user turn,
+// continuing from opts.History when set. index numbers the candidate in
+// stream events.
+func (c *Client) generateCandidate(ctx context.Context, index int, userContent *apiContent, opts *GenerateOptions) candidateOutcome {
+	history := opts.History
+	if history != nil {
+		history = history.Clone()
 	}
 
-	return &GenerateResult{
-		Model:     c.model.Spec.ID,
-		Images:    allImages,
-		Thoughts:  allThoughts,
-		Texts:     allTexts,
-		Grounding: lastGround,
-		History:   lastHistory,
-	}, nil
-}
+	contents := []*apiContent{}
+	if history != nil {
+		contents = append(contents, history.Contents...)
+	}
+	contents = append(contents, cloneContent(userContent))
 
-func (c *Client) SaveImage(img *GeneratedImage, outputPath string) error {
-	dir := filepath.Dir(outputPath)
-	if dir != "" && dir != "." {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
+	system := opts.SystemInstruction
+	if system == "" && history != nil {
+		system = history.SystemInstruction
+	}
+	sampling := opts.Sampling.withSeedOffset(index)
+
+	reqBody := &apiGenerateContentRequest{
+		Contents:          contents,
+		SystemInstruction: buildSystemInstruction(system),
+		GenerationConfig:  c.buildGenerationConfig(opts, sampling),
+		Tools:             c.buildTools(opts),
+		SafetySettings:    c.buildSafetySettings(opts),
+	}
+
+	var emit func(*apiGenerateContentResponse)
+	if opts.OnEvent != nil {
+		emit = func(chunk *apiGenerateContentResponse) {
+			for _, event := range chunkEvents(chunk, index) {
+				opts.OnEvent(event)
+			}
+		}
+	}
+
+	resp, attempts, err := c.generateContentWithRetry(ctx, reqBody, emit)
+	if err != nil {
+		return candidateOutcome{attempts: attempts, err: err}
+	}
+	if opts.OnEvent != nil {
+		done := StreamEvent{Type: EventDone, Candidate: index}
+		if len(resp.Candidates) > 0 {
+			done.FinishReason = resp.Candidates[0].FinishReason
 		}
+		opts.OnEvent(done)
 	}
 
-	if err := os.WriteFile(outputPath, img.Data, 0644); err != nil {
-		return fmt.Errorf("failed to write image: %w", err)
+	result, err := c.extractResult(resp)
+	if err != nil {
+		return candidateOutcome{attempts: attempts, err: err}
 	}
 
-	return nil
+	result.History = &ConversationHistory{
+		Model:             c.model.Spec.ID,
+		SystemInstruction: system,
+		Contents:          append(contents, resp.firstContent()...),
+	}
+	if history != nil {
+		result.History.Format = history.Format
+	}
+	if !sampling.IsZero() {
+		result.History.Sampling = &sampling
+	}
+	return candidateOutcome{result: result, attempts: attempts}
 }
 
 func (c *Client) SaveHistory(history *ConversationHistory, path string) error {
 	if history == nil {
 		return nil
 	}
+	return history.Save(path)
+}
+
+// Save writes the history as JSON, creating parent directories as needed.
+// Externalized histories also write their images to BlobDir(path).
+func (h *ConversationHistory) Save(path string) error {
 	dir := filepath.Dir(path)
 	if dir != "" && dir != "." {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return fmt.Errorf("failed to create history directory: %w", err)
 		}
 	}
-	data, err := json.MarshalIndent(history, "", "  ")
+	onDisk, blobs, err := h.externalize(path)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(onDisk, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal history: %w", err)
 	}
 	if err := os.WriteFile(path, data, 0644); err != nil {
 		return fmt.Errorf("failed to write history file: %w", err)
 	}
-	return nil
+	return pruneBlobs(BlobDir(path), blobs)
 }
 
 func LoadHistory(path string) (*ConversationHistory, error) {
@@ -324,6 +537,9 @@ func LoadHistory(path string) (*ConversationHistory, error) {
 	if len(history.Contents) == 0 {
 		return nil, fmt.Errorf("history file %s does not contain contents", path)
 	}
+	if err := history.rehydrate(path); err != nil {
+		return nil, err
+	}
 	return &history, nil
 }
 
@@ -331,17 +547,23 @@ func (h *ConversationHistory) Clone() *ConversationHistory {
 	if h == nil {
 		return nil
 	}
-	cloned := &ConversationHistory{
-		Model:    h.Model,
-		Contents: make([]*apiContent, 0, len(h.Contents)),
-	}
+	contents := make([]*apiContent, 0, len(h.Contents))
 	for _, content := range h.Contents {
-		cloned.Contents = append(cloned.Contents, cloneContent(content))
+		contents = append(contents, cloneContent(content))
 	}
-	return cloned
+	return h.withContents(contents)
 }
 
 func (c *Client) validateOptions(opts *GenerateOptions) error {
+	if at := listedAt(); !c.model.Known && !at.IsZero() {
+		return &GeminiError{
+			Code:    ErrModelNotFound,
+			Message: fmt.Sprintf("model %s is not in the model list fetched %s or declared in config", c.model.Spec.ID, at.Format(time.DateOnly)),
+		}
+	}
+	if c.model.Spec.TextOnly {
+		return &GeminiError{Code: ErrInvalidInput, Message: fmt.Sprintf("model %s is not an image model; list image models with: nanobanana models", c.model.Spec.ID)}
+	}
 	if err := ValidateAspectRatio(c.model.Spec, opts.AspectRatio); err != nil {
 		return &GeminiError{Code: ErrInvalidInput, Message: err.Error()}
 	}
@@ -364,6 +586,15 @@ func (c *Client) validateOptions(opts *GenerateOptions) error {
 	if opts.GroundImage && !c.model.Spec.SupportsImageSearch {
 		return &GeminiError{Code: ErrInvalidInput, Message: fmt.Sprintf("model %s does not support Google Image Search grounding", c.model.Spec.ID)}
 	}
+	if err := ValidateSampling(c.model.Spec, opts.Sampling); err != nil {
+		return &GeminiError{Code: ErrInvalidInput, Message: err.Error()}
+	}
+	if opts.Sampling.CandidateCount > 1 && opts.Count > 1 {
+		return &GeminiError{Code: ErrInvalidInput, Message: "use either a candidate count or a count above 1, not both"}
+	}
+	if opts.Sampling.CandidateCount > 1 && opts.OnEvent != nil {
+		return &GeminiError{Code: ErrInvalidInput, Message: "streaming does not support a candidate count above 1"}
+	}
 	if opts.IncludeThoughts && !c.model.Spec.SupportsThinking {
 		return &GeminiError{Code: ErrInvalidInput, Message: fmt.Sprintf("model %s does not support thought output", c.model.Spec.ID)}
 	}
@@ -382,16 +613,16 @@ func (c *Client) validateOptions(opts *GenerateOptions) error {
 	return nil
 }
 
-func (c *Client) buildUserContent(prompt string, inputPaths []string) (*apiContent, error) {
+func (c *Client) buildUserContent(ctx context.Context, prompt string, inputs []string) (*apiContent, error) {
 	parts := []*apiPart{{Text: prompt}}
-	for _, inputPath := range inputPaths {
-		data, err := os.ReadFile(inputPath)
+	for _, input := range inputs {
+		data, mimeType, err := c.readInput(ctx, input)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read input image %s: %w", inputPath, err)
+			return nil, err
 		}
 		parts = append(parts, &apiPart{
 			InlineData: &apiBlob{
-				MIMEType: detectMimeType(inputPath),
+				MIMEType: mimeType,
 				Data:     data,
 			},
 		})
@@ -402,9 +633,27 @@ func (c *Client) buildUserContent(prompt string, inputPaths []string) (*apiConte
 	}, nil
 }
 
-func (c *Client) buildGenerationConfig(opts *GenerateOptions) *apiGenerationConfig {
+// buildSystemInstruction wraps a system instruction in a content, or returns
+// nil when there is none.
+func buildSystemInstruction(text string) *apiContent {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	return &apiContent{Parts: []*apiPart{{Text: text}}}
+}
+
+// buildGenerationConfig builds the generation config for one request. sampling
+// is passed separately so each of several candidates can get its own seed.
+func (c *Client) buildGenerationConfig(opts *GenerateOptions, sampling Sampling) *apiGenerationConfig {
 	cfg := &apiGenerationConfig{
 		ResponseModalities: []string{"TEXT", "IMAGE"},
+		Temperature:        sampling.Temperature,
+		TopP:               sampling.TopP,
+		TopK:               sampling.TopK,
+		Seed:               sampling.Seed,
+	}
+	if sampling.CandidateCount > 1 {
+		cfg.CandidateCount = sampling.CandidateCount
 	}
 
 	if opts.AspectRatio != "" || opts.ImageSize != "" {
@@ -440,86 +689,91 @@ func (c *Client) buildTools(opts *GenerateOptions) []apiTool {
 	return []apiTool{{GoogleSearch: search}}
 }
 
-func (c *Client) callGenerateContent(ctx context.Context, payload *apiGenerateContentRequest) (*apiGenerateContentResponse, error) {
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", apiBaseURL, c.model.Spec.ID, c.apiKey)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, c.classifyError(err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read API response: %w", err)
-	}
-
-	var parsed apiGenerateContentResponse
-	if err := json.Unmarshal(respBody, &parsed); err != nil {
-		if resp.StatusCode >= 400 {
-			return nil, c.classifyError(fmt.Errorf("api error: %s", string(respBody)))
+// generateContentWithRetry calls the backend, retrying transient failures per the
+// client's RetryPolicy. It returns the number of attempts made. When emit is
+// set the response is streamed to it; a stream that fails after emitting
+// anything is not retried.
+func (c *Client) generateContentWithRetry(ctx context.Context, payload *apiGenerateContentRequest, emit func(*apiGenerateContentResponse)) (*apiGenerateContentResponse, int, error) {
+	for attempt := 1; ; attempt++ {
+		var resp *apiGenerateContentResponse
+		var err error
+		streamed := false
+		if emit != nil {
+			resp, err = c.streamContent(ctx, payload, func(chunk *apiGenerateContentResponse) {
+				streamed = true
+				emit(chunk)
+			})
+		} else {
+			resp, err = c.backend.generateContent(ctx, c.model.Spec.ID, payload)
 		}
-		return nil, fmt.Errorf("failed to parse API response: %w", err)
-	}
-
-	if resp.StatusCode >= 400 {
-		if parsed.Error != nil {
-			return nil, c.classifyError(fmt.Errorf("%s (%s)", parsed.Error.Message, parsed.Error.Status))
+		if err == nil {
+			return resp, attempt, nil
+		}
+		if streamed {
+			return nil, attempt, err
+		}
+		delay, ok := c.retry.nextDelay(attempt, err)
+		if !ok {
+			return nil, attempt, err
+		}
+		if err := c.sleep(ctx, delay); err != nil {
+			return nil, attempt, err
 		}
-		return nil, c.classifyError(fmt.Errorf("api error: %s", resp.Status))
 	}
-
-	return &parsed, nil
 }
 
 func (c *Client) extractResult(resp *apiGenerateContentResponse) (*GenerateResult, error) {
-	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
-		return nil, &GeminiError{Code: ErrNoImageGenerated, Message: "empty response from API"}
+	if resp == nil || len(resp.Candidates) == 0 {
+		return nil, noImageError(resp, nil)
 	}
 
 	candidate := resp.Candidates[0]
-	result := &GenerateResult{Model: c.model.Spec.ID}
-
-	for _, part := range candidate.Content.Parts {
-		if part == nil {
+	result := &GenerateResult{
+		Model:          c.model.Spec.ID,
+		FinishReason:   candidate.FinishReason,
+		SafetyRatings:  translateSafetyRatings(candidate.SafetyRatings),
+		PromptFeedback: translatePromptFeedback(resp.PromptFeedback),
+		Usage:          translateUsage(resp.UsageMetadata),
+	}
+
+	// Several candidates are only returned when Sampling.CandidateCount asks
+	// for them; their images are numbered by candidate.
+	for index, cand := range resp.Candidates {
+		if cand.Content == nil {
 			continue
 		}
-		if part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/") {
-			img := &GeneratedImage{
-				Data:             part.InlineData.Data,
-				MimeType:         part.InlineData.MIMEType,
-				Thought:          part.Thought,
-				ThoughtSignature: part.ThoughtSignature,
+		for _, part := range cand.Content.Parts {
+			if part == nil {
+				continue
 			}
-			img.Width, img.Height = imageDimensions(img.Data)
-			if part.Thought {
-				result.Thoughts = append(result.Thoughts, img)
-			} else {
-				result.Images = append(result.Images, img)
+			if part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/") {
+				img := &GeneratedImage{
+					Candidate:        index,
+					Data:             part.InlineData.Data,
+					MimeType:         part.InlineData.MIMEType,
+					Thought:          part.Thought,
+					ThoughtSignature: part.ThoughtSignature,
+				}
+				img.Width, img.Height = imageDimensions(img.Data)
+				if part.Thought {
+					result.Thoughts = append(result.Thoughts, img)
+				} else {
+					result.Images = append(result.Images, img)
+				}
+				continue
+			}
+			if part.Text != "" {
+				result.Texts = append(result.Texts, TextPart{
+					Text:             part.Text,
+					Thought:          part.Thought,
+					ThoughtSignature: part.ThoughtSignature,
+				})
 			}
-			continue
-		}
-		if part.Text != "" {
-			result.Texts = append(result.Texts, TextPart{
-				Text:             part.Text,
-				Thought:          part.Thought,
-				ThoughtSignature: part.ThoughtSignature,
-			})
 		}
 	}
 
 	if len(result.Images) == 0 {
-		return nil, &GeminiError{Code: ErrNoImageGenerated, Message: "no final images were returned by the API"}
+		return nil, noImageError(resp, result.Texts)
 	}
 
 	if candidate.GroundingMetadata != nil {
@@ -594,6 +848,10 @@ func cloneContent(content *apiContent) *apiContent {
 				Data:     data,
 			}
 		}
+		if part.FileData != nil {
+			fd := *part.FileData
+			cp.FileData = &fd
+		}
 		cloned.Parts = append(cloned.Parts, cp)
 	}
 	return cloned
@@ -623,56 +881,3 @@ func normalizeThinkingLevel(level string) string {
 		return level
 	}
 }
-
-const (
-	ErrInvalidAPIKey    = "INVALID_API_KEY"
-	ErrQuotaExceeded    = "QUOTA_EXCEEDED"
-	ErrRateLimited      = "RATE_LIMITED"
-	ErrInvalidInput     = "INVALID_INPUT"
-	ErrSafetyBlocked    = "SAFETY_BLOCKED"
-	ErrAPIError         = "API_ERROR"
-	ErrNoImageGenerated = "NO_IMAGE_GENERATED"
-)
-
-type GeminiError struct {
-	Code    string
-	Message string
-}
-
-func (e *GeminiError) Error() string {
-	return fmt.Sprintf("[%s] %s", e.Code, e.Message)
-}
-
-func (c *Client) classifyError(err error) error {
-	errStr := strings.ToLower(err.Error())
-	switch {
-	case strings.Contains(errStr, "api key"), strings.Contains(errStr, "unauthorized"), strings.Contains(errStr, "permission_denied"), strings.Contains(errStr, "401"):
-		return &GeminiError{Code: ErrInvalidAPIKey, Message: "invalid or unauthorized API key"}
-	case strings.Contains(errStr, "quota"), strings.Contains(errStr, "resource_exhausted"), strings.Contains(errStr, "429"):
-		return &GeminiError{Code: ErrQuotaExceeded, Message: "API quota exceeded"}
-	case strings.Contains(errStr, "rate limit"):
-		return &GeminiError{Code: ErrRateLimited, Message: "rate limit exceeded, please wait"}
-	case strings.Contains(errStr, "safety"), strings.Contains(errStr, "blocked"):
-		return &GeminiError{Code: ErrSafetyBlocked, Message: "content blocked by safety filters"}
-	case strings.Contains(errStr, "invalid"), strings.Contains(errStr, "unsupported"), strings.Contains(errStr, "400"):
-		return &GeminiError{Code: ErrInvalidInput, Message: strings.TrimSpace(err.Error())}
-	default:
-		return &GeminiError{Code: ErrAPIError, Message: strings.TrimSpace(err.Error())}
-	}
-}
-
-func detectMimeType(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
-	switch ext {
-	case ".png":
-		return "image/png"
-	case ".jpg", ".jpeg":
-		return "image/jpeg"
-	case ".gif":
-		return "image/gif"
-	case ".webp":
-		return "image/webp"
-	default:
-		return "image/png"
-	}
-}
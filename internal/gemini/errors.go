@@ -0,0 +1,269 @@
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	ErrInvalidAPIKey      = "INVALID_API_KEY"
+	ErrPermissionDenied   = "PERMISSION_DENIED"
+	ErrQuotaExceeded      = "QUOTA_EXCEEDED"
+	ErrRateLimited        = "RATE_LIMITED"
+	ErrInvalidInput       = "INVALID_INPUT"
+	ErrModelNotFound      = "MODEL_NOT_FOUND"
+	ErrNotFound           = "NOT_FOUND"
+	ErrSafetyBlocked      = "SAFETY_BLOCKED"
+	ErrServerError        = "SERVER_ERROR"
+	ErrServiceUnavailable = "SERVICE_UNAVAILABLE"
+	ErrTimeout            = "TIMEOUT"
+	ErrNetwork            = "NETWORK_ERROR"
+	ErrAPIError           = "API_ERROR"
+	ErrNoImageGenerated   = "NO_IMAGE_GENERATED"
+	ErrRequestTooLarge    = "REQUEST_TOO_LARGE"
+)
+
+// GeminiError describes a failed API call. HTTPStatus, Status, RawMessage and
+// Details mirror the API's error body when one was returned.
+type GeminiError struct {
+	Code       string
+	Message    string
+	HTTPStatus int
+	Status     string
+	RawMessage string
+	Details    []map[string]any
+
+	// Set when a successful response carried no image.
+	FinishReason  string
+	BlockReason   string
+	SafetyRatings []SafetyRating
+	// BlockedCategory is the harm category that most likely caused a safety
+	// block, when the ratings identify one.
+	BlockedCategory string
+
+	retryable  bool
+	retryAfter time.Duration
+}
+
+func (e *GeminiError) Error() string {
+	return fmt.Sprintf("[%s] %s", e.Code, e.Message)
+}
+
+// Reason returns the google.rpc.ErrorInfo reason attached to the error, if any.
+func (e *GeminiError) Reason() string {
+	if info := findErrorDetail(e.Details, "google.rpc.ErrorInfo"); info != nil {
+		reason, _ := info["reason"].(string)
+		return reason
+	}
+	return ""
+}
+
+// DetailMap flattens the structured error fields into string pairs for output.
+func (e *GeminiError) DetailMap() map[string]string {
+	out := map[string]string{}
+	if e.HTTPStatus != 0 {
+		out["http_status"] = strconv.Itoa(e.HTTPStatus)
+	}
+	if e.Status != "" {
+		out["status"] = e.Status
+	}
+	if e.RawMessage != "" && e.RawMessage != e.Message {
+		out["api_message"] = e.RawMessage
+	}
+	if info := findErrorDetail(e.Details, "google.rpc.ErrorInfo"); info != nil {
+		if reason, _ := info["reason"].(string); reason != "" {
+			out["reason"] = reason
+		}
+		if domain, _ := info["domain"].(string); domain != "" {
+			out["domain"] = domain
+		}
+		if metadata, ok := info["metadata"].(map[string]any); ok {
+			keys := make([]string, 0, len(metadata))
+			for k := range metadata {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				out["metadata."+k] = fmt.Sprint(metadata[k])
+			}
+		}
+	}
+	if quota := findErrorDetail(e.Details, "google.rpc.QuotaFailure"); quota != nil {
+		if violations, ok := quota["violations"].([]any); ok && len(violations) > 0 {
+			if v, ok := violations[0].(map[string]any); ok {
+				if metric, _ := v["quotaMetric"].(string); metric != "" {
+					out["quota_metric"] = metric
+				}
+				if id, _ := v["quotaId"].(string); id != "" {
+					out["quota_id"] = id
+				}
+			}
+		}
+	}
+	if e.retryAfter > 0 {
+		out["retry_after"] = e.retryAfter.String()
+	}
+	if e.FinishReason != "" {
+		out["finish_reason"] = e.FinishReason
+	}
+	if e.BlockReason != "" {
+		out["block_reason"] = e.BlockReason
+	}
+	if e.BlockedCategory != "" {
+		out["blocked_category"] = e.BlockedCategory
+	}
+	for _, rating := range e.SafetyRatings {
+		if rating.Probability != "" {
+			out["safety."+rating.Category] = rating.Probability
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// classifyError wraps a transport-level failure where no HTTP response was received.
+func (c *Client) classifyError(err error) *GeminiError {
+	raw := redactSecrets(strings.TrimSpace(err.Error()), c.apiKey)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &GeminiError{Code: ErrTimeout, Message: "request to the Gemini API timed out", RawMessage: raw, retryable: true}
+	}
+	return &GeminiError{Code: ErrNetwork, Message: raw, RawMessage: raw, retryable: isTransientNetworkError(err)}
+}
+
+// isTransientNetworkError reports whether a transport failure may succeed on
+// retry: refused or reset connections and temporary DNS failures. TLS
+// failures, malformed URLs and unknown hosts fail the same way every time.
+func isTransientNetworkError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+	}
+	return false
+}
+
+// classifyResponse derives a GeminiError from a non-2xx HTTP response using the
+// status code, the API status enum and the structured error details.
+func (c *Client) classifyResponse(resp *http.Response, body []byte) *GeminiError {
+	gerr := &GeminiError{
+		HTTPStatus: resp.StatusCode,
+		retryable:  isRetryableStatus(resp.StatusCode),
+	}
+
+	var parsed struct {
+		Error *apiErrorBody `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Error != nil {
+		gerr.Status = parsed.Error.Status
+		gerr.RawMessage = parsed.Error.Message
+		gerr.Details = parsed.Error.Details
+	} else {
+		gerr.RawMessage = strings.TrimSpace(string(body))
+	}
+
+	gerr.retryAfter = retryDelayFromDetails(gerr.Details)
+	if headerDelay := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); headerDelay > 0 {
+		gerr.retryAfter = headerDelay
+	}
+
+	gerr.RawMessage = redactSecrets(gerr.RawMessage, c.apiKey)
+	gerr.Code, gerr.Message = errorCodeFor(gerr)
+	return gerr
+}
+
+func errorCodeFor(e *GeminiError) (string, string) {
+	message := e.RawMessage
+	if message == "" {
+		message = http.StatusText(e.HTTPStatus)
+	}
+
+	switch e.Reason() {
+	case "API_KEY_INVALID", "API_KEY_EXPIRED", "API_KEY_SERVICE_BLOCKED":
+		return ErrInvalidAPIKey, "invalid or unauthorized API key"
+	}
+
+	if e.HTTPStatus == http.StatusRequestEntityTooLarge {
+		return ErrRequestTooLarge, message
+	}
+
+	status := e.Status
+	if status == "" {
+		status = statusForHTTP(e.HTTPStatus)
+	}
+
+	switch status {
+	case "UNAUTHENTICATED":
+		return ErrInvalidAPIKey, "invalid or unauthorized API key"
+	case "PERMISSION_DENIED":
+		return ErrPermissionDenied, message
+	case "RESOURCE_EXHAUSTED":
+		if findErrorDetail(e.Details, "google.rpc.QuotaFailure") != nil {
+			return ErrQuotaExceeded, message
+		}
+		return ErrRateLimited, message
+	case "INVALID_ARGUMENT", "FAILED_PRECONDITION", "OUT_OF_RANGE":
+		return ErrInvalidInput, message
+	case "NOT_FOUND":
+		// Unknown models are named as models/ID; anything else, such as an
+		// expired file or a mistyped batch, is a plain NOT_FOUND.
+		if strings.Contains(message, "models/") {
+			return ErrModelNotFound, message
+		}
+		return ErrNotFound, message
+	case "UNAVAILABLE":
+		return ErrServiceUnavailable, message
+	case "DEADLINE_EXCEEDED":
+		return ErrTimeout, message
+	case "INTERNAL", "UNKNOWN", "DATA_LOSS":
+		return ErrServerError, message
+	default:
+		return ErrAPIError, message
+	}
+}
+
+// statusForHTTP maps an HTTP status to the canonical google.rpc code name used
+// when the response body does not carry one.
+func statusForHTTP(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusInternalServerError:
+		return "INTERNAL"
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		return ""
+	}
+}
+
+func findErrorDetail(details []map[string]any, typeSuffix string) map[string]any {
+	for _, detail := range details {
+		if t, _ := detail["@type"].(string); strings.HasSuffix(t, typeSuffix) {
+			return detail
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package gemini
+
+import (
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantCode   string
+		wantReason string
+	}{
+		{
+			name:       "invalid api key reported as 400",
+			status:     http.StatusBadRequest,
+			body:       `{"error":{"code":400,"message":"API key not valid. Please pass a valid API key.","status":"INVALID_ARGUMENT","details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"API_KEY_INVALID","domain":"googleapis.com"}]}}`,
+			wantCode:   ErrInvalidAPIKey,
+			wantReason: "API_KEY_INVALID",
+		},
+		{
+			name:     "prompt mentioning blocked is still invalid input",
+			status:   http.StatusBadRequest,
+			body:     `{"error":{"code":400,"message":"Unsupported aspect ratio for blocked-style prompt","status":"INVALID_ARGUMENT"}}`,
+			wantCode: ErrInvalidInput,
+		},
+		{
+			name:     "quota failure",
+			status:   http.StatusTooManyRequests,
+			body:     `{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.QuotaFailure","violations":[{"quotaMetric":"generativelanguage.googleapis.com/generate_requests","quotaId":"PerMinute"}]}]}}`,
+			wantCode: ErrQuotaExceeded,
+		},
+		{
+			name:     "rate limit without quota details",
+			status:   http.StatusTooManyRequests,
+			body:     `{"error":{"code":429,"message":"Too many requests","status":"RESOURCE_EXHAUSTED"}}`,
+			wantCode: ErrRateLimited,
+		},
+		{
+			name:     "unknown model",
+			status:   http.StatusNotFound,
+			body:     `{"error":{"code":404,"message":"models/foo is not found","status":"NOT_FOUND"}}`,
+			wantCode: ErrModelNotFound,
+		},
+		{
+			name:     "missing file",
+			status:   http.StatusNotFound,
+			body:     `{"error":{"code":404,"message":"File files/abc123 is not found","status":"NOT_FOUND"}}`,
+			wantCode: ErrNotFound,
+		},
+		{
+			name:     "non-json gateway error",
+			status:   http.StatusBadGateway,
+			body:     `<html>bad gateway</html>`,
+			wantCode: ErrServiceUnavailable,
+		},
+	}
+
+	client, err := NewClient("test-key", "banana2", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tc.status, Header: http.Header{}}
+			gerr := client.classifyResponse(resp, []byte(tc.body))
+			if gerr.Code != tc.wantCode {
+				t.Fatalf("Code = %s, want %s", gerr.Code, tc.wantCode)
+			}
+			if gerr.HTTPStatus != tc.status {
+				t.Fatalf("HTTPStatus = %d, want %d", gerr.HTTPStatus, tc.status)
+			}
+			if gerr.Reason() != tc.wantReason {
+				t.Fatalf("Reason() = %q, want %q", gerr.Reason(), tc.wantReason)
+			}
+			if got := gerr.DetailMap()["http_status"]; got == "" {
+				t.Fatal("DetailMap() is missing http_status")
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	dial := func(err error) error {
+		return &url.Error{Op: "Post", URL: DefaultBaseURL, Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	tests := []struct {
+		name          string
+		err           error
+		wantCode      string
+		wantRetryable bool
+	}{
+		{"timeout", dial(&net.DNSError{Err: "i/o timeout", IsTimeout: true}), ErrTimeout, true},
+		{"connection refused", dial(os.NewSyscallError("connect", syscall.ECONNREFUSED)), ErrNetwork, true},
+		{"connection reset", dial(os.NewSyscallError("read", syscall.ECONNRESET)), ErrNetwork, true},
+		{"temporary dns failure", dial(&net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}), ErrNetwork, true},
+		{"unknown host", dial(&net.DNSError{Err: "no such host", Name: "exmaple.com", IsNotFound: true}), ErrNetwork, false},
+		{"untrusted certificate", &url.Error{Op: "Post", URL: DefaultBaseURL, Err: x509.UnknownAuthorityError{}}, ErrNetwork, false},
+		{"bad url", &url.Error{Op: "Post", URL: "htp://x", Err: errors.New(`unsupported protocol scheme "htp"`)}, ErrNetwork, false},
+	}
+
+	client, err := NewClient("test-key", "banana2", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gerr := client.classifyError(tc.err)
+			if gerr.Code != tc.wantCode || gerr.retryable != tc.wantRetryable {
+				t.Fatalf("got %s retryable=%v, want %s retryable=%v", gerr.Code, gerr.retryable, tc.wantCode, tc.wantRetryable)
+			}
+		})
+	}
+}
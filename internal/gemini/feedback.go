@@ -0,0 +1,141 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ErrPromptBlocked = "PROMPT_BLOCKED"
+	ErrImageSafety   = "IMAGE_SAFETY"
+	ErrRecitation    = "RECITATION"
+	ErrMaxTokens     = "MAX_TOKENS"
+	ErrTextOnly      = "TEXT_ONLY_RESPONSE"
+)
+
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability,omitempty"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+type PromptFeedback struct {
+	BlockReason        string         `json:"block_reason,omitempty"`
+	BlockReasonMessage string         `json:"block_reason_message,omitempty"`
+	SafetyRatings      []SafetyRating `json:"safety_ratings,omitempty"`
+}
+
+type apiPromptFeedback struct {
+	BlockReason        string            `json:"blockReason,omitempty"`
+	BlockReasonMessage string            `json:"blockReasonMessage,omitempty"`
+	SafetyRatings      []apiSafetyRating `json:"safetyRatings,omitempty"`
+}
+
+type apiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability,omitempty"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+func translateSafetyRatings(in []apiSafetyRating) []SafetyRating {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]SafetyRating, 0, len(in))
+	for _, r := range in {
+		out = append(out, SafetyRating{Category: r.Category, Probability: r.Probability, Blocked: r.Blocked})
+	}
+	return out
+}
+
+func translatePromptFeedback(in *apiPromptFeedback) *PromptFeedback {
+	if in == nil {
+		return nil
+	}
+	return &PromptFeedback{
+		BlockReason:        in.BlockReason,
+		BlockReasonMessage: in.BlockReasonMessage,
+		SafetyRatings:      translateSafetyRatings(in.SafetyRatings),
+	}
+}
+
+// noImageError explains why a response carried no final image, using the prompt
+// feedback, the candidate's finish reason and any text the model returned instead.
+func noImageError(resp *apiGenerateContentResponse, texts []TextPart) *GeminiError {
+	gerr := &GeminiError{Code: ErrNoImageGenerated, Message: "no final images were returned by the API"}
+	if resp == nil {
+		gerr.Message = "empty response from API"
+		return gerr
+	}
+
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != "" {
+		gerr.Code = ErrPromptBlocked
+		gerr.Message = fmt.Sprintf("prompt was blocked (%s)", fb.BlockReason)
+		if fb.BlockReasonMessage != "" {
+			gerr.Message += ": " + fb.BlockReasonMessage
+		}
+		gerr.BlockReason = fb.BlockReason
+		gerr.SafetyRatings = translateSafetyRatings(fb.SafetyRatings)
+		if gerr.BlockedCategory = blockingCategory(fb.SafetyRatings); gerr.BlockedCategory != "" {
+			gerr.Message += " in category " + gerr.BlockedCategory
+		}
+		return gerr
+	}
+
+	if len(resp.Candidates) == 0 {
+		gerr.Message = "empty response from API"
+		return gerr
+	}
+
+	candidate := resp.Candidates[0]
+	gerr.FinishReason = candidate.FinishReason
+	gerr.SafetyRatings = translateSafetyRatings(candidate.SafetyRatings)
+
+	switch candidate.FinishReason {
+	case "SAFETY", "PROHIBITED_CONTENT", "BLOCKLIST", "SPII":
+		gerr.Code = ErrSafetyBlocked
+		gerr.Message = fmt.Sprintf("response was blocked by safety filters (%s)", candidate.FinishReason)
+		gerr.BlockedCategory = blockingCategory(candidate.SafetyRatings)
+	case "IMAGE_SAFETY", "IMAGE_PROHIBITED_CONTENT":
+		gerr.Code = ErrImageSafety
+		gerr.Message = fmt.Sprintf("generated image was blocked by safety filters (%s)", candidate.FinishReason)
+		gerr.BlockedCategory = blockingCategory(candidate.SafetyRatings)
+	case "RECITATION", "IMAGE_RECITATION":
+		gerr.Code = ErrRecitation
+		gerr.Message = fmt.Sprintf("response was stopped for reciting existing content (%s)", candidate.FinishReason)
+	case "MAX_TOKENS":
+		gerr.Code = ErrMaxTokens
+		gerr.Message = "response hit the output token limit before an image was produced"
+	default:
+		if text := firstAnswerText(texts); text != "" {
+			gerr.Code = ErrTextOnly
+			gerr.Message = "model answered with text instead of an image: " + text
+		} else if candidate.FinishReason != "" && candidate.FinishReason != "STOP" {
+			gerr.Message = fmt.Sprintf("no final images were returned by the API (%s)", candidate.FinishReason)
+		}
+	}
+	if gerr.BlockedCategory != "" {
+		gerr.Message += " in category " + gerr.BlockedCategory
+	}
+	if candidate.FinishMessage != "" {
+		gerr.Message += ": " + candidate.FinishMessage
+	}
+	return gerr
+}
+
+func firstAnswerText(texts []TextPart) string {
+	for _, t := range texts {
+		if t.Thought {
+			continue
+		}
+		text := strings.TrimSpace(t.Text)
+		if text == "" {
+			continue
+		}
+		if runes := []rune(text); len(runes) > 200 {
+			text = string(runes[:200]) + "..."
+		}
+		return text
+	}
+	return ""
+}
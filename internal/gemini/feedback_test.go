@@ -0,0 +1,131 @@
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExtractResultExplainsMissingImages(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantCode     string
+		wantCategory string
+	}{
+		{
+			name:         "prompt blocked",
+			body:         `{"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH","blocked":true}]}}`,
+			wantCode:     ErrPromptBlocked,
+			wantCategory: "HARM_CATEGORY_DANGEROUS_CONTENT",
+		},
+		{
+			name:         "response safety",
+			body:         `{"candidates":[{"finishReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"LOW"},{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH"}]}]}`,
+			wantCode:     ErrSafetyBlocked,
+			wantCategory: "HARM_CATEGORY_DANGEROUS_CONTENT",
+		},
+		{
+			name:     "image safety",
+			body:     `{"candidates":[{"finishReason":"IMAGE_SAFETY"}]}`,
+			wantCode: ErrImageSafety,
+		},
+		{
+			name:     "recitation",
+			body:     `{"candidates":[{"finishReason":"RECITATION","content":{"role":"model","parts":[]}}]}`,
+			wantCode: ErrRecitation,
+		},
+		{
+			name:     "text only",
+			body:     `{"candidates":[{"finishReason":"STOP","content":{"role":"model","parts":[{"text":"I can't draw that, but here is a description."}]}}]}`,
+			wantCode: ErrTextOnly,
+		},
+		{
+			name:     "empty",
+			body:     `{}`,
+			wantCode: ErrNoImageGenerated,
+		},
+	}
+
+	client, err := NewClient("test-key", "banana2", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp apiGenerateContentResponse
+			if err := json.Unmarshal([]byte(tc.body), &resp); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			_, err := client.extractResult(&resp)
+			var gerr *GeminiError
+			if !errors.As(err, &gerr) {
+				t.Fatalf("extractResult() error = %v, want *GeminiError", err)
+			}
+			if gerr.Code != tc.wantCode {
+				t.Fatalf("Code = %s, want %s (%s)", gerr.Code, tc.wantCode, gerr.Message)
+			}
+			if gerr.BlockedCategory != tc.wantCategory || gerr.DetailMap()["blocked_category"] != tc.wantCategory {
+				t.Fatalf("BlockedCategory = %q, want %q", gerr.BlockedCategory, tc.wantCategory)
+			}
+		})
+	}
+}
+
+func TestExtractResultKeepsFinishReason(t *testing.T) {
+	client, err := NewClient("test-key", "banana2", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	resp := &apiGenerateContentResponse{
+		Candidates: []apiCandidate{{
+			FinishReason:  "STOP",
+			SafetyRatings: []apiSafetyRating{{Category: "HARM_CATEGORY_HARASSMENT", Probability: "NEGLIGIBLE"}},
+			Content: &apiContent{Parts: []*apiPart{
+				{InlineData: &apiBlob{MIMEType: "image/png", Data: testPNG(t)}},
+			}},
+		}},
+	}
+
+	result, err := client.extractResult(resp)
+	if err != nil {
+		t.Fatalf("extractResult: %v", err)
+	}
+	if result.FinishReason != "STOP" || len(result.SafetyRatings) != 1 {
+		t.Fatalf("finish reason = %q, ratings = %v", result.FinishReason, result.SafetyRatings)
+	}
+}
+
+func TestSafetySettings(t *testing.T) {
+	for _, raw := range []string{"dangerous=none", "HARM_CATEGORY_DANGEROUS_CONTENT=BLOCK_NONE", "dangerous-content=None"} {
+		s, err := ParseSafetySetting(raw)
+		if err != nil || s != (SafetySetting{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_NONE"}) {
+			t.Fatalf("ParseSafetySetting(%q) = %+v, %v", raw, s, err)
+		}
+	}
+	for _, raw := range []string{"dangerous", "violence=none", "dangerous=sometimes"} {
+		if _, err := ParseSafetySetting(raw); err == nil {
+			t.Fatalf("ParseSafetySetting(%q) succeeded", raw)
+		}
+	}
+
+	defaults, err := ParseSafetyMap(map[string]string{"sexual": "low", "dangerous": "medium"})
+	if err != nil {
+		t.Fatalf("ParseSafetyMap: %v", err)
+	}
+	client, err := NewClient("test-key", "banana2", time.Second, WithSafetySettings(defaults))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	got := client.buildSafetySettings(&GenerateOptions{SafetySettings: []SafetySetting{{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_NONE"}}})
+	want := []apiSafetySetting{
+		{Category: "HARM_CATEGORY_SEXUALLY_EXPLICIT", Threshold: "BLOCK_LOW_AND_ABOVE"},
+		{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_NONE"},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("buildSafetySettings = %+v, want %+v", got, want)
+	}
+}
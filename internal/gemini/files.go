@@ -0,0 +1,431 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File states reported by the Files API.
+const (
+	FileStateProcessing = "PROCESSING"
+	FileStateActive     = "ACTIVE"
+	FileStateFailed     = "FAILED"
+)
+
+// fileReuseMargin is how long a cached upload must still have to live to be
+// reused, so it does not expire mid-request.
+const fileReuseMargin = time.Hour
+
+// File is a file uploaded with the Files API. Uploads expire after 48 hours.
+type File struct {
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name,omitempty"`
+	MimeType    string    `json:"mime_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	URI         string    `json:"uri"`
+	State       string    `json:"state,omitempty"`
+	SHA256      string    `json:"sha256,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type apiFileData struct {
+	MIMEType string `json:"mime_type,omitempty"`
+	FileURI  string `json:"file_uri"`
+}
+
+type apiFile struct {
+	Name           string    `json:"name"`
+	DisplayName    string    `json:"displayName,omitempty"`
+	MimeType       string    `json:"mimeType,omitempty"`
+	SizeBytes      string    `json:"sizeBytes,omitempty"`
+	CreateTime     time.Time `json:"createTime"`
+	ExpirationTime time.Time `json:"expirationTime"`
+	SHA256Hash     string    `json:"sha256Hash,omitempty"`
+	URI            string    `json:"uri"`
+	State          string    `json:"state,omitempty"`
+}
+
+func (f apiFile) toFile() File {
+	size, _ := strconv.ParseInt(f.SizeBytes, 10, 64)
+	return File{
+		Name:        f.Name,
+		DisplayName: f.DisplayName,
+		MimeType:    f.MimeType,
+		SizeBytes:   size,
+		URI:         f.URI,
+		State:       f.State,
+		SHA256:      f.SHA256Hash,
+		CreatedAt:   f.CreateTime,
+		ExpiresAt:   f.ExpirationTime,
+	}
+}
+
+type apiListFilesResponse struct {
+	Files         []apiFile `json:"files"`
+	NextPageToken string    `json:"nextPageToken,omitempty"`
+}
+
+// WithFileCache records uploads in cache so identical reference images are
+// uploaded once while their upload lives.
+func WithFileCache(cache *FileCache) ClientOption {
+	return func(c *Client) {
+		c.files = cache
+	}
+}
+
+// uploadURL is the media upload endpoint matching the client's base URL, which
+// puts /upload in front of the API version.
+func (c *Client) uploadURL() (string, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	u.Path = "/upload" + strings.TrimSuffix(u.Path, "/") + "/files"
+	return u.String(), nil
+}
+
+// UploadFile uploads data with the Files API's resumable protocol and waits
+// for the file to become active.
+func (c *Client) UploadFile(ctx context.Context, data []byte, mimeType, displayName string) (*File, error) {
+	if err := c.requireREST("file uploads"); err != nil {
+		return nil, err
+	}
+	uploadURL, err := c.uploadURL()
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := json.Marshal(map[string]any{"file": map[string]string{"display_name": displayName}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal file metadata: %w", err)
+	}
+	resp, err := c.sendBody(ctx, http.MethodPost, uploadURL, bytes.NewReader(meta), http.Header{
+		"Content-Type":                        {"application/json"},
+		"X-Goog-Upload-Protocol":              {"resumable"},
+		"X-Goog-Upload-Command":               {"start"},
+		"X-Goog-Upload-Header-Content-Length": {strconv.Itoa(len(data))},
+		"X-Goog-Upload-Header-Content-Type":   {mimeType},
+	})
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	sessionURL := resp.Header.Get("X-Goog-Upload-Url")
+	if sessionURL == "" {
+		return nil, &GeminiError{Code: ErrAPIError, Message: "file upload did not return an upload URL"}
+	}
+
+	resp, err = c.sendBody(ctx, http.MethodPost, sessionURL, bytes.NewReader(data), http.Header{
+		"Content-Type":          {mimeType},
+		"X-Goog-Upload-Command": {"upload, finalize"},
+		"X-Goog-Upload-Offset":  {"0"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var uploaded struct {
+		File apiFile `json:"file"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&uploaded); err != nil {
+		return nil, fmt.Errorf("failed to parse upload response: %w", err)
+	}
+
+	file := uploaded.File.toFile()
+	for file.State == FileStateProcessing {
+		if err := c.sleep(ctx, time.Second); err != nil {
+			return nil, err
+		}
+		next, err := c.GetFile(ctx, file.Name)
+		if err != nil {
+			return nil, err
+		}
+		file = *next
+	}
+	if file.State == FileStateFailed {
+		return nil, &GeminiError{Code: ErrInvalidInput, Message: fmt.Sprintf("the API could not process uploaded file %s", file.Name)}
+	}
+	return &file, nil
+}
+
+// GetFile fetches an uploaded file's metadata by name (files/...).
+func (c *Client) GetFile(ctx context.Context, name string) (*File, error) {
+	if err := c.requireREST("file uploads"); err != nil {
+		return nil, err
+	}
+	var f apiFile
+	if err := c.doJSON(ctx, http.MethodGet, c.baseURL+"/"+fileName(name), nil, &f); err != nil {
+		return nil, err
+	}
+	file := f.toFile()
+	return &file, nil
+}
+
+// ListFiles lists every uploaded file that has not yet expired.
+func (c *Client) ListFiles(ctx context.Context) ([]File, error) {
+	if err := c.requireREST("file uploads"); err != nil {
+		return nil, err
+	}
+	var files []File
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"100"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		var page apiListFilesResponse
+		if err := c.doJSON(ctx, http.MethodGet, c.baseURL+"/files?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		for _, f := range page.Files {
+			files = append(files, f.toFile())
+		}
+		if page.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// DeleteFile deletes an uploaded file by name (files/... or just its ID).
+func (c *Client) DeleteFile(ctx context.Context, name string) error {
+	if err := c.requireREST("file uploads"); err != nil {
+		return err
+	}
+	_, err := c.do(ctx, http.MethodDelete, c.baseURL+"/"+fileName(name), nil)
+	return err
+}
+
+// inputDisplayName names an upload after the input it came from.
+func inputDisplayName(ref string) string {
+	switch {
+	case ref == StdinInput:
+		return "stdin"
+	case isDataURI(ref):
+		return "data-uri"
+	case isURLInput(ref):
+		if u, err := url.Parse(ref); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			return path.Base(u.Path)
+		}
+		return ref
+	default:
+		return filepath.Base(ref)
+	}
+}
+
+func fileName(name string) string {
+	if strings.HasPrefix(name, "files/") {
+		return name
+	}
+	return "files/" + name
+}
+
+// FileScope identifies the account uploads belong to: the base URL and a
+// short hash of the API key, so the key itself is never written to disk.
+func (c *Client) FileScope() string {
+	return c.baseURL + " key:" + sha256Hex([]byte(c.apiKey))[:12]
+}
+
+// uploadInputs replaces the inline reference images in content with fileData
+// parts, uploading each image unless the cache holds a live upload of the
+// same bytes. It returns the uploads reused from the cache by content hash.
+func (c *Client) uploadInputs(ctx context.Context, content *apiContent, inputs []string) (map[string]File, error) {
+	reused := map[string]File{}
+	scope := c.FileScope()
+	n := 0
+	for _, part := range content.Parts {
+		if part.InlineData == nil {
+			continue
+		}
+		blob := part.InlineData
+		name := inputDisplayName(inputs[n])
+		n++
+
+		hash := sha256Hex(blob.Data)
+		file, ok := c.files.Lookup(scope, hash)
+		if ok {
+			reused[hash] = file
+		} else {
+			uploaded, err := c.UploadFile(ctx, blob.Data, blob.MIMEType, name)
+			if err != nil {
+				return nil, err
+			}
+			file = *uploaded
+			// The upload succeeded; a cache that cannot be saved only costs
+			// uploading it again next time.
+			c.files.Put(scope, hash, file)
+		}
+		part.FileData = &apiFileData{MIMEType: blob.MIMEType, FileURI: file.URI}
+		part.InlineData = nil
+	}
+	return reused, nil
+}
+
+// isStaleUpload reports whether err means one of the reused uploads is gone:
+// it expired, or the API answers NOT_FOUND or PERMISSION_DENIED naming it
+// (deleted, or owned by another project). The same codes for anything else,
+// such as an unknown model, leave the cache alone.
+func isStaleUpload(err error, reused map[string]File) bool {
+	for _, file := range reused {
+		if !file.ExpiresAt.After(time.Now()) {
+			return true
+		}
+	}
+	var gerr *GeminiError
+	if !errors.As(err, &gerr) || (gerr.Code != ErrNotFound && gerr.Code != ErrPermissionDenied) {
+		return false
+	}
+	message := gerr.RawMessage + " " + gerr.Message
+	for _, file := range reused {
+		if strings.Contains(message, strings.TrimPrefix(file.Name, "files/")) || (file.URI != "" && strings.Contains(message, file.URI)) {
+			return true
+		}
+	}
+	return false
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FileCache maps the SHA-256 of uploaded content to its Files API upload,
+// per scope (the base URL and API key the upload was made with). It is safe
+// for concurrent use and saves itself after every change. A nil *FileCache
+// caches nothing.
+type FileCache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]File // keyed by cacheKey
+}
+
+func cacheKey(scope, hash string) string {
+	return scope + " " + hash
+}
+
+// OpenFileCache loads the cache stored at path, or starts an empty one if the
+// file does not exist.
+func OpenFileCache(path string) (*FileCache, error) {
+	cache := &FileCache{path: path, entries: map[string]File{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse file cache %s: %w", path, err)
+	}
+	return cache, nil
+}
+
+// Lookup returns the upload of content with the given SHA-256 in scope, if
+// it has at least an hour left to live.
+func (fc *FileCache) Lookup(scope, hash string) (File, bool) {
+	if fc == nil {
+		return File{}, false
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	file, ok := fc.entries[cacheKey(scope, hash)]
+	if !ok || time.Until(file.ExpiresAt) < fileReuseMargin {
+		return File{}, false
+	}
+	return file, true
+}
+
+// Put records an upload in scope and saves the cache, dropping expired
+// entries.
+func (fc *FileCache) Put(scope, hash string, file File) error {
+	if fc == nil {
+		return nil
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.entries[cacheKey(scope, hash)] = file
+	return fc.save()
+}
+
+// Evict drops the uploads of the given hashes in scope and saves the cache.
+func (fc *FileCache) Evict(scope string, hashes []string) error {
+	if fc == nil {
+		return nil
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for _, hash := range hashes {
+		delete(fc.entries, cacheKey(scope, hash))
+	}
+	return fc.save()
+}
+
+// Remove drops the entries in scope for an uploaded file name and saves the
+// cache.
+func (fc *FileCache) Remove(scope, name string) error {
+	if fc == nil {
+		return nil
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for key, file := range fc.entries {
+		if strings.HasPrefix(key, scope+" ") && file.Name == fileName(name) {
+			delete(fc.entries, key)
+		}
+	}
+	return fc.save()
+}
+
+// Hashes maps the file names uploaded in scope to the SHA-256 they are
+// cached under.
+func (fc *FileCache) Hashes(scope string) map[string]string {
+	hashes := map[string]string{}
+	if fc == nil {
+		return hashes
+	}
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for key, file := range fc.entries {
+		if hash, ok := strings.CutPrefix(key, scope+" "); ok {
+			hashes[file.Name] = hash
+		}
+	}
+	return hashes
+}
+
+func (fc *FileCache) save() error {
+	now := time.Now()
+	for hash, file := range fc.entries {
+		if !file.ExpiresAt.After(now) {
+			delete(fc.entries, hash)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(fc.path), 0755); err != nil {
+		return fmt.Errorf("failed to create file cache directory: %w", err)
+	}
+	data, err := json.MarshalIndent(fc.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal file cache: %w", err)
+	}
+	if err := os.WriteFile(fc.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file cache: %w", err)
+	}
+	return nil
+}
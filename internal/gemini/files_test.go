@@ -0,0 +1,116 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/gemini/geminitest"
+)
+
+func TestFileCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "files.json")
+	cache, err := OpenFileCache(path)
+	if err != nil {
+		t.Fatalf("OpenFileCache: %v", err)
+	}
+	now := time.Now()
+	cache.Put("s", "fresh", File{Name: "files/a", URI: "uri-a", ExpiresAt: now.Add(47 * time.Hour)})
+	cache.Put("s", "expiring", File{Name: "files/b", URI: "uri-b", ExpiresAt: now.Add(30 * time.Minute)})
+	cache.Put("s", "expired", File{Name: "files/c", URI: "uri-c", ExpiresAt: now.Add(-time.Minute)})
+
+	reopened, err := OpenFileCache(path)
+	if err != nil {
+		t.Fatalf("OpenFileCache: %v", err)
+	}
+	if f, ok := reopened.Lookup("s", "fresh"); !ok || f.URI != "uri-a" {
+		t.Fatalf("Lookup(fresh) = %+v, %v", f, ok)
+	}
+	if _, ok := reopened.Lookup("s", "expiring"); ok {
+		t.Fatal("an upload about to expire should not be reused")
+	}
+	if _, ok := reopened.Lookup("other", "fresh"); ok {
+		t.Fatal("an upload should not be reused in another scope")
+	}
+	if hashes := reopened.Hashes("s"); len(hashes) != 2 || hashes["files/c"] != "" {
+		t.Fatalf("expired entries should be dropped on save: %v", hashes)
+	}
+
+	if err := reopened.Evict("s", []string{"expiring"}); err != nil {
+		t.Fatalf("Evict: %v", err)
+	}
+	if hashes := reopened.Hashes("s"); len(hashes) != 1 {
+		t.Fatalf("evicted entry still cached: %v", hashes)
+	}
+
+	if err := reopened.Remove("s", "a"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, ok := reopened.Lookup("s", "fresh"); ok {
+		t.Fatal("removed upload still cached")
+	}
+}
+
+func TestUploadSurvivesUnwritableCache(t *testing.T) {
+	srv := geminitest.NewServer()
+	defer srv.Close()
+	dir := t.TempDir()
+	ref := filepath.Join(dir, "ref.png")
+	os.WriteFile(ref, testPNG(t), 0644)
+	cache, err := OpenFileCache(filepath.Join(dir, "cache", "files.json"))
+	if err != nil {
+		t.Fatalf("OpenFileCache: %v", err)
+	}
+	// The cache's directory is now a regular file, so saving it fails.
+	os.WriteFile(filepath.Join(dir, "cache"), nil, 0644)
+
+	client, err := NewClient("test-key", "banana2", time.Second, WithBaseURL(srv.URL), WithFileCache(cache))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := client.Generate(context.Background(), "a banana", &GenerateOptions{InputPaths: []string{ref}, UploadInputs: true}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if reqs := srv.Requests(); srv.Uploads() != 1 || len(reqs) != 1 || reqs[0].InputFiles != 1 {
+		t.Fatalf("uploads = %d, requests = %+v", srv.Uploads(), reqs)
+	}
+}
+
+func TestIsStaleUpload(t *testing.T) {
+	live := map[string]File{"h": {Name: "files/abc123", URI: "https://example.com/v1beta/files/abc123", ExpiresAt: time.Now().Add(time.Hour)}}
+	expired := map[string]File{"h": {Name: "files/abc123", ExpiresAt: time.Now().Add(-time.Minute)}}
+	for _, tc := range []struct {
+		err    error
+		reused map[string]File
+		want   bool
+	}{
+		{&GeminiError{Code: ErrNotFound, RawMessage: "File files/abc123 not found"}, live, true},
+		{&GeminiError{Code: ErrPermissionDenied, RawMessage: "You do not have permission to access the File abc123 or it may not exist."}, live, true},
+		{&GeminiError{Code: ErrModelNotFound, RawMessage: "models/gemini-2.5-flash-imag is not found"}, live, false},
+		{&GeminiError{Code: ErrPermissionDenied, RawMessage: "The caller does not have permission"}, live, false},
+		{&GeminiError{Code: ErrInvalidInput, RawMessage: "bad request"}, expired, true},
+		{fmt.Errorf("network down"), live, false},
+	} {
+		if got := isStaleUpload(tc.err, tc.reused); got != tc.want {
+			t.Errorf("isStaleUpload(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestUploadURL(t *testing.T) {
+	for base, want := range map[string]string{
+		DefaultBaseURL:          "https://generativelanguage.googleapis.com/upload/v1beta/files",
+		"http://127.0.0.1:8080": "http://127.0.0.1:8080/upload/files",
+	} {
+		client, err := NewClient("test-key", "banana2", time.Second, WithBaseURL(base))
+		if err != nil {
+			t.Fatalf("NewClient: %v", err)
+		}
+		if got, _ := client.uploadURL(); got != want {
+			t.Errorf("uploadURL(%s) = %s, want %s", base, got, want)
+		}
+	}
+}
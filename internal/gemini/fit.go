@@ -0,0 +1,123 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/image"
+)
+
+// DefaultMaxRequestBytes is the API's limit on an inline generateContent
+// request.
+const DefaultMaxRequestBytes = 20 * 1000 * 1000
+
+// requestOverhead is headroom for the request JSON around the contents.
+const requestOverhead = 16 << 10
+
+// InputAdjustment records a reference image that was re-encoded to fit the
+// model's request size limit.
+type InputAdjustment struct {
+	Input          string `json:"input"`
+	OriginalBytes  int    `json:"original_bytes"`
+	Bytes          int    `json:"bytes"`
+	OriginalWidth  int    `json:"original_width"`
+	OriginalHeight int    `json:"original_height"`
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	MimeType       string `json:"mime_type"`
+}
+
+// fitInputs checks the request built around content against the model's size
+// limit. When it is over and opts.FitInputs is set, the largest reference
+// images are downscaled and re-encoded in place until it fits; otherwise the
+// request is rejected before it is sent.
+func (c *Client) fitInputs(content *apiContent, opts *GenerateOptions) ([]InputAdjustment, error) {
+	limit := c.model.Spec.MaxRequestBytes
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	fixed := int64(requestOverhead + len(opts.SystemInstruction))
+	if opts.History != nil {
+		data, err := json.Marshal(opts.History.Contents)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure history: %w", err)
+		}
+		fixed += int64(len(data) + len(opts.History.SystemInstruction))
+	}
+	var images []*apiPart
+	var imageBytes int64
+	for _, part := range content.Parts {
+		if part.InlineData == nil {
+			fixed += int64(len(part.Text))
+			continue
+		}
+		images = append(images, part)
+		imageBytes += base64Len(len(part.InlineData.Data))
+	}
+	if fixed+imageBytes <= limit {
+		return nil, nil
+	}
+
+	tooLarge := func(detail string) error {
+		return &GeminiError{
+			Code:    ErrRequestTooLarge,
+			Message: fmt.Sprintf("request is about %d bytes, over the %d byte limit of %s%s", fixed+imageBytes, limit, c.model.Spec.ID, detail),
+		}
+	}
+	if !opts.FitInputs || len(images) == 0 {
+		return nil, tooLarge("")
+	}
+	// Inline data is base64 encoded, so each raw byte costs 4/3.
+	available := (limit - fixed) * 3 / 4
+	if available <= 0 {
+		return nil, tooLarge(" before any reference image")
+	}
+
+	// Give each image an equal share of what is left, smallest first, so
+	// images under their share pass their slack on to the larger ones.
+	order := make([]int, len(images))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return len(images[order[a]].InlineData.Data) < len(images[order[b]].InlineData.Data)
+	})
+	adjusted := make([]*InputAdjustment, len(images))
+	for n, i := range order {
+		blob := images[i].InlineData
+		share := available / int64(len(order)-n)
+		if int64(len(blob.Data)) > share {
+			fit, err := image.FitBytes(blob.Data, int(share))
+			if err != nil {
+				return nil, tooLarge(fmt.Sprintf(": %s: %v", InputLabel(opts.InputPaths[i]), err))
+			}
+			adjusted[i] = &InputAdjustment{
+				Input:          InputLabel(opts.InputPaths[i]),
+				OriginalBytes:  len(blob.Data),
+				Bytes:          len(fit.Data),
+				OriginalWidth:  fit.OriginalWidth,
+				OriginalHeight: fit.OriginalHeight,
+				Width:          fit.Width,
+				Height:         fit.Height,
+				MimeType:       fit.MimeType,
+			}
+			blob.Data = fit.Data
+			blob.MIMEType = fit.MimeType
+		}
+		available -= int64(len(blob.Data))
+	}
+
+	var adjustments []InputAdjustment
+	for _, a := range adjusted {
+		if a != nil {
+			adjustments = append(adjustments, *a)
+		}
+	}
+	return adjustments, nil
+}
+
+func base64Len(n int) int64 {
+	return int64((n + 2) / 3 * 4)
+}
@@ -0,0 +1,137 @@
+package geminitest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Recorder modes.
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// Fixture is one recorded request/response pair. Request headers are not
+// stored, so fixtures never contain credentials.
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+type FixtureRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Body   string `json:"body,omitempty"`
+}
+
+type FixtureResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Recorder is an http.RoundTripper that either records real exchanges to Dir
+// or replays them from Dir without touching the network. Fixtures are keyed
+// by method, URL path and request body.
+type Recorder struct {
+	mode string
+	dir  string
+	base http.RoundTripper
+}
+
+// NewRecorder returns a recording or replaying transport. base is used only
+// when recording and defaults to http.DefaultTransport.
+func NewRecorder(mode, dir string, base http.RoundTripper) (*Recorder, error) {
+	if mode != ModeRecord && mode != ModeReplay {
+		return nil, fmt.Errorf("unknown recorder mode %q", mode)
+	}
+	if strings.TrimSpace(dir) == "" {
+		return nil, fmt.Errorf("fixture directory is required")
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if mode == ModeRecord {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+		}
+	}
+	return &Recorder{mode: mode, dir: dir, base: base}, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	path := r.fixturePath(req.Method, req.URL.Path, body)
+
+	if r.mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("no fixture for %s %s (%s): %w", req.Method, req.URL.Path, filepath.Base(path), err)
+		}
+		var fx Fixture
+		if err := json.Unmarshal(data, &fx); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", fx.Response.Status, http.StatusText(fx.Response.Status)),
+			StatusCode:    fx.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        fx.Response.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(fx.Response.Body)),
+			ContentLength: int64(len(fx.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	fx := Fixture{
+		Request:  FixtureRequest{Method: req.Method, Path: req.URL.Path, Body: string(body)},
+		Response: FixtureResponse{Status: resp.StatusCode, Header: header, Body: string(respBody)},
+	}
+	data, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return resp, nil
+}
+
+func (r *Recorder) fixturePath(method, path string, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", method, path)
+	h.Write(body)
+	return filepath.Join(r.dir, hex.EncodeToString(h.Sum(nil))[:16]+".json")
+}
@@ -0,0 +1,626 @@
+// Package geminitest provides an in-process fake of the Gemini generateContent,
+// batch, models.list and Files APIs and a record/replay transport, so commands can be tested without network
+// access or an API key.
+package geminitest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request is a generateContent or streamGenerateContent call received by the
+// fake.
+type Request struct {
+	Model       string
+	APIKey      string
+	Header      http.Header
+	Prompt      string
+	System      string
+	InputImages int
+	InputFiles  int
+	AspectRatio string
+	ImageSize   string
+	Thoughts    bool
+	Stream      bool
+	Body        []byte
+}
+
+// Reply is a scripted response returned instead of the default canned image.
+type Reply struct {
+	Status int
+	Header http.Header
+	Body   string
+}
+
+// Fake is an http.Handler that answers generateContent and
+// streamGenerateContent calls. Scripted replies are served first, in order;
+// after that every call returns a solid-color PNG sized from the request's
+// aspect ratio and image size. Streamed calls receive it one part per event.
+//
+// Batches submitted with batchGenerateContent are answered the same way and
+// report PENDING when created and SUCCEEDED from the first status poll on.
+// Inlined requests get inlined responses; a batch read from an uploaded
+// JSONL file gets a responses file, served on the download route.
+//
+// Files uploaded with the resumable upload protocol are kept in memory and
+// can be listed, fetched and deleted.
+type Fake struct {
+	mu       sync.Mutex
+	requests []Request
+	replies  []Reply
+	batches  map[string]*fakeBatch
+	sessions map[string]map[string]any
+	files    map[string]map[string]any
+	contents map[string][]byte
+	uploads  int
+}
+
+type fakeBatch struct {
+	model       string
+	displayName string
+	polled      bool
+	responses   []map[string]any
+	// responsesFile is set for batches read from an input file.
+	responsesFile string
+}
+
+// NewFake returns an empty fake handler.
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Enqueue scripts the next responses, e.g. errors or safety blocks.
+func (f *Fake) Enqueue(replies ...Reply) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replies = append(f.replies, replies...)
+}
+
+// Requests returns the calls received so far.
+func (f *Fake) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
+
+// Uploads returns the number of files uploaded so far, including deleted ones.
+func (f *Fake) Uploads() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.uploads
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.serveFiles(w, r) {
+		return
+	}
+	if _, id, ok := strings.Cut(r.URL.Path, "/batches/"); ok && r.Method == http.MethodGet {
+		f.getBatch(w, "batches/"+id)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/models") && r.Method == http.MethodGet {
+		listModels(w)
+		return
+	}
+
+	_, rest, ok := strings.Cut(r.URL.Path, "/models/")
+	model, method, _ := strings.Cut(rest, ":")
+	if !ok || r.Method != http.MethodPost || (method != "generateContent" && method != "streamGenerateContent" && method != "batchGenerateContent") {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("unsupported route %s %s", r.Method, r.URL.Path))
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	if method == "batchGenerateContent" {
+		f.createBatch(w, r, model, body)
+		return
+	}
+	req, err := parseRequest(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+		return
+	}
+	req.Model = model
+	req.APIKey = r.Header.Get("x-goog-api-key")
+	req.Header = r.Header.Clone()
+	req.Stream = method == "streamGenerateContent"
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	var reply *Reply
+	if len(f.replies) > 0 {
+		reply = &f.replies[0]
+		f.replies = f.replies[1:]
+	}
+	f.mu.Unlock()
+
+	if reply != nil {
+		for k, values := range reply.Header {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		status := reply.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, reply.Body)
+		return
+	}
+
+	resp, err := cannedResponse(req)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL", err.Error())
+		return
+	}
+	if method == "streamGenerateContent" {
+		writeStream(w, resp)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeStream sends a canned response as server-sent events, one part per
+// event, with finishReason and usage on the last one.
+func writeStream(w http.ResponseWriter, resp map[string]any) {
+	candidate := resp["candidates"].([]map[string]any)[0]
+	parts := candidate["content"].(map[string]any)["parts"].([]map[string]any)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	for i, part := range parts {
+		chunk := map[string]any{
+			"candidates": []map[string]any{{
+				"content": map[string]any{"role": "model", "parts": []map[string]any{part}},
+			}},
+		}
+		if i == len(parts)-1 {
+			chunk["candidates"].([]map[string]any)[0]["finishReason"] = candidate["finishReason"]
+			chunk["usageMetadata"] = resp["usageMetadata"]
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\r\n\r\n", data)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+}
+
+func (f *Fake) createBatch(w http.ResponseWriter, r *http.Request, model string, body []byte) {
+	type item struct {
+		Key      string            `json:"key"`
+		Request  json.RawMessage   `json:"request"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	var wire struct {
+		Batch struct {
+			DisplayName string `json:"display_name"`
+			InputConfig struct {
+				Requests struct {
+					Requests []item `json:"requests"`
+				} `json:"requests"`
+				FileName string `json:"file_name"`
+			} `json:"input_config"`
+		} `json:"batch"`
+	}
+	if err := json.Unmarshal(body, &wire); err != nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", fmt.Sprintf("invalid JSON payload: %v", err))
+		return
+	}
+	items := wire.Batch.InputConfig.Requests.Requests
+	if fileName := wire.Batch.InputConfig.FileName; fileName != "" {
+		f.mu.Lock()
+		data, ok := f.contents[fileName]
+		f.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("file %s not found", fileName))
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for dec.More() {
+			var line item
+			if err := dec.Decode(&line); err != nil {
+				writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", fmt.Sprintf("invalid batch input file: %v", err))
+				return
+			}
+			items = append(items, line)
+		}
+	}
+	if len(items) == 0 {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "batch.input_config.requests is required")
+		return
+	}
+
+	batch := &fakeBatch{model: model, displayName: wire.Batch.DisplayName}
+	var received []Request
+	for _, item := range items {
+		req, err := parseRequest(item.Request)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", err.Error())
+			return
+		}
+		req.Model = model
+		req.APIKey = r.Header.Get("x-goog-api-key")
+		req.Header = r.Header.Clone()
+		received = append(received, req)
+
+		resp, err := cannedResponse(req)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL", err.Error())
+			return
+		}
+		if item.Key != "" {
+			batch.responses = append(batch.responses, map[string]any{"response": resp, "key": item.Key})
+		} else {
+			batch.responses = append(batch.responses, map[string]any{"response": resp, "metadata": item.Metadata})
+		}
+	}
+
+	f.mu.Lock()
+	if f.batches == nil {
+		f.batches = map[string]*fakeBatch{}
+	}
+	name := fmt.Sprintf("batches/fake-%d", len(f.batches)+1)
+	if wire.Batch.InputConfig.FileName != "" {
+		batch.responsesFile = fmt.Sprintf("files/fake-%d-responses", len(f.batches)+1)
+	}
+	f.batches[name] = batch
+	f.requests = append(f.requests, received...)
+	op := batch.operation(name)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(op)
+}
+
+func (f *Fake) getBatch(w http.ResponseWriter, name string) {
+	f.mu.Lock()
+	batch, ok := f.batches[name]
+	var op map[string]any
+	if ok {
+		batch.polled = true
+		op = batch.operation(name)
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("batch %s not found", name))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(op)
+}
+
+// ListedModels are the image models returned by the fake's models.list, next
+// to a text model and an embedding model.
+var ListedModels = []string{
+	"gemini-2.5-flash-image",
+	"gemini-3-pro-image-preview",
+	"gemini-3.1-flash-image-preview",
+	"gemini-9-flash-image-preview",
+}
+
+func listModels(w http.ResponseWriter) {
+	models := []map[string]any{
+		{"name": "models/gemini-2.5-flash", "displayName": "Gemini 2.5 Flash", "supportedGenerationMethods": []string{"generateContent", "countTokens"}},
+		{"name": "models/gemini-embedding-001", "displayName": "Gemini Embedding", "supportedGenerationMethods": []string{"embedContent"}},
+	}
+	for _, id := range ListedModels {
+		models = append(models, map[string]any{
+			"name":                       "models/" + id,
+			"displayName":                id,
+			"supportedGenerationMethods": []string{"generateContent", "countTokens", "batchGenerateContent"},
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"models": models})
+}
+
+// serveFiles answers Files API routes and reports whether r was one.
+func (f *Fake) serveFiles(w http.ResponseWriter, r *http.Request) bool {
+	path := r.URL.Path
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.files == nil {
+		f.files = map[string]map[string]any{}
+		f.contents = map[string][]byte{}
+		f.sessions = map[string]map[string]any{}
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/download/") && r.Method == http.MethodGet:
+		_, file, _ := strings.Cut(path, "/files/")
+		name := "files/" + strings.TrimSuffix(file, ":download")
+		for _, batch := range f.batches {
+			if batch.responsesFile == name && batch.polled {
+				w.Header().Set("Content-Type", "application/jsonl")
+				enc := json.NewEncoder(w)
+				for _, resp := range batch.responses {
+					enc.Encode(resp)
+				}
+				return true
+			}
+		}
+		writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("file %s not found", name))
+	case strings.HasPrefix(path, "/upload-session/") && r.Method == http.MethodPost:
+		id := strings.TrimPrefix(path, "/upload-session/")
+		file, ok := f.sessions[id]
+		if !ok || r.Header.Get("X-Goog-Upload-Command") != "upload, finalize" {
+			writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "unknown upload session")
+			return true
+		}
+		data, _ := io.ReadAll(r.Body)
+		delete(f.sessions, id)
+		f.uploads++
+		sum := sha256.Sum256(data)
+		now := time.Now().UTC()
+		name := fmt.Sprintf("files/fake-%d", f.uploads)
+		file["name"] = name
+		file["uri"] = "https://generativelanguage.googleapis.com/v1beta/" + name
+		file["sizeBytes"] = strconv.Itoa(len(data))
+		file["sha256Hash"] = base64.StdEncoding.EncodeToString(sum[:])
+		file["createTime"] = now
+		file["updateTime"] = now
+		file["expirationTime"] = now.Add(48 * time.Hour)
+		file["state"] = "ACTIVE"
+		f.files[name] = file
+		f.contents[name] = data
+		writeJSON(w, map[string]any{"file": file})
+	case strings.HasPrefix(path, "/upload/") && strings.HasSuffix(path, "/files") && r.Method == http.MethodPost:
+		var meta struct {
+			File struct {
+				DisplayName string `json:"display_name"`
+			} `json:"file"`
+		}
+		json.NewDecoder(r.Body).Decode(&meta)
+		id := strconv.Itoa(len(f.sessions) + f.uploads + 1)
+		f.sessions[id] = map[string]any{
+			"displayName": meta.File.DisplayName,
+			"mimeType":    r.Header.Get("X-Goog-Upload-Header-Content-Type"),
+		}
+		w.Header().Set("X-Goog-Upload-Url", "http://"+r.Host+"/upload-session/"+id)
+		w.Header().Set("X-Goog-Upload-Status", "active")
+	case strings.HasSuffix(path, "/files") && r.Method == http.MethodGet:
+		files := []map[string]any{}
+		for _, file := range f.files {
+			files = append(files, file)
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i]["name"].(string) < files[j]["name"].(string) })
+		writeJSON(w, map[string]any{"files": files})
+	case strings.Contains(path, "/files/"):
+		_, id, _ := strings.Cut(path, "/files/")
+		file, ok := f.files["files/"+id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("file files/%s not found", id))
+			return true
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.files, "files/"+id)
+			delete(f.contents, "files/"+id)
+			writeJSON(w, map[string]any{})
+			return true
+		}
+		writeJSON(w, file)
+	default:
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// operation renders the batch as a long-running operation. Callers hold f.mu.
+func (b *fakeBatch) operation(name string) map[string]any {
+	total := strconv.Itoa(len(b.responses))
+	meta := map[string]any{
+		"name":        name,
+		"displayName": b.displayName,
+		"model":       "models/" + b.model,
+		"state":       "BATCH_STATE_PENDING",
+		"batchStats":  map[string]any{"requestCount": total, "pendingRequestCount": total},
+	}
+	op := map[string]any{"name": name, "metadata": meta}
+	if b.polled {
+		meta["state"] = "BATCH_STATE_SUCCEEDED"
+		meta["batchStats"] = map[string]any{"requestCount": total, "successfulRequestCount": total}
+		op["done"] = true
+		if b.responsesFile != "" {
+			op["response"] = map[string]any{"responsesFile": b.responsesFile}
+		} else {
+			op["response"] = map[string]any{
+				"inlinedResponses": map[string]any{"inlinedResponses": b.responses},
+			}
+		}
+	}
+	return op
+}
+
+// Server is a Fake listening on a local port.
+type Server struct {
+	*Fake
+	*httptest.Server
+}
+
+// NewServer starts a fake on a loopback port. Use URL as the client's base URL
+// and Close when done.
+func NewServer() *Server {
+	fake := NewFake()
+	return &Server{Fake: fake, Server: httptest.NewServer(fake)}
+}
+
+// ImageDimensions returns the size the fake renders for an aspect ratio and
+// image size, keeping roughly size² pixels like the real models.
+func ImageDimensions(aspectRatio, imageSize string) (int, int) {
+	base := 1024
+	switch strings.ToUpper(imageSize) {
+	case "512":
+		base = 512
+	case "2K":
+		base = 2048
+	case "4K":
+		base = 4096
+	}
+
+	rw, rh := 1.0, 1.0
+	if w, h, ok := strings.Cut(aspectRatio, ":"); ok {
+		if pw, err := strconv.ParseFloat(w, 64); err == nil && pw > 0 {
+			rw = pw
+		}
+		if ph, err := strconv.ParseFloat(h, 64); err == nil && ph > 0 {
+			rh = ph
+		}
+	}
+	scale := math.Sqrt(rw / rh)
+	round8 := func(v float64) int { return max(8, int(math.Round(v/8))*8) }
+	return round8(float64(base) * scale), round8(float64(base) / scale)
+}
+
+type wireRequest struct {
+	Contents []struct {
+		Role  string `json:"role"`
+		Parts []struct {
+			Text       string          `json:"text"`
+			InlineData json.RawMessage `json:"inline_data"`
+			FileData   json.RawMessage `json:"file_data"`
+		} `json:"parts"`
+	} `json:"contents"`
+	SystemInstruction struct {
+		Parts []struct {
+			Text string `json:"text"`
+		} `json:"parts"`
+	} `json:"systemInstruction"`
+	GenerationConfig struct {
+		ImageConfig struct {
+			AspectRatio string `json:"aspectRatio"`
+			ImageSize   string `json:"imageSize"`
+		} `json:"imageConfig"`
+		ThinkingConfig struct {
+			IncludeThoughts bool `json:"includeThoughts"`
+		} `json:"thinkingConfig"`
+	} `json:"generationConfig"`
+}
+
+func parseRequest(body []byte) (Request, error) {
+	var wire wireRequest
+	if err := json.Unmarshal(body, &wire); err != nil {
+		return Request{}, fmt.Errorf("invalid JSON payload: %w", err)
+	}
+	if len(wire.Contents) == 0 {
+		return Request{}, fmt.Errorf("contents is required")
+	}
+	req := Request{
+		AspectRatio: wire.GenerationConfig.ImageConfig.AspectRatio,
+		ImageSize:   wire.GenerationConfig.ImageConfig.ImageSize,
+		Thoughts:    wire.GenerationConfig.ThinkingConfig.IncludeThoughts,
+		Body:        body,
+	}
+	for _, part := range wire.SystemInstruction.Parts {
+		req.System += part.Text
+	}
+	last := wire.Contents[len(wire.Contents)-1]
+	for _, part := range last.Parts {
+		if part.Text != "" && req.Prompt == "" {
+			req.Prompt = part.Text
+		}
+		if len(part.InlineData) > 0 {
+			req.InputImages++
+		}
+		if len(part.FileData) > 0 {
+			req.InputFiles++
+		}
+	}
+	return req, nil
+}
+
+func cannedResponse(req Request) (map[string]any, error) {
+	width, height := ImageDimensions(req.AspectRatio, req.ImageSize)
+	data, err := solidPNG(width, height, promptColor(req.Prompt))
+	if err != nil {
+		return nil, err
+	}
+
+	signature := base64.StdEncoding.EncodeToString([]byte("fake-signature"))
+	parts := []map[string]any{}
+	if req.Thoughts {
+		parts = append(parts, map[string]any{"text": "Planning the composition.", "thought": true})
+	}
+	parts = append(parts,
+		map[string]any{"text": "Here is your image."},
+		map[string]any{
+			"inline_data":       map[string]any{"mime_type": "image/png", "data": data},
+			"thought_signature": signature,
+		},
+	)
+
+	return map[string]any{
+		"candidates": []map[string]any{{
+			"content":      map[string]any{"role": "model", "parts": parts},
+			"finishReason": "STOP",
+		}},
+		"usageMetadata": map[string]any{
+			"promptTokenCount":     len(strings.Fields(req.Prompt)) + 258*req.InputImages,
+			"candidatesTokenCount": 1290,
+			"totalTokenCount":      len(strings.Fields(req.Prompt)) + 258*req.InputImages + 1290,
+			"candidatesTokensDetails": []map[string]any{
+				{"modality": "IMAGE", "tokenCount": 1280},
+				{"modality": "TEXT", "tokenCount": 10},
+			},
+		},
+	}, nil
+}
+
+// promptColor derives a stable color from the prompt so different prompts
+// produce distinguishable images.
+func promptColor(prompt string) color.NRGBA {
+	h := fnv.New32a()
+	h.Write([]byte(prompt))
+	sum := h.Sum32()
+	return color.NRGBA{R: uint8(sum >> 16), G: uint8(sum >> 8), B: uint8(sum), A: 255}
+}
+
+func solidPNG(width, height int, c color.NRGBA) ([]byte, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeError(w http.ResponseWriter, code int, status, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"code": code, "message": message, "status": status},
+	})
+}
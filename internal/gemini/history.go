@@ -0,0 +1,295 @@
+package gemini
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// HistoryTurn summarizes one exchange in a ConversationHistory: a user
+// content and the model contents that answered it.
+type HistoryTurn struct {
+	Turn        int      `json:"turn"`
+	Prompt      string   `json:"prompt"`
+	InputImages int      `json:"input_images"`
+	Texts       []string `json:"texts,omitempty"`
+	Images      int      `json:"images"`
+	Thoughts    int      `json:"thoughts,omitempty"`
+	Answered    bool     `json:"answered"`
+}
+
+// HistoryImage is an image embedded in a history, numbered within its turn.
+type HistoryImage struct {
+	Turn     int
+	Role     string
+	Index    int
+	Thought  bool
+	MimeType string
+	Data     []byte
+}
+
+// turnStarts returns the content index at which each turn begins. Every user
+// content starts a turn; leading model contents belong to turn 1.
+func (h *ConversationHistory) turnStarts() []int {
+	var starts []int
+	for i, content := range h.Contents {
+		if content == nil {
+			continue
+		}
+		if i == 0 || content.Role == "user" {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// Turns summarizes every turn, oldest first.
+func (h *ConversationHistory) Turns() []HistoryTurn {
+	starts := h.turnStarts()
+	turns := make([]HistoryTurn, 0, len(starts))
+	for t, start := range starts {
+		end := len(h.Contents)
+		if t+1 < len(starts) {
+			end = starts[t+1]
+		}
+		turn := HistoryTurn{Turn: t + 1}
+		for _, content := range h.Contents[start:end] {
+			if content == nil {
+				continue
+			}
+			for _, part := range content.Parts {
+				if part == nil {
+					continue
+				}
+				isImage := part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/")
+				if content.Role == "user" {
+					if isImage {
+						turn.InputImages++
+					} else if part.Text != "" && turn.Prompt == "" {
+						turn.Prompt = part.Text
+					}
+					continue
+				}
+				switch {
+				case part.Thought:
+					turn.Thoughts++
+				case isImage:
+					turn.Images++
+				case part.Text != "":
+					turn.Texts = append(turn.Texts, part.Text)
+				}
+			}
+			if content.Role != "user" {
+				turn.Answered = true
+			}
+		}
+		turns = append(turns, turn)
+	}
+	return turns
+}
+
+// Truncate returns a copy holding only the first n turns.
+func (h *ConversationHistory) Truncate(n int) (*ConversationHistory, error) {
+	starts := h.turnStarts()
+	if n < 1 || n > len(starts) {
+		return nil, fmt.Errorf("turn %d is out of range (history has %d turns)", n, len(starts))
+	}
+	end := len(h.Contents)
+	if n < len(starts) {
+		end = starts[n]
+	}
+	contents := make([]*apiContent, 0, end)
+	for _, content := range h.Contents[:end] {
+		contents = append(contents, cloneContent(content))
+	}
+	return h.withContents(contents), nil
+}
+
+// withContents returns a history with h's settings and the given contents.
+func (h *ConversationHistory) withContents(contents []*apiContent) *ConversationHistory {
+	cp := *h
+	cp.Contents = contents
+	return &cp
+}
+
+// Images returns every embedded image, including user references and thought
+// images, in history order.
+func (h *ConversationHistory) Images() []HistoryImage {
+	var images []HistoryImage
+	starts := h.turnStarts()
+	turn := 0
+	perTurn := 0
+	for i, content := range h.Contents {
+		if content == nil {
+			continue
+		}
+		if turn < len(starts) && i == starts[turn] {
+			turn++
+			perTurn = 0
+		}
+		for _, part := range content.Parts {
+			if part == nil || part.InlineData == nil || !strings.HasPrefix(part.InlineData.MIMEType, "image/") {
+				continue
+			}
+			perTurn++
+			images = append(images, HistoryImage{
+				Turn:     turn,
+				Role:     content.Role,
+				Index:    perTurn,
+				Thought:  part.Thought,
+				MimeType: part.InlineData.MIMEType,
+				Data:     part.InlineData.Data,
+			})
+		}
+	}
+	return images
+}
+
+// History formats accepted in ConversationHistory.Format.
+const (
+	HistoryInline   = "inline"
+	HistoryExternal = "external"
+)
+
+var blobName = regexp.MustCompile(`^[0-9a-f]{64}\.[a-z0-9+.-]+$`)
+
+// BlobDir is the directory holding the images of an externalized history
+// saved at path: photo-history.json keeps them in photo-history.blobs.
+func BlobDir(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".blobs"
+}
+
+// externalize returns the history as it is written to path. For
+// HistoryExternal, every image is written to BlobDir(path), named by its
+// SHA-256 so unchanged images are not rewritten, and replaced by a reference.
+// The returned set holds the blob file names still in use.
+func (h *ConversationHistory) externalize(path string) (*ConversationHistory, map[string]bool, error) {
+	blobs := map[string]bool{}
+	switch h.Format {
+	case "", HistoryInline:
+		return h, blobs, nil
+	case HistoryExternal:
+	default:
+		return nil, nil, fmt.Errorf("unknown history format %q (use %s or %s)", h.Format, HistoryInline, HistoryExternal)
+	}
+
+	dir := BlobDir(path)
+	contents := make([]*apiContent, 0, len(h.Contents))
+	for _, content := range h.Contents {
+		if content == nil {
+			contents = append(contents, nil)
+			continue
+		}
+		cp := &apiContent{Role: content.Role, Parts: make([]*apiPart, 0, len(content.Parts))}
+		for _, part := range content.Parts {
+			if part == nil || part.InlineData == nil || len(part.InlineData.Data) == 0 {
+				cp.Parts = append(cp.Parts, part)
+				continue
+			}
+			sum := sha256.Sum256(part.InlineData.Data)
+			hash := hex.EncodeToString(sum[:])
+			name := hash + "." + blobExtension(part.InlineData.MIMEType)
+			if !blobs[name] {
+				if err := writeBlob(filepath.Join(dir, name), part.InlineData.Data); err != nil {
+					return nil, nil, err
+				}
+				blobs[name] = true
+			}
+			ref := *part
+			ref.InlineData = &apiBlob{
+				MIMEType: part.InlineData.MIMEType,
+				SHA256:   hash,
+				File:     filepath.ToSlash(filepath.Join(filepath.Base(dir), name)),
+			}
+			cp.Parts = append(cp.Parts, &ref)
+		}
+		contents = append(contents, cp)
+	}
+	return h.withContents(contents), blobs, nil
+}
+
+// rehydrate reads the blob files referenced by a history loaded from path
+// back into the parts, verifying their hashes. References must name a blob
+// in BlobDir(path) carrying its SHA-256, so a history file cannot pull
+// arbitrary files into the next request.
+func (h *ConversationHistory) rehydrate(path string) error {
+	dir := BlobDir(path)
+	for _, content := range h.Contents {
+		if content == nil {
+			continue
+		}
+		for _, part := range content.Parts {
+			if part == nil || part.InlineData == nil || part.InlineData.File == "" {
+				continue
+			}
+			blob := part.InlineData
+			name := strings.TrimPrefix(blob.File, filepath.Base(dir)+"/")
+			if name == blob.File || !blobName.MatchString(name) || !strings.HasPrefix(name, blob.SHA256+".") {
+				return fmt.Errorf("history image %q is not a blob of %s", blob.File, dir)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return fmt.Errorf("failed to read history image: %w", err)
+			}
+			if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != blob.SHA256 {
+				return fmt.Errorf("history image %s does not match its SHA-256", blob.File)
+			}
+			blob.Data = data
+			blob.File = ""
+			blob.SHA256 = ""
+		}
+	}
+	return nil
+}
+
+// writeBlob writes a content-addressed file unless it already exists.
+func writeBlob(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create history image directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write history image: %w", err)
+	}
+	return nil
+}
+
+// pruneBlobs removes blob files in dir that are not in keep, and dir itself
+// once it is empty. Files not named like blobs are left alone.
+func pruneBlobs(dir string, keep map[string]bool) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history image directory: %w", err)
+	}
+	for _, entry := range entries {
+		if blobName.MatchString(entry.Name()) && !keep[entry.Name()] {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return fmt.Errorf("failed to remove unused history image: %w", err)
+			}
+		}
+	}
+	if len(keep) == 0 {
+		os.Remove(dir)
+	}
+	return nil
+}
+
+// blobExtension names a blob file after the subtype of its MIME type.
+func blobExtension(mimeType string) string {
+	_, subtype, ok := strings.Cut(mimeType, "/")
+	subtype = strings.ToLower(subtype)
+	if !ok || !blobName.MatchString(strings.Repeat("0", 64)+"."+subtype) {
+		return "bin"
+	}
+	return subtype
+}
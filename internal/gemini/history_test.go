@@ -0,0 +1,146 @@
+package gemini
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testHistory() *ConversationHistory {
+	img := func(data string) *apiPart {
+		return &apiPart{InlineData: &apiBlob{MIMEType: "image/png", Data: []byte(data)}}
+	}
+	return &ConversationHistory{
+		Model: "gemini-3-pro-image-preview",
+		Contents: []*apiContent{
+			{Role: "user", Parts: []*apiPart{{Text: "a red bicycle"}}},
+			{Role: "model", Parts: []*apiPart{{Text: "thinking", Thought: true}, img("one"), {Text: "Here it is."}}},
+			{Role: "user", Parts: []*apiPart{{Text: "add a bell"}, img("ref")}},
+			{Role: "model", Parts: []*apiPart{img("two")}},
+			{Role: "user", Parts: []*apiPart{{Text: "make it blue"}}},
+		},
+	}
+}
+
+func TestHistoryTurns(t *testing.T) {
+	turns := testHistory().Turns()
+	if len(turns) != 3 {
+		t.Fatalf("turns = %+v", turns)
+	}
+	first, second, third := turns[0], turns[1], turns[2]
+	if first.Prompt != "a red bicycle" || first.Images != 1 || first.Thoughts != 1 || len(first.Texts) != 1 || !first.Answered {
+		t.Fatalf("turn 1 = %+v", first)
+	}
+	if second.Prompt != "add a bell" || second.InputImages != 1 || second.Images != 1 {
+		t.Fatalf("turn 2 = %+v", second)
+	}
+	if third.Answered {
+		t.Fatalf("turn 3 = %+v", third)
+	}
+}
+
+func TestHistoryTruncate(t *testing.T) {
+	h := testHistory()
+	truncated, err := h.Truncate(2)
+	if err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	if len(truncated.Contents) != 4 || truncated.Model != h.Model {
+		t.Fatalf("truncated = %+v", truncated)
+	}
+	truncated.Contents[0].Parts[0].Text = "changed"
+	if h.Contents[0].Parts[0].Text != "a red bicycle" {
+		t.Fatal("Truncate shares contents with the original")
+	}
+	if _, err := h.Truncate(4); err == nil {
+		t.Fatal("expected an error for an out-of-range turn")
+	}
+
+	path := filepath.Join(t.TempDir(), "h.json")
+	if err := truncated.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadHistory(path)
+	if err != nil || len(loaded.Turns()) != 2 {
+		t.Fatalf("loaded = %+v, %v", loaded, err)
+	}
+}
+
+func TestHistoryImages(t *testing.T) {
+	images := testHistory().Images()
+	if len(images) != 3 {
+		t.Fatalf("images = %+v", images)
+	}
+	if images[0].Turn != 1 || images[0].Role != "model" || string(images[0].Data) != "one" {
+		t.Fatalf("image 1 = %+v", images[0])
+	}
+	if images[1].Turn != 2 || images[1].Role != "user" || images[1].Index != 1 || images[2].Index != 2 {
+		t.Fatalf("turn 2 images = %+v", images[1:])
+	}
+}
+
+func TestHistoryExternalFormat(t *testing.T) {
+	h := testHistory()
+	h.Contents[3].Parts = append(h.Contents[3].Parts, &apiPart{InlineData: &apiBlob{MIMEType: "image/png", Data: []byte("one")}})
+	h.Format = HistoryExternal
+	path := filepath.Join(t.TempDir(), "h.json")
+	if err := h.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), `"data"`) || !strings.Contains(string(data), `"file": "h.blobs/`) {
+		t.Fatalf("history file still embeds images:\n%s", data)
+	}
+	blobs, _ := os.ReadDir(BlobDir(path))
+	if len(blobs) != 3 {
+		t.Fatalf("blobs = %v, want one file per distinct image", blobs)
+	}
+
+	loaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("LoadHistory: %v", err)
+	}
+	images := loaded.Images()
+	if loaded.Format != HistoryExternal || len(images) != 4 || string(images[3].Data) != "one" || loaded.Contents[1].Parts[1].InlineData.File != "" {
+		t.Fatalf("loaded = %+v", images)
+	}
+
+	truncated, _ := loaded.Truncate(1)
+	if err := truncated.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if blobs, _ = os.ReadDir(BlobDir(path)); len(blobs) != 1 {
+		t.Fatalf("unused blobs were not pruned: %v", blobs)
+	}
+
+	os.WriteFile(filepath.Join(BlobDir(path), blobs[0].Name()), []byte("tampered"), 0644)
+	if _, err := LoadHistory(path); err == nil || !strings.Contains(err.Error(), "SHA-256") {
+		t.Fatalf("err = %v, want a hash mismatch", err)
+	}
+
+	secret := filepath.Join(filepath.Dir(path), "secret.txt")
+	os.WriteFile(secret, []byte("key"), 0644)
+	sum := sha256.Sum256([]byte("key"))
+	for _, ref := range []string{
+		`{"file":"../secret.txt","sha256":"` + hex.EncodeToString(sum[:]) + `"}`,
+		`{"file":"h.blobs/../secret.txt"}`,
+		`{"file":"h.blobs/` + strings.Repeat("a", 64) + `.png"}`,
+	} {
+		os.WriteFile(path, []byte(`{"contents":[{"role":"user","parts":[{"inline_data":`+ref+`}]}]}`), 0644)
+		if _, err := LoadHistory(path); err == nil || !strings.Contains(err.Error(), "is not a blob") {
+			t.Fatalf("%s: err = %v, want a rejected reference", ref, err)
+		}
+	}
+
+	truncated.Format = HistoryInline
+	if err := truncated.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(BlobDir(path)); !os.IsNotExist(err) {
+		t.Fatalf("blob directory should be removed after migrating to inline: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxInputBytes caps the size of a single reference image read from a
+// URL, stdin or data URI.
+const DefaultMaxInputBytes = 20 << 20
+
+// StdinInput is the input reference that reads image bytes from stdin.
+const StdinInput = "-"
+
+// WithMaxInputBytes overrides DefaultMaxInputBytes.
+func WithMaxInputBytes(n int64) ClientOption {
+	return func(c *Client) {
+		if n > 0 {
+			c.maxInputBytes = n
+		}
+	}
+}
+
+// WithStdin replaces os.Stdin as the source of "-" inputs.
+func WithStdin(r io.Reader) ClientOption {
+	return func(c *Client) {
+		c.stdin = r
+	}
+}
+
+// IsFileInput reports whether an input reference names a local file, rather
+// than stdin, a data URI or an http(s) URL.
+func IsFileInput(ref string) bool {
+	return ref != StdinInput && !isDataURI(ref) && !isURLInput(ref)
+}
+
+// InputLabel shortens an input reference for display, replacing a data URI's
+// payload with its size.
+func InputLabel(ref string) string {
+	if !isDataURI(ref) {
+		return ref
+	}
+	header, payload, _ := strings.Cut(ref, ",")
+	return fmt.Sprintf("%s,... (%d bytes)", header, len(payload))
+}
+
+func isDataURI(ref string) bool {
+	return strings.HasPrefix(ref, "data:")
+}
+
+func isURLInput(ref string) bool {
+	return strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://")
+}
+
+// readInput loads one reference image and detects its MIME type from the
+// content.
+func (c *Client) readInput(ctx context.Context, ref string) ([]byte, string, error) {
+	var (
+		data     []byte
+		declared string
+		err      error
+	)
+	switch {
+	case ref == StdinInput:
+		data, err = c.readStdin()
+	case isDataURI(ref):
+		data, declared, err = decodeDataURI(ref)
+	case isURLInput(ref):
+		data, declared, err = c.fetchInput(ctx, ref)
+	default:
+		data, err = os.ReadFile(ref)
+	}
+	if err != nil {
+		return nil, "", &GeminiError{Code: ErrInvalidInput, Message: fmt.Sprintf("failed to read input image %s: %v", InputLabel(ref), err)}
+	}
+	if int64(len(data)) > c.maxInputBytes && !IsFileInput(ref) {
+		return nil, "", &GeminiError{Code: ErrInvalidInput, Message: fmt.Sprintf("input image %s is larger than %d bytes", InputLabel(ref), c.maxInputBytes)}
+	}
+
+	mimeType, err := detectMimeType(data, declared, ref)
+	if err != nil {
+		return nil, "", &GeminiError{Code: ErrInvalidInput, Message: fmt.Sprintf("input %s: %v", InputLabel(ref), err)}
+	}
+	return data, mimeType, nil
+}
+
+// readStdin reads stdin once; every "-" input in the client's lifetime gets
+// the same bytes.
+func (c *Client) readStdin() ([]byte, error) {
+	c.stdinOnce.Do(func() {
+		in := c.stdin
+		if in == nil {
+			in = os.Stdin
+		}
+		c.stdinData, c.stdinErr = io.ReadAll(io.LimitReader(in, c.maxInputBytes+1))
+		if c.stdinErr == nil && len(c.stdinData) == 0 {
+			c.stdinErr = fmt.Errorf("stdin is empty")
+		}
+	})
+	return c.stdinData, c.stdinErr
+}
+
+// decodeDataURI decodes a data:[<mime>][;base64],<data> URI.
+func decodeDataURI(ref string) ([]byte, string, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(ref, "data:"), ",")
+	if !ok {
+		return nil, "", fmt.Errorf("malformed data URI")
+	}
+	mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+	if !isBase64 {
+		text, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("malformed data URI: %w", err)
+		}
+		return []byte(text), mediaType, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("malformed base64 in data URI: %w", err)
+	}
+	return data, mediaType, nil
+}
+
+// fetchInput downloads a reference image, refusing bodies larger than
+// maxInputBytes. It returns the response's declared content type.
+func (c *Client) fetchInput(ctx context.Context, rawURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "image/*")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("HTTP %s", resp.Status)
+	}
+	if resp.ContentLength > c.maxInputBytes {
+		return nil, "", fmt.Errorf("response is %d bytes, over the %d byte limit", resp.ContentLength, c.maxInputBytes)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.maxInputBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// detectMimeType sniffs an image's type from its bytes, falling back to a
+// declared content type and then to the file extension. Content that is not
+// recognizably an image is an error.
+func detectMimeType(data []byte, declared, name string) (string, error) {
+	if sniffed := sniffImageType(data); sniffed != "" {
+		return sniffed, nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(declared); err == nil && strings.HasPrefix(mediaType, "image/") {
+		return mediaType, nil
+	}
+	if IsFileInput(name) {
+		if byExt, ok := imageExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+			return byExt, nil
+		}
+	}
+	return "", fmt.Errorf("content is not a recognized image (detected %s)", http.DetectContentType(data))
+}
+
+var imageExtensions = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".heic": "image/heic",
+	".heif": "image/heif",
+}
+
+func sniffImageType(data []byte) string {
+	if sniffed := http.DetectContentType(data); strings.HasPrefix(sniffed, "image/") {
+		return sniffed
+	}
+	// HEIC and HEIF are ISO-BMFF files with an ftyp box, which
+	// http.DetectContentType does not know.
+	if len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) {
+		switch string(data[8:12]) {
+		case "heic", "heix", "heim", "heis":
+			return "image/heic"
+		case "mif1", "msf1", "heif":
+			return "image/heif"
+		}
+	}
+	return ""
+}
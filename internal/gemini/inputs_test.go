@@ -0,0 +1,166 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/png"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadInput(t *testing.T) {
+	pngData := testPNG(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ref.png":
+			// A wrong declared type loses to the sniffed one.
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(pngData)
+		case "/big.png":
+			w.Write(append(pngData, make([]byte, 1024)...))
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>not an image</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	misnamed := filepath.Join(dir, "photo.jpg")
+	os.WriteFile(misnamed, pngData, 0644)
+	unknown := filepath.Join(dir, "notes.txt")
+	os.WriteFile(unknown, []byte("hello"), 0644)
+
+	client, err := NewClient("test-key", "banana2", time.Second,
+		WithMaxInputBytes(int64(len(pngData))+512),
+		WithStdin(strings.NewReader(string(pngData))))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		wantErr string
+	}{
+		{name: "file sniffed over extension", ref: misnamed},
+		{name: "url", ref: srv.URL + "/ref.png"},
+		{name: "stdin", ref: StdinInput},
+		{name: "stdin again", ref: StdinInput},
+		{name: "base64 data uri", ref: "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngData)},
+		{name: "data uri without type", ref: "data:;base64," + base64.StdEncoding.EncodeToString(pngData)},
+		{name: "url over limit", ref: srv.URL + "/big.png", wantErr: "byte limit"},
+		{name: "url not found", ref: srv.URL + "/missing.png", wantErr: "404"},
+		{name: "url not an image", ref: srv.URL + "/page", wantErr: "not a recognized image"},
+		{name: "file not an image", ref: unknown, wantErr: "not a recognized image"},
+		{name: "malformed data uri", ref: "data:image/png;base64", wantErr: "malformed"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, mimeType, err := client.readInput(context.Background(), tc.ref)
+			if tc.wantErr != "" {
+				var gerr *GeminiError
+				if !errors.As(err, &gerr) || gerr.Code != ErrInvalidInput || !strings.Contains(gerr.Message, tc.wantErr) {
+					t.Fatalf("err = %v, want %s containing %q", err, ErrInvalidInput, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readInput: %v", err)
+			}
+			if mimeType != "image/png" || len(data) != len(pngData) {
+				t.Errorf("got %s with %d bytes, want image/png with %d", mimeType, len(data), len(pngData))
+			}
+		})
+	}
+}
+
+func TestDetectMimeTypeHEIC(t *testing.T) {
+	data := append([]byte{0, 0, 0, 24}, []byte("ftypheic\x00\x00\x00\x00mif1heic")...)
+	got, err := detectMimeType(data, "", "photo.bin")
+	if err != nil || got != "image/heic" {
+		t.Errorf("detectMimeType = %q, %v; want image/heic", got, err)
+	}
+}
+
+func TestInputLabel(t *testing.T) {
+	if got := InputLabel("data:image/png;base64,AAAA"); got != "data:image/png;base64,... (4 bytes)" {
+		t.Errorf("InputLabel = %q", got)
+	}
+	if got := InputLabel("https://example.com/a.png"); got != "https://example.com/a.png" {
+		t.Errorf("InputLabel = %q", got)
+	}
+}
+
+// noisyPNG returns a PNG of random pixels, which compresses poorly.
+func noisyPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.IntN(256))
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFitInputs(t *testing.T) {
+	t.Cleanup(ResetModels)
+	small := OpenModelSpec("gemini-small-image")
+	small.MaxRequestBytes = 400 << 10
+	RegisterModels(small)
+
+	dir := t.TempDir()
+	big := filepath.Join(dir, "big.png")
+	os.WriteFile(big, noisyPNG(t, 400, 400), 0644)
+	tiny := filepath.Join(dir, "tiny.png")
+	os.WriteFile(tiny, testPNG(t), 0644)
+
+	client, err := NewClient("test-key", "gemini-small-image", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	inputs := []string{big, tiny, big}
+
+	content, err := client.buildUserContent(context.Background(), "combine", inputs)
+	if err != nil {
+		t.Fatalf("buildUserContent: %v", err)
+	}
+	_, err = client.fitInputs(content, &GenerateOptions{InputPaths: inputs})
+	var gerr *GeminiError
+	if !errors.As(err, &gerr) || gerr.Code != ErrRequestTooLarge {
+		t.Fatalf("err = %v, want %s", err, ErrRequestTooLarge)
+	}
+
+	adjustments, err := client.fitInputs(content, &GenerateOptions{InputPaths: inputs, FitInputs: true})
+	if err != nil {
+		t.Fatalf("fitInputs: %v", err)
+	}
+	if len(adjustments) != 2 || adjustments[0].Input != big || adjustments[0].Bytes >= adjustments[0].OriginalBytes {
+		t.Fatalf("adjustments = %+v", adjustments)
+	}
+	var total int64
+	for _, part := range content.Parts[1:] {
+		total += base64Len(len(part.InlineData.Data))
+	}
+	if total > small.MaxRequestBytes || content.Parts[1].InlineData.MIMEType != "image/jpeg" || content.Parts[2].InlineData.MIMEType != "image/png" {
+		t.Fatalf("fitted inputs total %d bytes, types %s and %s", total, content.Parts[1].InlineData.MIMEType, content.Parts[2].InlineData.MIMEType)
+	}
+}
@@ -13,9 +13,19 @@ const (
 	DefaultModelID = ModelFlash31
 )
 
+// Model sources, as reported in ModelSpec.Source: built in, declared in
+// config, or discovered through the API's model list.
+const (
+	SourceBuiltin = "builtin"
+	SourceConfig  = "config"
+	SourceAPI     = "api"
+)
+
 type ModelSpec struct {
 	ID                    string
 	Aliases               []string
+	DisplayName           string
+	Source                string
 	DefaultImageSize      string
 	SupportedAspectRatios []string
 	SupportedImageSizes   []string
@@ -24,6 +34,17 @@ type ModelSpec struct {
 	SupportsThinking      bool
 	SupportsThinkingLevel bool
 	MaxInputImages        int
+	// MaxRequestBytes caps the encoded generateContent request, inline
+	// reference images and history included.
+	MaxRequestBytes int64
+	// Sampling limits checked by ValidateSampling.
+	MaxTemperature    float64
+	MaxTopK           int
+	MaxCandidateCount int
+	Pricing           ModelPricing
+	// TextOnly marks listed models that do not generate images, which
+	// clients reject before sending anything.
+	TextOnly bool
 }
 
 var (
@@ -64,14 +85,23 @@ var (
 		ModelFlash25: {
 			ID:                    ModelFlash25,
 			Aliases:               []string{"banana", "2.5"},
+			DisplayName:           "Gemini 2.5 Flash Image",
+			Source:                SourceBuiltin,
 			DefaultImageSize:      "1K",
 			SupportedAspectRatios: standardAspectRatios,
 			SupportedImageSizes:   []string{"1K"},
 			MaxInputImages:        3,
+			MaxRequestBytes:       DefaultMaxRequestBytes,
+			MaxTemperature:        2,
+			MaxTopK:               64,
+			MaxCandidateCount:     8,
+			Pricing:               ModelPricing{InputPerMillion: 0.30, TextOutputPerMillion: 2.50, ImageOutputPerMillion: 30},
 		},
 		ModelFlash31: {
 			ID:                    ModelFlash31,
 			Aliases:               []string{"banana2", "3.1"},
+			DisplayName:           "Gemini 3.1 Flash Image Preview",
+			Source:                SourceBuiltin,
 			DefaultImageSize:      "1K",
 			SupportedAspectRatios: flash31AspectRatios,
 			SupportedImageSizes:   allImageSizes,
@@ -80,16 +110,28 @@ var (
 			SupportsThinking:      true,
 			SupportsThinkingLevel: true,
 			MaxInputImages:        14,
+			MaxRequestBytes:       DefaultMaxRequestBytes,
+			MaxTemperature:        2,
+			MaxTopK:               64,
+			MaxCandidateCount:     8,
+			Pricing:               ModelPricing{InputPerMillion: 0.50, TextOutputPerMillion: 3, ImageOutputPerMillion: 60},
 		},
 		ModelPro: {
 			ID:                    ModelPro,
 			Aliases:               []string{"pro"},
+			DisplayName:           "Gemini 3 Pro Image Preview",
+			Source:                SourceBuiltin,
 			DefaultImageSize:      "1K",
 			SupportedAspectRatios: standardAspectRatios,
 			SupportedImageSizes:   []string{"1K", "2K", "4K"},
 			SupportsGrounding:     true,
 			SupportsThinking:      true,
 			MaxInputImages:        14,
+			MaxRequestBytes:       DefaultMaxRequestBytes,
+			MaxTemperature:        2,
+			MaxTopK:               64,
+			MaxCandidateCount:     8,
+			Pricing:               ModelPricing{InputPerMillion: 2, TextOutputPerMillion: 12, ImageOutputPerMillion: 120},
 		},
 	}
 
@@ -108,6 +150,9 @@ var (
 type ValidatedModel struct {
 	Spec  ModelSpec
 	Alias string
+	// Known is false for raw IDs found in neither the built-in models, the
+	// registered ones nor the API's model list.
+	Known bool
 }
 
 func ResolveModelName(name string) string {
@@ -117,27 +162,35 @@ func ResolveModelName(name string) string {
 func ResolveModel(name string) ValidatedModel {
 	normalized := strings.TrimSpace(strings.ToLower(name))
 	if normalized == "" {
-		return ValidatedModel{Spec: modelsByID[DefaultModelID], Alias: "banana2"}
+		spec, _ := lookupModel(DefaultModelID)
+		return ValidatedModel{Spec: spec, Alias: "banana2", Known: true}
 	}
 
-	if id, ok := aliasToModelID[normalized]; ok {
-		return ValidatedModel{Spec: modelsByID[id], Alias: normalized}
+	if spec, ok := lookupModel(normalized); ok {
+		return ValidatedModel{Spec: spec, Alias: normalized, Known: true}
 	}
 
 	// Unknown values are treated as raw model IDs.
-	return ValidatedModel{
-		Spec: ModelSpec{
-			ID:                    name,
-			DefaultImageSize:      "1K",
-			SupportedAspectRatios: allAspectRatios,
-			SupportedImageSizes:   allImageSizes,
-			SupportsGrounding:     true,
-			SupportsImageSearch:   true,
-			SupportsThinking:      true,
-			SupportsThinkingLevel: true,
-			MaxInputImages:        14,
-		},
-		Alias: name,
+	return ValidatedModel{Spec: OpenModelSpec(strings.TrimSpace(name)), Alias: name}
+}
+
+// OpenModelSpec returns a spec for a model whose capabilities are not known,
+// allowing every option so that the API decides.
+func OpenModelSpec(id string) ModelSpec {
+	return ModelSpec{
+		ID:                    id,
+		DefaultImageSize:      "1K",
+		SupportedAspectRatios: allAspectRatios,
+		SupportedImageSizes:   allImageSizes,
+		SupportsGrounding:     true,
+		SupportsImageSearch:   true,
+		SupportsThinking:      true,
+		SupportsThinkingLevel: true,
+		MaxInputImages:        14,
+		MaxRequestBytes:       DefaultMaxRequestBytes,
+		MaxTemperature:        2,
+		MaxTopK:               64,
+		MaxCandidateCount:     8,
 	}
 }
 
@@ -149,17 +202,32 @@ func ListModelAliases() []string {
 	}
 }
 
+// ListAllAspectRatios returns every ratio supported by some model, including
+// ratios declared for registered models.
 func ListAllAspectRatios() []string {
-	return append([]string(nil), allAspectRatios...)
+	ratios := append([]string(nil), allAspectRatios...)
+	for _, spec := range registeredSpecs() {
+		for _, ratio := range spec.SupportedAspectRatios {
+			if !slices.Contains(ratios, ratio) {
+				ratios = append(ratios, ratio)
+			}
+		}
+	}
+	return ratios
+}
+
+// ListAllImageSizes returns every image size the API accepts.
+func ListAllImageSizes() []string {
+	return append([]string(nil), allImageSizes...)
 }
 
 func IsKnownModel(name string) bool {
-	_, ok := aliasToModelID[strings.ToLower(strings.TrimSpace(name))]
+	_, ok := lookupModel(strings.ToLower(strings.TrimSpace(name)))
 	return ok
 }
 
 func IsValidAspectRatio(ratio string) bool {
-	return slices.Contains(allAspectRatios, strings.TrimSpace(ratio))
+	return slices.Contains(ListAllAspectRatios(), strings.TrimSpace(ratio))
 }
 
 func IsValidImageSize(size string) bool {
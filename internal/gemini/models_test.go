@@ -1,7 +1,12 @@
 package gemini
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -63,6 +68,23 @@ func TestValidateOptionsByModel(t *testing.T) {
 			model: "pro",
 			opts:  GenerateOptions{ImageSize: "4K", GroundWeb: true, Count: 1},
 		},
+		{
+			name:    "temperature above model limit",
+			model:   "banana2",
+			opts:    GenerateOptions{Sampling: Sampling{Temperature: ptr(2.5)}, Count: 1},
+			wantErr: true,
+		},
+		{
+			name:    "candidate count with count",
+			model:   "banana2",
+			opts:    GenerateOptions{Sampling: Sampling{CandidateCount: 2}, Count: 2},
+			wantErr: true,
+		},
+		{
+			name:  "sampling within limits",
+			model: "pro",
+			opts:  GenerateOptions{Sampling: Sampling{Temperature: ptr(1.0), TopP: ptr(0.9), TopK: ptr(40), CandidateCount: 4}, Count: 1},
+		},
 	}
 
 	for _, tc := range tests {
@@ -143,3 +165,91 @@ func TestTranslateGrounding(t *testing.T) {
 		t.Fatal("search entry point was not preserved")
 	}
 }
+
+func TestRegisteredModels(t *testing.T) {
+	t.Cleanup(ResetModels)
+
+	custom := OpenModelSpec("gemini-4-flash-image-preview")
+	custom.Aliases = []string{"banana4"}
+	custom.SupportedAspectRatios = []string{"1:1", "7:3"}
+	custom.Source = SourceConfig
+	RegisterModels(custom)
+
+	got := ResolveModel("banana4")
+	if !got.Known || got.Spec.ID != "gemini-4-flash-image-preview" {
+		t.Fatalf("ResolveModel(banana4) = %+v", got)
+	}
+	if err := ValidateAspectRatio(got.Spec, "16:9"); err == nil {
+		t.Fatal("expected 16:9 to be rejected for the declared model")
+	}
+	if !IsValidAspectRatio("7:3") {
+		t.Fatal("a ratio declared for a registered model should be valid")
+	}
+
+	if ResolveModel("gemini-5-image").Known {
+		t.Fatal("unregistered model reported as known")
+	}
+	client, err := NewClient("test-key", "gemini-5-image", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if err := client.validateOptions(&GenerateOptions{Count: 1}); err != nil {
+		t.Fatalf("unknown models are allowed without a listing: %v", err)
+	}
+
+	RegisterListing(&ModelListing{RefreshedAt: time.Now(), Models: []ListedModel{{ID: "gemini-6-image", DisplayName: "Gemini 6 Image"}, {ID: ModelPro}, {ID: "gemini-6-flash"}}})
+	if spec := ResolveModel("gemini-6-image").Spec; spec.Source != SourceAPI || spec.DisplayName != "Gemini 6 Image" {
+		t.Fatalf("listed model = %+v", spec)
+	}
+	if spec := ResolveModel("pro").Spec; spec.Source != SourceBuiltin {
+		t.Fatalf("listing replaced a built-in model: %+v", spec)
+	}
+	err = client.validateOptions(&GenerateOptions{Count: 1})
+	var gerr *GeminiError
+	if !errors.As(err, &gerr) || gerr.Code != ErrModelNotFound {
+		t.Fatalf("validateOptions for an unlisted model = %v, want %s", err, ErrModelNotFound)
+	}
+
+	text, err := NewClient("test-key", "gemini-6-flash", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	err = text.validateOptions(&GenerateOptions{Count: 1})
+	if !errors.As(err, &gerr) || gerr.Code != ErrInvalidInput || !strings.Contains(gerr.Message, "not an image model") {
+		t.Fatalf("validateOptions for a text model = %v, want an image model error", err)
+	}
+}
+
+func TestListModelsPages(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "" {
+			w.Write([]byte(`{"models":[{"name":"models/gemini-3-pro-image-preview","displayName":"Nano Banana Pro","supportedGenerationMethods":["generateContent"]},{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}],"nextPageToken":"p2"}`))
+			return
+		}
+		w.Write([]byte(`{"models":[{"name":"models/gemini-2.5-flash-image","supportedGenerationMethods":["generateContent"]}]}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("test-key", "banana2", time.Second, WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	listing, err := client.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+	if len(listing.Models) != 2 || listing.Models[0].ID != ModelFlash25 || listing.Models[1].DisplayName != "Nano Banana Pro" {
+		t.Fatalf("listing = %+v", listing.Models)
+	}
+
+	path := filepath.Join(t.TempDir(), "models.json")
+	if err := listing.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadModelListing(path)
+	if err != nil || len(loaded.Models) != 2 || !loaded.RefreshedAt.Equal(listing.RefreshedAt) {
+		t.Fatalf("LoadModelListing = %+v, %v", loaded, err)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,231 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// registry holds models added at run time, from config or from a cached
+// models.list response, on top of the built-in modelsByID.
+var registry = struct {
+	mu       sync.RWMutex
+	models   map[string]ModelSpec
+	aliases  map[string]string
+	listedAt time.Time
+}{models: map[string]ModelSpec{}, aliases: map[string]string{}}
+
+// RegisterModels adds specs to the registry. A spec with the ID of a built-in
+// or previously registered model replaces it, and its aliases take precedence
+// over built-in ones.
+func RegisterModels(specs ...ModelSpec) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for _, spec := range specs {
+		id := strings.ToLower(spec.ID)
+		registry.models[id] = spec
+		registry.aliases[id] = id
+		for _, alias := range spec.Aliases {
+			registry.aliases[strings.ToLower(alias)] = id
+		}
+	}
+}
+
+// ResetModels drops every registered model and the model listing, leaving
+// the built-in models.
+func ResetModels() {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.models = map[string]ModelSpec{}
+	registry.aliases = map[string]string{}
+	registry.listedAt = time.Time{}
+}
+
+// Models returns every known model, built-in and registered, sorted by ID.
+func Models() []ModelSpec {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	specs := make([]ModelSpec, 0, len(modelsByID)+len(registry.models))
+	for id, spec := range modelsByID {
+		if _, ok := registry.models[id]; !ok {
+			specs = append(specs, spec)
+		}
+	}
+	for _, spec := range registry.models {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].ID < specs[j].ID })
+	return specs
+}
+
+// IsImageModel reports whether a model ID names an image generation model.
+func IsImageModel(id string) bool {
+	return strings.Contains(strings.ToLower(id), "image")
+}
+
+func lookupModel(name string) (ModelSpec, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	if id, ok := registry.aliases[name]; ok {
+		return registry.models[id], true
+	}
+	if id, ok := aliasToModelID[name]; ok {
+		return modelsByID[id], true
+	}
+	return ModelSpec{}, false
+}
+
+func registeredSpecs() []ModelSpec {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	specs := make([]ModelSpec, 0, len(registry.models))
+	for _, spec := range registry.models {
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// listedAt returns when the registered model listing was fetched, or the zero
+// time if none was registered.
+func listedAt() time.Time {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.listedAt
+}
+
+// ListedModel is one model returned by the API's models.list endpoint.
+type ListedModel struct {
+	ID               string   `json:"id"`
+	DisplayName      string   `json:"display_name,omitempty"`
+	Description      string   `json:"description,omitempty"`
+	InputTokenLimit  int      `json:"input_token_limit,omitempty"`
+	OutputTokenLimit int      `json:"output_token_limit,omitempty"`
+	Methods          []string `json:"methods,omitempty"`
+}
+
+// ModelListing is a models.list result, cached between runs.
+type ModelListing struct {
+	RefreshedAt time.Time     `json:"refreshed_at"`
+	Models      []ListedModel `json:"models"`
+}
+
+type apiListModelsResponse struct {
+	Models        []apiModel `json:"models"`
+	NextPageToken string     `json:"nextPageToken,omitempty"`
+}
+
+type apiModel struct {
+	Name                       string   `json:"name"`
+	DisplayName                string   `json:"displayName,omitempty"`
+	Description                string   `json:"description,omitempty"`
+	InputTokenLimit            int      `json:"inputTokenLimit,omitempty"`
+	OutputTokenLimit           int      `json:"outputTokenLimit,omitempty"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods,omitempty"`
+}
+
+// ListModels fetches every model that supports generateContent from the
+// models.list endpoint.
+func (c *Client) ListModels(ctx context.Context) (*ModelListing, error) {
+	if err := c.requireREST("model listing"); err != nil {
+		return nil, err
+	}
+	listing := &ModelListing{RefreshedAt: time.Now().UTC()}
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"1000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		var page apiListModelsResponse
+		if err := c.doJSON(ctx, http.MethodGet, c.baseURL+"/models?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		for _, m := range page.Models {
+			if !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+				continue
+			}
+			listing.Models = append(listing.Models, ListedModel{
+				ID:               strings.TrimPrefix(m.Name, "models/"),
+				DisplayName:      m.DisplayName,
+				Description:      m.Description,
+				InputTokenLimit:  m.InputTokenLimit,
+				OutputTokenLimit: m.OutputTokenLimit,
+				Methods:          m.SupportedGenerationMethods,
+			})
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+	sort.Slice(listing.Models, func(i, j int) bool { return listing.Models[i].ID < listing.Models[j].ID })
+	return listing, nil
+}
+
+// Save writes the listing as JSON, creating parent directories as needed.
+func (l *ModelListing) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create model cache directory: %w", err)
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal model listing: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write model cache: %w", err)
+	}
+	return nil
+}
+
+// LoadModelListing reads a listing written by Save. A missing file returns an
+// error matching os.ErrNotExist.
+func LoadModelListing(path string) (*ModelListing, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read model cache: %w", err)
+	}
+	var listing ModelListing
+	if err := json.Unmarshal(data, &listing); err != nil {
+		return nil, fmt.Errorf("failed to parse model cache %s: %w", path, err)
+	}
+	return &listing, nil
+}
+
+// RegisterListing registers listed models that are not already known and
+// records the listing time. Image models get open capabilities; the listing
+// does not report output modalities, so other models, such as text-only
+// Gemini models, are told apart by ID and registered as TextOnly. Once a
+// listing is registered, clients reject model IDs it does not contain.
+func RegisterListing(l *ModelListing) {
+	var specs []ModelSpec
+	for _, m := range l.Models {
+		if _, ok := lookupModel(strings.ToLower(m.ID)); ok {
+			continue
+		}
+		spec := OpenModelSpec(m.ID)
+		if !IsImageModel(m.ID) {
+			spec = ModelSpec{ID: m.ID, TextOnly: true}
+		}
+		spec.DisplayName = m.DisplayName
+		spec.Source = SourceAPI
+		specs = append(specs, spec)
+	}
+	RegisterModels(specs...)
+
+	registry.mu.Lock()
+	registry.listedAt = l.RefreshedAt
+	registry.mu.Unlock()
+}
@@ -0,0 +1,155 @@
+package gemini
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxRetries   = 3
+	DefaultRetryBase    = time.Second
+	DefaultRetryMaxWait = time.Minute
+)
+
+// RetryPolicy controls how transient API failures are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of HTTP attempts per request, including the first.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry; it doubles on every attempt.
+	BaseDelay time.Duration
+	// MaxWait caps a single wait. A server-requested delay longer than this stops retrying.
+	MaxWait time.Duration
+	// Jitter is the fraction of each computed delay that is randomized (0 disables it).
+	Jitter float64
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DefaultMaxRetries + 1,
+		BaseDelay:   DefaultRetryBase,
+		MaxWait:     DefaultRetryMaxWait,
+		Jitter:      0.5,
+	}
+}
+
+// NoRetry performs a single attempt per request.
+func NoRetry() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+func (p RetryPolicy) normalized() RetryPolicy {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryBase
+	}
+	if p.MaxWait <= 0 {
+		p.MaxWait = DefaultRetryMaxWait
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Jitter > 1 {
+		p.Jitter = 1
+	}
+	return p
+}
+
+// backoff returns the exponential delay before retry number attempt (1-based).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxWait; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxWait {
+		delay = p.MaxWait
+	}
+	if p.Jitter > 0 {
+		spread := time.Duration(float64(delay) * p.Jitter)
+		delay = delay - spread + time.Duration(rand.Int64N(int64(spread)+1))
+	}
+	return delay
+}
+
+// nextDelay decides whether a failed attempt should be retried and how long to wait.
+func (p RetryPolicy) nextDelay(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	gerr, ok := err.(*GeminiError)
+	if !ok || !gerr.retryable {
+		return 0, false
+	}
+	if gerr.retryAfter > 0 {
+		if gerr.retryAfter > p.MaxWait {
+			return 0, false
+		}
+		return gerr.retryAfter, true
+	}
+	return p.backoff(attempt), true
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given either as seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// retryDelayFromDetails extracts google.rpc.RetryInfo.retryDelay from an error body.
+func retryDelayFromDetails(details []map[string]any) time.Duration {
+	info := findErrorDetail(details, "google.rpc.RetryInfo")
+	if info == nil {
+		return 0
+	}
+	delay, _ := info["retryDelay"].(string)
+	if d, err := time.ParseDuration(delay); err == nil && d > 0 {
+		return d
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
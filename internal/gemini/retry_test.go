@@ -0,0 +1,137 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerateRetriesTransientErrors(t *testing.T) {
+	imageData := testPNG(t)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"code":503,"message":"overloaded","status":"UNAVAILABLE"}}`))
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"code":429,"message":"quota","status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"7s"}]}}`))
+		default:
+			json.NewEncoder(w).Encode(apiGenerateContentResponse{
+				Candidates: []apiCandidate{{Content: &apiContent{
+					Role:  "model",
+					Parts: []*apiPart{{InlineData: &apiBlob{MIMEType: "image/png", Data: imageData}}},
+				}}},
+			})
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("test-key", "banana2", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.baseURL = srv.URL
+	var waits []time.Duration
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	result, err := client.Generate(context.Background(), "a banana", &GenerateOptions{Count: 1})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if result.Attempts != 3 || result.Retries != 2 {
+		t.Fatalf("attempts = %d, retries = %d, want 3 and 2", result.Attempts, result.Retries)
+	}
+	if len(waits) != 2 || waits[0] != 2*time.Second || waits[1] != 7*time.Second {
+		t.Fatalf("waits = %v, want [2s 7s]", waits)
+	}
+}
+
+func TestGenerateDoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"bad request","status":"INVALID_ARGUMENT"}}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("test-key", "banana2", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.baseURL = srv.URL
+	client.sleep = func(context.Context, time.Duration) error { return nil }
+
+	if _, err := client.Generate(context.Background(), "a banana", nil); err == nil {
+		t.Fatal("Generate succeeded, want error")
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+}
+
+func TestGenerateReturnsContextErrorWhenRetryWaitIsCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"code":503,"message":"overloaded","status":"UNAVAILABLE"}}`))
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("test-key", "banana2", time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.baseURL = srv.URL
+	ctx, cancel := context.WithCancel(context.Background())
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	if _, err := client.Generate(ctx, "a banana", &GenerateOptions{Count: 1}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Generate error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRetryPolicyNextDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxWait: 5 * time.Second}.normalized()
+
+	if d, ok := policy.nextDelay(1, &GeminiError{retryable: true}); !ok || d != time.Second {
+		t.Fatalf("first retry = %v, %v; want 1s, true", d, ok)
+	}
+	if d, ok := policy.nextDelay(2, &GeminiError{retryable: true}); !ok || d != 2*time.Second {
+		t.Fatalf("second retry = %v, %v; want 2s, true", d, ok)
+	}
+	if _, ok := policy.nextDelay(3, &GeminiError{retryable: true}); ok {
+		t.Fatal("retry allowed after MaxAttempts")
+	}
+	if _, ok := policy.nextDelay(1, &GeminiError{retryable: true, retryAfter: time.Minute}); ok {
+		t.Fatal("retry allowed when server delay exceeds MaxWait")
+	}
+	if got := parseRetryAfter("3", time.Now()); got != 3*time.Second {
+		t.Fatalf("parseRetryAfter(3) = %v, want 3s", got)
+	}
+}
@@ -0,0 +1,174 @@
+package gemini
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SafetySetting sets the block threshold for one harm category, using the
+// API's names such as HARM_CATEGORY_DANGEROUS_CONTENT and BLOCK_ONLY_HIGH.
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+type apiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+var harmCategories = map[string]string{
+	"harassment":        "HARM_CATEGORY_HARASSMENT",
+	"hate":              "HARM_CATEGORY_HATE_SPEECH",
+	"hate_speech":       "HARM_CATEGORY_HATE_SPEECH",
+	"sexual":            "HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"sexually_explicit": "HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"dangerous":         "HARM_CATEGORY_DANGEROUS_CONTENT",
+	"dangerous_content": "HARM_CATEGORY_DANGEROUS_CONTENT",
+	"civic":             "HARM_CATEGORY_CIVIC_INTEGRITY",
+	"civic_integrity":   "HARM_CATEGORY_CIVIC_INTEGRITY",
+}
+
+var blockThresholds = map[string]string{
+	"none":             "BLOCK_NONE",
+	"high":             "BLOCK_ONLY_HIGH",
+	"only_high":        "BLOCK_ONLY_HIGH",
+	"medium":           "BLOCK_MEDIUM_AND_ABOVE",
+	"medium_and_above": "BLOCK_MEDIUM_AND_ABOVE",
+	"low":              "BLOCK_LOW_AND_ABOVE",
+	"low_and_above":    "BLOCK_LOW_AND_ABOVE",
+	"off":              "OFF",
+}
+
+// SafetyCategories lists the short category names accepted by NewSafetySetting.
+func SafetyCategories() []string {
+	return []string{"harassment", "hate", "sexual", "dangerous", "civic"}
+}
+
+// SafetyThresholds lists the short threshold names accepted by NewSafetySetting.
+func SafetyThresholds() []string {
+	return []string{"none", "high", "medium", "low", "off"}
+}
+
+// NewSafetySetting normalizes a category and threshold given either as short
+// names (dangerous, none) or as the API's names (HARM_CATEGORY_DANGEROUS_CONTENT,
+// BLOCK_NONE).
+func NewSafetySetting(category, threshold string) (SafetySetting, error) {
+	key := safetyKey(category, "harm_category_")
+	cat, ok := harmCategories[key]
+	if !ok {
+		return SafetySetting{}, fmt.Errorf("unknown safety category %q (valid: %s)", category, strings.Join(SafetyCategories(), ", "))
+	}
+	key = safetyKey(threshold, "block_")
+	level, ok := blockThresholds[key]
+	if !ok {
+		return SafetySetting{}, fmt.Errorf("unknown safety threshold %q (valid: %s)", threshold, strings.Join(SafetyThresholds(), ", "))
+	}
+	return SafetySetting{Category: cat, Threshold: level}, nil
+}
+
+// ParseSafetySetting parses a category=threshold pair.
+func ParseSafetySetting(raw string) (SafetySetting, error) {
+	category, threshold, ok := strings.Cut(raw, "=")
+	if !ok {
+		return SafetySetting{}, fmt.Errorf("invalid safety setting %q, expected category=threshold", raw)
+	}
+	return NewSafetySetting(category, threshold)
+}
+
+// ParseSafetyMap normalizes a category to threshold map, such as the safety
+// section of the config file, sorted by category.
+func ParseSafetyMap(m map[string]string) ([]SafetySetting, error) {
+	settings := make([]SafetySetting, 0, len(m))
+	for category, threshold := range m {
+		s, err := NewSafetySetting(category, threshold)
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, s)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Category < settings[j].Category })
+	return settings, nil
+}
+
+// WithSafetySettings sets default safety thresholds for every request.
+// GenerateOptions.SafetySettings override them per category.
+func WithSafetySettings(settings []SafetySetting) ClientOption {
+	return func(c *Client) {
+		c.safety = mergeSafetySettings(c.safety, settings)
+	}
+}
+
+// WithoutSafetySettings drops the defaults set by earlier options, so only
+// GenerateOptions.SafetySettings are sent.
+func WithoutSafetySettings() ClientOption {
+	return func(c *Client) {
+		c.safety = nil
+	}
+}
+
+func safetyKey(name, prefix string) string {
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.ReplaceAll(key, "-", "_")
+	return strings.TrimPrefix(key, prefix)
+}
+
+// mergeSafetySettings returns base with each override replacing the setting
+// for its category.
+func mergeSafetySettings(base, overrides []SafetySetting) []SafetySetting {
+	if len(overrides) == 0 {
+		return base
+	}
+	merged := make([]SafetySetting, 0, len(base)+len(overrides))
+	for _, s := range base {
+		if !containsCategory(overrides, s.Category) {
+			merged = append(merged, s)
+		}
+	}
+	for i, s := range overrides {
+		if !containsCategory(overrides[i+1:], s.Category) {
+			merged = append(merged, s)
+		}
+	}
+	return merged
+}
+
+func containsCategory(settings []SafetySetting, category string) bool {
+	for _, s := range settings {
+		if s.Category == category {
+			return true
+		}
+	}
+	return false
+}
+
+// buildSafetySettings combines the client defaults with opts for one request.
+func (c *Client) buildSafetySettings(opts *GenerateOptions) []apiSafetySetting {
+	settings := mergeSafetySettings(c.safety, opts.SafetySettings)
+	if len(settings) == 0 {
+		return nil
+	}
+	out := make([]apiSafetySetting, 0, len(settings))
+	for _, s := range settings {
+		out = append(out, apiSafetySetting{Category: s.Category, Threshold: s.Threshold})
+	}
+	return out
+}
+
+// blockingCategory picks the harm category that most likely caused a block:
+// a rating marked blocked, or else the one with the highest probability of
+// at least MEDIUM.
+func blockingCategory(ratings []apiSafetyRating) string {
+	rank := map[string]int{"MEDIUM": 1, "HIGH": 2}
+	best, bestRank := "", 0
+	for _, r := range ratings {
+		if r.Blocked {
+			return r.Category
+		}
+		if rank[r.Probability] > bestRank {
+			best, bestRank = r.Category, rank[r.Probability]
+		}
+	}
+	return best
+}
@@ -0,0 +1,55 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sampling holds optional sampling parameters. Nil fields leave the model's
+// default in place.
+type Sampling struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+	// Seed makes sampling repeatable. With GenerateOptions.Count above 1,
+	// candidate N (from 0) is sent Seed+N so the candidates still differ.
+	Seed *int32 `json:"seed,omitempty"`
+	// CandidateCount asks the model for several candidates in one request
+	// (the API's candidateCount), as opposed to Count's separate requests.
+	CandidateCount int `json:"candidate_count,omitempty"`
+}
+
+// IsZero reports whether no sampling parameter is set.
+func (s Sampling) IsZero() bool {
+	return s.Temperature == nil && s.TopP == nil && s.TopK == nil && s.Seed == nil && s.CandidateCount == 0
+}
+
+// withSeedOffset returns s with Seed advanced by n, for candidate n of Count.
+func (s Sampling) withSeedOffset(n int) Sampling {
+	if s.Seed != nil && n != 0 {
+		seed := *s.Seed + int32(n)
+		s.Seed = &seed
+	}
+	return s
+}
+
+// ValidateSampling checks sampling parameters against the model's limits.
+func ValidateSampling(spec ModelSpec, s Sampling) error {
+	var problems []string
+	if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > spec.MaxTemperature) {
+		problems = append(problems, fmt.Sprintf("temperature must be between 0 and %g", spec.MaxTemperature))
+	}
+	if s.TopP != nil && (*s.TopP < 0 || *s.TopP > 1) {
+		problems = append(problems, "top-p must be between 0 and 1")
+	}
+	if s.TopK != nil && (*s.TopK < 1 || *s.TopK > spec.MaxTopK) {
+		problems = append(problems, fmt.Sprintf("top-k must be between 1 and %d", spec.MaxTopK))
+	}
+	if s.CandidateCount < 0 || s.CandidateCount > max(spec.MaxCandidateCount, 1) {
+		problems = append(problems, fmt.Sprintf("candidate count must be between 1 and %d", max(spec.MaxCandidateCount, 1)))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("model %s: %s", spec.ID, strings.Join(problems, "; "))
+	}
+	return nil
+}
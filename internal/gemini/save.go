@@ -0,0 +1,37 @@
+package gemini
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lyalindotcom/nano-banana-cli/internal/image"
+)
+
+// SaveImage writes img to outputPath, converting it to the format implied by
+// the extension (see image.FormatForPath), and returns the format written. A
+// path without an extension keeps the API's format. quality is the JPEG
+// quality, 0 for image.DefaultJPEGQuality.
+func (c *Client) SaveImage(img *GeneratedImage, outputPath string, quality int) (string, error) {
+	format, err := image.FormatForPath(outputPath)
+	if err != nil {
+		return "", err
+	}
+	data, format, err := image.Convert(img.Data, format, quality)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Dir(outputPath)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+
+	return format, nil
+}
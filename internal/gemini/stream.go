@@ -0,0 +1,131 @@
+package gemini
+
+import (
+	"context"
+	"strings"
+)
+
+// Stream event types passed to GenerateOptions.OnEvent.
+const (
+	EventThought = "thought"
+	EventText    = "text"
+	EventImage   = "image"
+	EventDone    = "done"
+)
+
+// StreamEvent is a piece of a streamed response. Thought events carry either
+// thought text or a thought image; text events carry a text delta; image events
+// carry a final image; a done event ends each candidate.
+type StreamEvent struct {
+	Type         string
+	Candidate    int
+	Text         string
+	Image        *GeneratedImage
+	FinishReason string
+}
+
+// streamContent calls streamGenerateContent, passing each chunk to onChunk,
+// and returns the chunks merged into a single response.
+func (c *Client) streamContent(ctx context.Context, payload *apiGenerateContentRequest, onChunk func(*apiGenerateContentResponse)) (*apiGenerateContentResponse, error) {
+	merged := &apiGenerateContentResponse{}
+	err := c.backend.streamGenerateContent(ctx, c.model.Spec.ID, payload, func(chunk *apiGenerateContentResponse) error {
+		mergeChunk(merged, chunk)
+		onChunk(chunk)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// mergeChunk folds a streamed chunk into resp. Parts are appended, with text
+// deltas joined onto the previous text part of the same kind; metadata from
+// later chunks replaces earlier values.
+func mergeChunk(resp, chunk *apiGenerateContentResponse) {
+	if chunk.PromptFeedback != nil {
+		resp.PromptFeedback = chunk.PromptFeedback
+	}
+	if chunk.UsageMetadata != nil {
+		resp.UsageMetadata = chunk.UsageMetadata
+	}
+	if len(chunk.Candidates) == 0 {
+		return
+	}
+	if len(resp.Candidates) == 0 {
+		resp.Candidates = []apiCandidate{{Content: &apiContent{Role: "model"}}}
+	}
+
+	dst := &resp.Candidates[0]
+	src := chunk.Candidates[0]
+	if src.FinishReason != "" {
+		dst.FinishReason = src.FinishReason
+		dst.FinishMessage = src.FinishMessage
+	}
+	if len(src.SafetyRatings) > 0 {
+		dst.SafetyRatings = src.SafetyRatings
+	}
+	if src.GroundingMetadata != nil {
+		dst.GroundingMetadata = src.GroundingMetadata
+	}
+	if src.Content == nil {
+		return
+	}
+	if src.Content.Role != "" {
+		dst.Content.Role = src.Content.Role
+	}
+	for _, part := range src.Content.Parts {
+		if part == nil {
+			continue
+		}
+		parts := dst.Content.Parts
+		if n := len(parts); n > 0 && part.InlineData == nil && parts[n-1].InlineData == nil && parts[n-1].Thought == part.Thought {
+			last := parts[n-1]
+			last.Text += part.Text
+			if last.ThoughtSignature == "" {
+				last.ThoughtSignature = part.ThoughtSignature
+			}
+			continue
+		}
+		copied := *part
+		dst.Content.Parts = append(dst.Content.Parts, &copied)
+	}
+}
+
+// chunkEvents converts the parts of a streamed chunk into events.
+func chunkEvents(chunk *apiGenerateContentResponse, candidate int) []StreamEvent {
+	if len(chunk.Candidates) == 0 || chunk.Candidates[0].Content == nil {
+		return nil
+	}
+	var events []StreamEvent
+	for _, part := range chunk.Candidates[0].Content.Parts {
+		if part == nil {
+			continue
+		}
+		event := StreamEvent{Candidate: candidate}
+		switch {
+		case part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/"):
+			img := &GeneratedImage{
+				Candidate:        candidate,
+				Data:             part.InlineData.Data,
+				MimeType:         part.InlineData.MIMEType,
+				Thought:          part.Thought,
+				ThoughtSignature: part.ThoughtSignature,
+			}
+			img.Width, img.Height = imageDimensions(img.Data)
+			event.Type, event.Image = EventImage, img
+			if part.Thought {
+				event.Type = EventThought
+			}
+		case part.Text != "":
+			event.Type, event.Text = EventText, part.Text
+			if part.Thought {
+				event.Type = EventThought
+			}
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
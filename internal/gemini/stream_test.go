@@ -0,0 +1,84 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGenerateStreamsEvents(t *testing.T) {
+	imageData := testPNG(t)
+	chunks := []apiGenerateContentResponse{
+		{Candidates: []apiCandidate{{Content: &apiContent{Role: "model", Parts: []*apiPart{{Text: "Sketching ", Thought: true}}}}}},
+		{Candidates: []apiCandidate{{Content: &apiContent{Parts: []*apiPart{{Text: "the layout.", Thought: true, ThoughtSignature: "c2ln"}}}}}},
+		{Candidates: []apiCandidate{{Content: &apiContent{Parts: []*apiPart{{InlineData: &apiBlob{MIMEType: "image/png", Data: imageData}}}}}}},
+		{
+			Candidates:    []apiCandidate{{Content: &apiContent{Parts: []*apiPart{{Text: "Done."}}}, FinishReason: "STOP"}},
+			UsageMetadata: &apiUsageMetadata{PromptTokenCount: 5, CandidatesTokenCount: 1290, TotalTokenCount: 1295},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-3-pro-image-preview:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			data, _ := json.Marshal(chunk)
+			fmt.Fprintf(w, "data: %s\r\n\r\n", data)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("k", "pro", time.Second, WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	var events []StreamEvent
+	result, err := client.Generate(context.Background(), "a map", &GenerateOptions{
+		IncludeThoughts: true,
+		OnEvent:         func(e StreamEvent) { events = append(events, e) },
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	if fmt.Sprint(types) != "[thought thought image text done]" || events[4].FinishReason != "STOP" {
+		t.Fatalf("events = %v", types)
+	}
+	if len(result.Images) != 1 || result.Usage == nil || result.Usage.TotalTokens != 1295 || result.FinishReason != "STOP" {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(result.Texts) != 2 || result.Texts[0].Text != "Sketching the layout." || result.Texts[0].ThoughtSignature != "c2ln" {
+		t.Fatalf("texts = %+v", result.Texts)
+	}
+}
+
+func TestStreamErrorAfterOutputIsNotRetried(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"hm\",\"thought\":true}]}}]}\r\n\r\n")
+		fmt.Fprint(w, "data: {\"error\":{\"code\":503,\"message\":\"overloaded\",\"status\":\"UNAVAILABLE\"}}\r\n\r\n")
+	}))
+	defer srv.Close()
+
+	client, err := NewClient("k", "pro", time.Second, WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.sleep = func(context.Context, time.Duration) error { return nil }
+
+	_, err = client.Generate(context.Background(), "x", &GenerateOptions{OnEvent: func(StreamEvent) {}})
+	gerr, ok := err.(*GeminiError)
+	if !ok || gerr.Code != ErrServiceUnavailable || calls != 1 {
+		t.Fatalf("err = %v, calls = %d", err, calls)
+	}
+}
@@ -0,0 +1,116 @@
+package gemini
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	apiKeyPattern   = regexp.MustCompile(`AIza[0-9A-Za-z_\-]{30,}`)
+	keyParamPattern = regexp.MustCompile(`([?&](?:key|api_key|access_token)=)[^&\s"']+`)
+)
+
+// sensitiveHeaders are masked in traces.
+var sensitiveHeaders = map[string]bool{
+	"X-Goog-Api-Key":      true,
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// WithTrace logs request and response metadata for every HTTP call to w.
+// Credentials are masked; bodies are never logged.
+func WithTrace(w io.Writer) ClientOption {
+	return func(c *Client) {
+		c.trace = w
+	}
+}
+
+// maskSecret keeps the last four characters of a secret for identification.
+func maskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
+
+// redactSecrets removes API keys from text that may echo request URLs or headers.
+func redactSecrets(text string, secrets ...string) string {
+	for _, secret := range secrets {
+		if strings.TrimSpace(secret) != "" {
+			text = strings.ReplaceAll(text, secret, maskSecret(secret))
+		}
+	}
+	text = keyParamPattern.ReplaceAllString(text, "${1}****")
+	return apiKeyPattern.ReplaceAllStringFunc(text, maskSecret)
+}
+
+type traceTransport struct {
+	base    http.RoundTripper
+	out     io.Writer
+	secrets []string
+	mu      sync.Mutex
+	seq     int
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.seq++
+	id := t.seq
+	t.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[http %d] --> %s %s\n", id, req.Method, redactSecrets(req.URL.String(), t.secrets...))
+	writeHeaders(&b, id, req.Header, t.secrets)
+	if req.ContentLength > 0 {
+		fmt.Fprintf(&b, "[http %d]     body: %d bytes\n", id, req.ContentLength)
+	}
+	t.write(b.String())
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	b.Reset()
+	if err != nil {
+		fmt.Fprintf(&b, "[http %d] <-- error after %s: %s\n", id, elapsed, redactSecrets(err.Error(), t.secrets...))
+		t.write(b.String())
+		return resp, err
+	}
+	fmt.Fprintf(&b, "[http %d] <-- %s in %s\n", id, resp.Status, elapsed)
+	writeHeaders(&b, id, resp.Header, t.secrets)
+	if resp.ContentLength >= 0 {
+		fmt.Fprintf(&b, "[http %d]     body: %d bytes\n", id, resp.ContentLength)
+	}
+	t.write(b.String())
+	return resp, nil
+}
+
+func (t *traceTransport) write(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.out, s)
+}
+
+func writeHeaders(b *strings.Builder, id int, header http.Header, secrets []string) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(header[name], ", ")
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			value = maskSecret(value)
+		} else {
+			value = redactSecrets(value, secrets...)
+		}
+		fmt.Fprintf(b, "[http %d]     %s: %s\n", id, name, value)
+	}
+}
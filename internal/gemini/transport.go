@@ -0,0 +1,82 @@
+package gemini
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// TransportConfig describes network settings for talking to the API through
+// proxies or endpoints with private certificate authorities.
+type TransportConfig struct {
+	// ProxyURL routes requests through an HTTP(S) proxy. Empty uses HTTPS_PROXY and friends.
+	ProxyURL string
+	// CACertFile is a PEM bundle trusted in addition to the system roots.
+	CACertFile string
+}
+
+// NewTransport builds an http.RoundTripper from cfg, starting from http.DefaultTransport.
+func NewTransport(cfg TransportConfig) (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if strings.TrimSpace(cfg.ProxyURL) != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if strings.TrimSpace(cfg.CACertFile) != "" {
+		pem, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACertFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return transport, nil
+}
+
+// WithBaseURL points the client at a different API root, such as a regional
+// endpoint, an egress proxy or a local fake. It should include the API version,
+// e.g. http://localhost:8080/v1beta.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		if baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/"); baseURL != "" {
+			c.baseURL = baseURL
+		}
+	}
+}
+
+// WithTransport replaces the HTTP transport used for API calls.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		if rt != nil {
+			c.httpClient.Transport = rt
+		}
+	}
+}
+
+// WithHeaders adds headers to every API request.
+func WithHeaders(headers map[string]string) ClientOption {
+	return func(c *Client) {
+		if c.headers == nil {
+			c.headers = http.Header{}
+		}
+		for k, v := range headers {
+			c.headers.Set(k, v)
+		}
+	}
+}
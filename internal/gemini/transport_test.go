@@ -0,0 +1,53 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestClientOptionsRouteRequests(t *testing.T) {
+	var got *http.Request
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = r
+		return nil, errors.New("stop")
+	})
+
+	client, err := NewClient("test-key", "banana2", time.Second,
+		WithBaseURL("http://localhost:9999/v1beta/"),
+		WithHeaders(map[string]string{"X-Team": "assets"}),
+		WithTransport(rt),
+		WithRetryPolicy(NoRetry()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	if _, err := client.Generate(context.Background(), "a banana", nil); err == nil {
+		t.Fatal("Generate succeeded, want transport error")
+	}
+	if got == nil {
+		t.Fatal("custom transport was not used")
+	}
+	if got.URL.Host != "localhost:9999" || got.URL.Path != "/v1beta/models/"+ModelFlash31+":generateContent" {
+		t.Fatalf("request URL = %s", got.URL)
+	}
+	if got.Header.Get("X-Team") != "assets" {
+		t.Fatalf("X-Team header = %q, want assets", got.Header.Get("X-Team"))
+	}
+}
+
+func TestNewTransportRejectsBadSettings(t *testing.T) {
+	if _, err := NewTransport(TransportConfig{ProxyURL: "::not a url"}); err == nil {
+		t.Fatal("NewTransport accepted an invalid proxy URL")
+	}
+	if _, err := NewTransport(TransportConfig{CACertFile: "/does/not/exist.pem"}); err == nil {
+		t.Fatal("NewTransport accepted a missing CA file")
+	}
+}
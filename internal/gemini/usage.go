@@ -0,0 +1,95 @@
+package gemini
+
+import "math"
+
+// Usage reports token counts for one or more generateContent calls.
+type Usage struct {
+	PromptTokens      int `json:"prompt_tokens"`
+	CandidateTokens   int `json:"candidate_tokens"`
+	ThoughtTokens     int `json:"thought_tokens,omitempty"`
+	ImageOutputTokens int `json:"image_output_tokens,omitempty"`
+	TextOutputTokens  int `json:"text_output_tokens,omitempty"`
+	TotalTokens       int `json:"total_tokens"`
+}
+
+// ModelPricing holds USD prices per million tokens.
+type ModelPricing struct {
+	InputPerMillion       float64 `json:"input_per_million"`
+	TextOutputPerMillion  float64 `json:"text_output_per_million"`
+	ImageOutputPerMillion float64 `json:"image_output_per_million"`
+}
+
+type apiUsageMetadata struct {
+	PromptTokenCount        int                     `json:"promptTokenCount,omitempty"`
+	CandidatesTokenCount    int                     `json:"candidatesTokenCount,omitempty"`
+	ThoughtsTokenCount      int                     `json:"thoughtsTokenCount,omitempty"`
+	TotalTokenCount         int                     `json:"totalTokenCount,omitempty"`
+	CandidatesTokensDetails []apiModalityTokenCount `json:"candidatesTokensDetails,omitempty"`
+}
+
+type apiModalityTokenCount struct {
+	Modality   string `json:"modality"`
+	TokenCount int    `json:"tokenCount"`
+}
+
+func translateUsage(meta *apiUsageMetadata) *Usage {
+	if meta == nil {
+		return nil
+	}
+	u := &Usage{
+		PromptTokens:    meta.PromptTokenCount,
+		CandidateTokens: meta.CandidatesTokenCount,
+		ThoughtTokens:   meta.ThoughtsTokenCount,
+		TotalTokens:     meta.TotalTokenCount,
+	}
+	if len(meta.CandidatesTokensDetails) == 0 {
+		// Without a modality breakdown, image models' output is billed as image tokens.
+		u.ImageOutputTokens = meta.CandidatesTokenCount
+	}
+	for _, detail := range meta.CandidatesTokensDetails {
+		switch detail.Modality {
+		case "IMAGE":
+			u.ImageOutputTokens += detail.TokenCount
+		default:
+			u.TextOutputTokens += detail.TokenCount
+		}
+	}
+	if u.TotalTokens == 0 {
+		u.TotalTokens = u.PromptTokens + u.CandidateTokens + u.ThoughtTokens
+	}
+	return u
+}
+
+// Add accumulates other into u and returns the sum; either side may be nil.
+func (u *Usage) Add(other *Usage) *Usage {
+	if other == nil {
+		return u
+	}
+	if u == nil {
+		cp := *other
+		return &cp
+	}
+	u.PromptTokens += other.PromptTokens
+	u.CandidateTokens += other.CandidateTokens
+	u.ThoughtTokens += other.ThoughtTokens
+	u.ImageOutputTokens += other.ImageOutputTokens
+	u.TextOutputTokens += other.TextOutputTokens
+	u.TotalTokens += other.TotalTokens
+	return u
+}
+
+// IsZero reports whether no prices are set.
+func (p ModelPricing) IsZero() bool {
+	return p.InputPerMillion == 0 && p.TextOutputPerMillion == 0 && p.ImageOutputPerMillion == 0
+}
+
+// EstimateCost prices usage in USD. Thought tokens are billed at the text output rate.
+func (p ModelPricing) EstimateCost(u *Usage) float64 {
+	if u == nil {
+		return 0
+	}
+	cost := float64(u.PromptTokens)*p.InputPerMillion +
+		float64(u.TextOutputTokens+u.ThoughtTokens)*p.TextOutputPerMillion +
+		float64(u.ImageOutputTokens)*p.ImageOutputPerMillion
+	return math.Round(cost) / 1e6
+}
@@ -0,0 +1,30 @@
+package gemini
+
+import "testing"
+
+func TestTranslateUsageAndEstimateCost(t *testing.T) {
+	usage := translateUsage(&apiUsageMetadata{
+		PromptTokenCount:     1000,
+		CandidatesTokenCount: 1300,
+		ThoughtsTokenCount:   200,
+		TotalTokenCount:      2500,
+		CandidatesTokensDetails: []apiModalityTokenCount{
+			{Modality: "IMAGE", TokenCount: 1290},
+			{Modality: "TEXT", TokenCount: 10},
+		},
+	})
+	if usage.ImageOutputTokens != 1290 || usage.TextOutputTokens != 10 {
+		t.Fatalf("modality split = %d image, %d text", usage.ImageOutputTokens, usage.TextOutputTokens)
+	}
+
+	pricing := ModelPricing{InputPerMillion: 2, TextOutputPerMillion: 12, ImageOutputPerMillion: 120}
+	// 1000*2 + (10+200)*12 + 1290*120 = 2000 + 2520 + 154800 micro-dollars
+	if got, want := pricing.EstimateCost(usage), 0.15932; got != want {
+		t.Fatalf("EstimateCost = %v, want %v", got, want)
+	}
+
+	total := (*Usage)(nil).Add(usage).Add(usage)
+	if total.TotalTokens != 5000 {
+		t.Fatalf("accumulated total = %d, want 5000", total.TotalTokens)
+	}
+}
@@ -0,0 +1,312 @@
+package gemini
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/auth/credentials"
+	"cloud.google.com/go/auth/httptransport"
+	"google.golang.org/genai"
+)
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// VertexConfig selects a Google Cloud project and region for the Vertex AI
+// backend. Requests are authenticated with Application Default Credentials.
+type VertexConfig struct {
+	Project  string
+	Location string
+}
+
+// WithVertex sends requests to Vertex AI through the genai SDK instead of the
+// AI Studio REST API. No API key is needed.
+func WithVertex(cfg VertexConfig) ClientOption {
+	return func(c *Client) {
+		c.vertex = &cfg
+	}
+}
+
+// vertexBackend calls generateContent and streamGenerateContent on Vertex AI
+// via the genai SDK.
+type vertexBackend struct {
+	c      *Client
+	models *genai.Models
+}
+
+func newVertexBackend(ctx context.Context, c *Client, cfg VertexConfig) (*vertexBackend, error) {
+	if strings.TrimSpace(cfg.Project) == "" {
+		return nil, fmt.Errorf("a Google Cloud project is required for the Vertex AI backend")
+	}
+	if strings.TrimSpace(cfg.Location) == "" {
+		cfg.Location = "global"
+	}
+
+	// genai only adds credentials when it builds the HTTP client itself, so
+	// build an authenticated client on top of our transport (proxy, CA, trace).
+	httpClient, err := httptransport.NewClient(&httptransport.Options{
+		BaseRoundTripper: c.httpClient.Transport,
+		DetectOpts:       &credentials.DetectOptions{Scopes: []string{cloudPlatformScope}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Google Cloud credentials: %w", err)
+	}
+	httpClient.Timeout = c.timeout
+
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		Backend:     genai.BackendVertexAI,
+		Project:     cfg.Project,
+		Location:    cfg.Location,
+		HTTPClient:  httpClient,
+		HTTPOptions: genai.HTTPOptions{Headers: c.headers.Clone()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vertex AI client: %w", err)
+	}
+	return &vertexBackend{c: c, models: client.Models}, nil
+}
+
+func (b *vertexBackend) generateContent(ctx context.Context, model string, req *apiGenerateContentRequest) (*apiGenerateContentResponse, error) {
+	contents, err := toGenaiContents(req.Contents)
+	if err != nil {
+		return nil, err
+	}
+	config, err := toGenaiConfig(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, b.c.classifyVertexError(err)
+	}
+	return fromGenaiResponse(resp), nil
+}
+
+func (b *vertexBackend) streamGenerateContent(ctx context.Context, model string, req *apiGenerateContentRequest, yield func(*apiGenerateContentResponse) error) error {
+	contents, err := toGenaiContents(req.Contents)
+	if err != nil {
+		return err
+	}
+	config, err := toGenaiConfig(req)
+	if err != nil {
+		return err
+	}
+
+	for resp, err := range b.models.GenerateContentStream(ctx, model, contents, config) {
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return b.c.classifyVertexError(err)
+		}
+		if err := yield(fromGenaiResponse(resp)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// classifyVertexError maps a genai SDK error onto the same GeminiError codes
+// the REST backend produces.
+func (c *Client) classifyVertexError(err error) error {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return c.classifyError(err)
+	}
+	gerr := &GeminiError{
+		HTTPStatus: apiErr.Code,
+		RawMessage: strings.TrimSpace(apiErr.Message),
+		Details:    apiErr.Details,
+		retryable:  isRetryableStatus(apiErr.Code),
+	}
+	// Unparseable error bodies carry the HTTP status line rather than an enum.
+	if !strings.Contains(apiErr.Status, " ") {
+		gerr.Status = apiErr.Status
+	}
+	gerr.retryAfter = retryDelayFromDetails(gerr.Details)
+	gerr.Code, gerr.Message = errorCodeFor(gerr)
+	return gerr
+}
+
+func toGenaiContents(in []*apiContent) ([]*genai.Content, error) {
+	out := make([]*genai.Content, 0, len(in))
+	for _, content := range in {
+		if content == nil {
+			continue
+		}
+		gc := &genai.Content{Role: content.Role}
+		for _, part := range content.Parts {
+			if part == nil {
+				continue
+			}
+			gp := &genai.Part{Text: part.Text, Thought: part.Thought}
+			if part.InlineData != nil {
+				gp.InlineData = &genai.Blob{MIMEType: part.InlineData.MIMEType, Data: part.InlineData.Data}
+			}
+			if part.FileData != nil {
+				gp.FileData = &genai.FileData{MIMEType: part.FileData.MIMEType, FileURI: part.FileData.FileURI}
+			}
+			if part.ThoughtSignature != "" {
+				sig, err := base64.StdEncoding.DecodeString(part.ThoughtSignature)
+				if err != nil {
+					return nil, fmt.Errorf("invalid thought signature in history: %w", err)
+				}
+				gp.ThoughtSignature = sig
+			}
+			gc.Parts = append(gc.Parts, gp)
+		}
+		out = append(out, gc)
+	}
+	return out, nil
+}
+
+func toGenaiConfig(req *apiGenerateContentRequest) (*genai.GenerateContentConfig, error) {
+	config := &genai.GenerateContentConfig{}
+	if req.SystemInstruction != nil {
+		config.SystemInstruction = &genai.Content{}
+		for _, part := range req.SystemInstruction.Parts {
+			config.SystemInstruction.Parts = append(config.SystemInstruction.Parts, &genai.Part{Text: part.Text})
+		}
+	}
+	if gc := req.GenerationConfig; gc != nil {
+		config.ResponseModalities = gc.ResponseModalities
+		config.CandidateCount = int32(gc.CandidateCount)
+		config.Seed = gc.Seed
+		if gc.Temperature != nil {
+			config.Temperature = genai.Ptr(float32(*gc.Temperature))
+		}
+		if gc.TopP != nil {
+			config.TopP = genai.Ptr(float32(*gc.TopP))
+		}
+		if gc.TopK != nil {
+			config.TopK = genai.Ptr(float32(*gc.TopK))
+		}
+		if gc.ImageConfig != nil {
+			config.ImageConfig = &genai.ImageConfig{
+				AspectRatio: gc.ImageConfig.AspectRatio,
+				ImageSize:   gc.ImageConfig.ImageSize,
+			}
+		}
+		if gc.ThinkingConfig != nil {
+			config.ThinkingConfig = &genai.ThinkingConfig{
+				IncludeThoughts: gc.ThinkingConfig.IncludeThoughts,
+				ThinkingLevel:   genai.ThinkingLevel(strings.ToUpper(gc.ThinkingConfig.ThinkingLevel)),
+			}
+		}
+	}
+	for _, s := range req.SafetySettings {
+		config.SafetySettings = append(config.SafetySettings, &genai.SafetySetting{
+			Category:  genai.HarmCategory(s.Category),
+			Threshold: genai.HarmBlockThreshold(s.Threshold),
+		})
+	}
+	for _, tool := range req.Tools {
+		if tool.GoogleSearch == nil {
+			continue
+		}
+		if tool.GoogleSearch.SearchTypes != nil && tool.GoogleSearch.SearchTypes.ImageSearch != nil {
+			return nil, &GeminiError{Code: ErrInvalidInput, Message: "Google Image Search grounding is not available on the Vertex AI backend"}
+		}
+		config.Tools = append(config.Tools, &genai.Tool{GoogleSearch: &genai.GoogleSearch{}})
+	}
+	return config, nil
+}
+
+func fromGenaiResponse(resp *genai.GenerateContentResponse) *apiGenerateContentResponse {
+	out := &apiGenerateContentResponse{}
+	if resp == nil {
+		return out
+	}
+	for _, cand := range resp.Candidates {
+		if cand == nil {
+			continue
+		}
+		out.Candidates = append(out.Candidates, apiCandidate{
+			Content:           fromGenaiContent(cand.Content),
+			FinishReason:      string(cand.FinishReason),
+			FinishMessage:     cand.FinishMessage,
+			SafetyRatings:     fromGenaiSafetyRatings(cand.SafetyRatings),
+			GroundingMetadata: fromGenaiGrounding(cand.GroundingMetadata),
+		})
+	}
+	if fb := resp.PromptFeedback; fb != nil {
+		out.PromptFeedback = &apiPromptFeedback{
+			BlockReason:        string(fb.BlockReason),
+			BlockReasonMessage: fb.BlockReasonMessage,
+			SafetyRatings:      fromGenaiSafetyRatings(fb.SafetyRatings),
+		}
+	}
+	if meta := resp.UsageMetadata; meta != nil {
+		usage := &apiUsageMetadata{
+			PromptTokenCount:     int(meta.PromptTokenCount),
+			CandidatesTokenCount: int(meta.CandidatesTokenCount),
+			ThoughtsTokenCount:   int(meta.ThoughtsTokenCount),
+			TotalTokenCount:      int(meta.TotalTokenCount),
+		}
+		for _, d := range meta.CandidatesTokensDetails {
+			if d != nil {
+				usage.CandidatesTokensDetails = append(usage.CandidatesTokensDetails, apiModalityTokenCount{
+					Modality:   string(d.Modality),
+					TokenCount: int(d.TokenCount),
+				})
+			}
+		}
+		out.UsageMetadata = usage
+	}
+	return out
+}
+
+func fromGenaiContent(in *genai.Content) *apiContent {
+	if in == nil {
+		return nil
+	}
+	out := &apiContent{Role: in.Role}
+	for _, part := range in.Parts {
+		if part == nil {
+			continue
+		}
+		ap := &apiPart{Text: part.Text, Thought: part.Thought}
+		if part.InlineData != nil {
+			ap.InlineData = &apiBlob{MIMEType: part.InlineData.MIMEType, Data: part.InlineData.Data}
+		}
+		if len(part.ThoughtSignature) > 0 {
+			ap.ThoughtSignature = base64.StdEncoding.EncodeToString(part.ThoughtSignature)
+		}
+		out.Parts = append(out.Parts, ap)
+	}
+	return out
+}
+
+func fromGenaiSafetyRatings(in []*genai.SafetyRating) []apiSafetyRating {
+	var out []apiSafetyRating
+	for _, r := range in {
+		if r != nil {
+			out = append(out, apiSafetyRating{Category: string(r.Category), Probability: string(r.Probability), Blocked: r.Blocked})
+		}
+	}
+	return out
+}
+
+func fromGenaiGrounding(in *genai.GroundingMetadata) *apiGroundingMetadata {
+	if in == nil {
+		return nil
+	}
+	out := &apiGroundingMetadata{WebSearchQueries: in.WebSearchQueries}
+	if in.SearchEntryPoint != nil {
+		out.SearchEntryPoint = &apiSearchEntryPoint{RenderedContent: in.SearchEntryPoint.RenderedContent}
+	}
+	for _, chunk := range in.GroundingChunks {
+		if chunk != nil && chunk.Web != nil {
+			out.GroundingChunks = append(out.GroundingChunks, apiGroundingChunk{
+				Web: &apiGroundingChunkWeb{URI: chunk.Web.URI, Title: chunk.Web.Title},
+			})
+		}
+	}
+	return out
+}
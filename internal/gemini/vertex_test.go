@@ -0,0 +1,69 @@
+package gemini
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestVertexContentRoundTripKeepsThoughtSignatures(t *testing.T) {
+	in := []*apiContent{{
+		Role: "model",
+		Parts: []*apiPart{
+			{Text: "thinking", Thought: true, ThoughtSignature: "c2lnbmF0dXJl"},
+			{InlineData: &apiBlob{MIMEType: "image/png", Data: []byte{1, 2, 3}}},
+		},
+	}}
+
+	contents, err := toGenaiContents(in)
+	if err != nil {
+		t.Fatalf("toGenaiContents: %v", err)
+	}
+	if got := string(contents[0].Parts[0].ThoughtSignature); got != "signature" {
+		t.Fatalf("decoded signature = %q", got)
+	}
+
+	out := fromGenaiContent(contents[0])
+	if out.Parts[0].ThoughtSignature != in[0].Parts[0].ThoughtSignature {
+		t.Fatalf("signature = %q, want %q", out.Parts[0].ThoughtSignature, in[0].Parts[0].ThoughtSignature)
+	}
+	if out.Parts[1].InlineData == nil || out.Parts[1].InlineData.MIMEType != "image/png" {
+		t.Fatalf("inline data not preserved: %+v", out.Parts[1])
+	}
+}
+
+func TestVertexConfigRejectsImageSearch(t *testing.T) {
+	client := &Client{model: ResolveModel("banana2")}
+	opts := &GenerateOptions{GroundWeb: true, GroundImage: true}
+	_, err := toGenaiConfig(&apiGenerateContentRequest{Tools: client.buildTools(opts)})
+	var gerr *GeminiError
+	if !errors.As(err, &gerr) || gerr.Code != ErrInvalidInput {
+		t.Fatalf("error = %v, want %s", err, ErrInvalidInput)
+	}
+}
+
+func TestClassifyVertexError(t *testing.T) {
+	client := &Client{}
+	tests := []struct {
+		name      string
+		err       genai.APIError
+		code      string
+		retryable bool
+	}{
+		{"quota", genai.APIError{Code: 429, Status: "RESOURCE_EXHAUSTED", Message: "slow down"}, ErrRateLimited, true},
+		{"permission", genai.APIError{Code: 403, Status: "PERMISSION_DENIED", Message: "no access"}, ErrPermissionDenied, false},
+		{"plain body", genai.APIError{Code: 503, Status: "503 Service Unavailable", Message: "busy"}, ErrServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gerr, ok := client.classifyVertexError(tt.err).(*GeminiError)
+			if !ok {
+				t.Fatalf("not a GeminiError")
+			}
+			if gerr.Code != tt.code || gerr.retryable != tt.retryable {
+				t.Fatalf("got %s retryable=%v, want %s retryable=%v", gerr.Code, gerr.retryable, tt.code, tt.retryable)
+			}
+		})
+	}
+}
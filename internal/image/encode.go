@@ -0,0 +1,121 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+// DefaultJPEGQuality is the JPEG quality used when none is given.
+const DefaultJPEGQuality = 90
+
+// FormatWebP is the WebP format, which imaging does not know. WebP output is
+// always lossless.
+const FormatWebP = "webp"
+
+// FormatForPath returns the format implied by path's extension: png, jpeg,
+// gif, tiff, bmp or webp. A path without an extension returns "", meaning the
+// source format is kept.
+func FormatForPath(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case "":
+		return "", nil
+	case ".webp":
+		return FormatWebP, nil
+	}
+	f, err := imaging.FormatFromExtension(ext)
+	if err != nil {
+		return "", fmt.Errorf("unsupported image format %q", ext)
+	}
+	return strings.ToLower(f.String()), nil
+}
+
+// DetectFormat returns the format of encoded image data, or "" if it is not
+// recognized.
+func DetectFormat(data []byte) string {
+	if _, name, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		return name
+	}
+	if len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP" {
+		return FormatWebP
+	}
+	return ""
+}
+
+// Convert re-encodes data as format and returns the encoded data and its
+// format. Data already in format is returned as is, unless a JPEG quality is
+// given; an empty format keeps the source. quality is the JPEG quality, 0 for
+// DefaultJPEGQuality.
+func Convert(data []byte, format string, quality int) ([]byte, string, error) {
+	source := DetectFormat(data)
+	if format == "" || (format == source && (format != "jpeg" || quality == 0)) {
+		return data, source, nil
+	}
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, img, format, quality); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), format, nil
+}
+
+// Save encodes img to path in the format implied by its extension, creating
+// parent directories as needed, and returns the format written.
+func Save(img image.Image, path string, quality int) (string, error) {
+	format, err := FormatForPath(path)
+	if err != nil {
+		return "", err
+	}
+	if format == "" {
+		return "", fmt.Errorf("cannot save %s: choose a .png, .jpg, .gif, .tiff, .bmp or .webp file", filepath.Base(path))
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, img, format, quality); err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(path)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+	return format, nil
+}
+
+// encode writes img as format. JPEG has no alpha channel, so transparent
+// images are flattened onto white first instead of turning black.
+func encode(w io.Writer, img image.Image, format string, quality int) error {
+	if format == FormatWebP {
+		return encodeWebP(w, img)
+	}
+	f, err := imaging.FormatFromExtension(format)
+	if err != nil {
+		return fmt.Errorf("unsupported image format %q", format)
+	}
+	if quality <= 0 {
+		quality = DefaultJPEGQuality
+	}
+	if f == imaging.JPEG && !isOpaque(img) {
+		b := img.Bounds()
+		img = imaging.Overlay(imaging.New(b.Dx(), b.Dy(), color.White), img, image.Pt(0, 0), 1)
+	}
+	if err := imaging.Encode(w, img, f, imaging.JPEGQuality(quality)); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", format, err)
+	}
+	return nil
+}
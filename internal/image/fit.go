@@ -0,0 +1,82 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// maxFitDimension is the longest side FitBytes starts from; the models
+	// gain nothing from larger reference images.
+	maxFitDimension = 3072
+	// minFitDimension is the longest side FitBytes gives up at.
+	minFitDimension = 64
+)
+
+// FitResult contains an image re-encoded by FitBytes
+type FitResult struct {
+	Data           []byte
+	MimeType       string
+	Width          int
+	Height         int
+	OriginalWidth  int
+	OriginalHeight int
+}
+
+// FitBytes decodes an encoded image and re-encodes it in at most maxBytes:
+// as JPEG, or as PNG when it has transparency, shrinking it until it fits.
+// EXIF orientation is applied, since it does not survive re-encoding.
+func FitBytes(data []byte, maxBytes int) (*FitResult, error) {
+	src, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	bounds := src.Bounds()
+	result := &FitResult{OriginalWidth: bounds.Dx(), OriginalHeight: bounds.Dy()}
+
+	format, mimeType := imaging.JPEG, "image/jpeg"
+	if !isOpaque(src) {
+		format, mimeType = imaging.PNG, "image/png"
+	}
+
+	longest := max(bounds.Dx(), bounds.Dy())
+	scale := min(1, float64(maxFitDimension)/float64(longest))
+	for {
+		img := src
+		if scale < 1 {
+			size := fmt.Sprintf("%dx%d", max(int(float64(bounds.Dx())*scale), 1), max(int(float64(bounds.Dy())*scale), 1))
+			if img, err = applyResize(src, size, "inside"); err != nil {
+				return nil, err
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, img, format, imaging.JPEGQuality(85)); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		if buf.Len() <= maxBytes {
+			result.Data = buf.Bytes()
+			result.MimeType = mimeType
+			result.Width = img.Bounds().Dx()
+			result.Height = img.Bounds().Dy()
+			return result, nil
+		}
+
+		if float64(longest)*scale <= minFitDimension {
+			return nil, fmt.Errorf("cannot fit a %dx%d image in %d bytes", bounds.Dx(), bounds.Dy(), maxBytes)
+		}
+		scale *= 0.75
+	}
+}
+
+// isOpaque reports whether img has no transparent pixels, when the image
+// type can tell.
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return true
+}
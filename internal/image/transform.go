@@ -19,6 +19,8 @@ type TransformOptions struct {
 	Rotate int    // degrees (-360 to 360)
 	Flip   bool   // vertical flip
 	Flop   bool   // horizontal flip (mirror)
+
+	Quality int // JPEG quality (0 for DefaultJPEGQuality)
 }
 
 // TransformResult contains information about the transformed image
@@ -79,15 +81,16 @@ func Transform(inputPath, outputPath string, opts *TransformOptions) (*Transform
 	}
 
 	// Save the result
-	if err := imaging.Save(result, outputPath); err != nil {
-		return nil, fmt.Errorf("failed to save image: %w", err)
+	format, err := Save(result, outputPath, opts.Quality)
+	if err != nil {
+		return nil, err
 	}
 
 	bounds := result.Bounds()
 	return &TransformResult{
 		Width:  bounds.Dx(),
 		Height: bounds.Dy(),
-		Format: strings.TrimPrefix(filepath.Ext(outputPath), "."),
+		Format: format,
 	}, nil
 }
 
@@ -0,0 +1,441 @@
+package image
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// WebP output is lossless (VP8L). The encoder applies the subtract-green
+// and gradient predictor transforms and codes every pixel as a literal with
+// per-channel prefix codes. Without backward references or a color cache its
+// files are larger than libwebp's, but they decode anywhere WebP does.
+
+const (
+	vp8lSignature = 0x2f
+	// vp8lMaxDimension is the largest width or height VP8L can describe.
+	vp8lMaxDimension = 1 << 14
+	// vp8lPredictorBits sizes the predictor blocks; one mode is used for the
+	// whole image, so the blocks are as large as the format allows.
+	vp8lPredictorBits = 9
+	// vp8lGradientMode is the ClampAddSubtractFull predictor, L + T - TL.
+	vp8lGradientMode = 12
+
+	vp8lTransformPredictor     = 0
+	vp8lTransformSubtractGreen = 2
+
+	// Prefix code alphabets: green includes the 24 length prefixes.
+	vp8lGreenAlphabet    = 256 + 24
+	vp8lLiteralAlphabet  = 256
+	vp8lDistanceAlphabet = 40
+
+	vp8lMaxCodeLength       = 15
+	vp8lMaxCodeLengthLength = 7
+)
+
+// vp8lCodeLengthOrder is the order code length code lengths are written in.
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// encodeWebP writes img as a lossless WebP file.
+func encodeWebP(w io.Writer, img image.Image) error {
+	src := imaging.Clone(img)
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	if width < 1 || height < 1 || width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return fmt.Errorf("failed to encode webp: %dx%d is outside the %d pixel limit", width, height, vp8lMaxDimension)
+	}
+
+	// ARGB pixels with green subtracted from red and blue.
+	pixels := make([]uint32, width*height)
+	alpha := false
+	for i := range pixels {
+		p := src.Pix[i*4 : i*4+4]
+		r, g, b, a := uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+		if a != 0xff {
+			alpha = true
+		}
+		pixels[i] = a<<24 | ((r-g)&0xff)<<16 | g<<8 | (b-g)&0xff
+	}
+	residuals := predictGradient(pixels, width, height)
+
+	bw := &bitWriter{}
+	bw.writeBits(vp8lSignature, 8)
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	bw.writeBit(alpha)
+	bw.writeBits(0, 3) // version
+
+	bw.writeBit(true)
+	bw.writeBits(vp8lTransformSubtractGreen, 2)
+	bw.writeBit(true)
+	bw.writeBits(vp8lTransformPredictor, 2)
+	bw.writeBits(vp8lPredictorBits-2, 3)
+	blocks := make([]uint32, subSampleSize(width)*subSampleSize(height))
+	for i := range blocks {
+		blocks[i] = 0xff000000 | vp8lGradientMode<<8
+	}
+	writeEntropyImage(bw, blocks)
+	bw.writeBit(false) // no more transforms
+
+	bw.writeBit(false) // no color cache
+	bw.writeBit(false) // no meta prefix codes
+	writeImageData(bw, residuals)
+
+	data := bw.bytes()
+	padded := len(data) + len(data)&1
+	var header [20]byte
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+padded))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if len(data)&1 == 1 {
+		data = append(data, 0)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func subSampleSize(size int) int {
+	return (size + 1<<vp8lPredictorBits - 1) >> vp8lPredictorBits
+}
+
+// predictGradient returns the residuals of the gradient predictor. The first
+// pixel is predicted as opaque black, the rest of the top row from the left
+// and the rest of the left column from the top, as VP8L requires.
+func predictGradient(pixels []uint32, width, height int) []uint32 {
+	out := make([]uint32, len(pixels))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			var pred uint32
+			switch {
+			case x == 0 && y == 0:
+				pred = 0xff000000
+			case y == 0:
+				pred = pixels[i-1]
+			case x == 0:
+				pred = pixels[i-width]
+			default:
+				pred = clampAddSubtractFull(pixels[i-1], pixels[i-width], pixels[i-width-1])
+			}
+			out[i] = subPixels(pixels[i], pred)
+		}
+	}
+	return out
+}
+
+func clampAddSubtractFull(l, t, tl uint32) uint32 {
+	var out uint32
+	for shift := 0; shift < 32; shift += 8 {
+		v := int(l>>shift&0xff) + int(t>>shift&0xff) - int(tl>>shift&0xff)
+		out |= uint32(min(max(v, 0), 255)) << shift
+	}
+	return out
+}
+
+// subPixels subtracts b from a per channel, modulo 256.
+func subPixels(a, b uint32) uint32 {
+	var out uint32
+	for shift := 0; shift < 32; shift += 8 {
+		out |= uint32(byte(a>>shift)-byte(b>>shift)) << shift
+	}
+	return out
+}
+
+// writeEntropyImage writes a sub-image, such as the predictor modes: no
+// color cache, then the prefix codes and pixels.
+func writeEntropyImage(bw *bitWriter, pixels []uint32) {
+	bw.writeBit(false)
+	writeImageData(bw, pixels)
+}
+
+// writeImageData writes the five prefix codes for pixels and then every pixel
+// as green, red, blue and alpha literals.
+func writeImageData(bw *bitWriter, pixels []uint32) {
+	green := make([]int, vp8lGreenAlphabet)
+	red := make([]int, vp8lLiteralAlphabet)
+	blue := make([]int, vp8lLiteralAlphabet)
+	alpha := make([]int, vp8lLiteralAlphabet)
+	for _, p := range pixels {
+		green[p>>8&0xff]++
+		red[p>>16&0xff]++
+		blue[p&0xff]++
+		alpha[p>>24]++
+	}
+	codes := [4]*prefixCode{newPrefixCode(green), newPrefixCode(red), newPrefixCode(blue), newPrefixCode(alpha)}
+	for _, code := range codes {
+		code.write(bw)
+	}
+	newPrefixCode(make([]int, vp8lDistanceAlphabet)).write(bw)
+
+	for _, p := range pixels {
+		codes[0].writeSymbol(bw, int(p>>8&0xff))
+		codes[1].writeSymbol(bw, int(p>>16&0xff))
+		codes[2].writeSymbol(bw, int(p&0xff))
+		codes[3].writeSymbol(bw, int(p>>24))
+	}
+}
+
+// prefixCode is a canonical Huffman code over an alphabet. Codes are stored
+// bit-reversed, ready to be written least significant bit first.
+type prefixCode struct {
+	lengths []int
+	codes   []uint32
+	used    []int // symbols with a non-zero count
+}
+
+func newPrefixCode(counts []int) *prefixCode {
+	c := &prefixCode{}
+	for s, n := range counts {
+		if n > 0 {
+			c.used = append(c.used, s)
+		}
+	}
+	switch len(c.used) {
+	case 0:
+		c.lengths = make([]int, len(counts))
+	case 1:
+		// A single symbol takes no bits.
+		c.lengths = make([]int, len(counts))
+	case 2:
+		c.lengths = make([]int, len(counts))
+		c.lengths[c.used[0]], c.lengths[c.used[1]] = 1, 1
+	default:
+		c.lengths = huffmanLengths(counts, vp8lMaxCodeLength)
+	}
+	c.codes = canonicalCodes(c.lengths)
+	return c
+}
+
+// write writes the code: as a simple code when it has at most two symbols
+// below 256, otherwise as code lengths coded with a code length code.
+func (c *prefixCode) write(bw *bitWriter) {
+	if len(c.used) <= 2 && (len(c.used) == 0 || c.used[len(c.used)-1] < 256) {
+		bw.writeBit(true)
+		symbols := c.used
+		if len(symbols) == 0 {
+			symbols = []int{0}
+		}
+		bw.writeBits(uint32(len(symbols)-1), 1)
+		bw.writeBit(true) // 8-bit first symbol
+		for _, s := range symbols {
+			bw.writeBits(uint32(s), 8)
+		}
+		return
+	}
+	bw.writeBit(false)
+
+	tokens := codeLengthTokens(c.lengths)
+	counts := make([]int, len(vp8lCodeLengthOrder))
+	for _, t := range tokens {
+		counts[t.symbol]++
+	}
+	// A code length code needs two symbols for its codes to take a bit.
+	if n := countUsed(counts); n < 2 {
+		for s := range counts {
+			if counts[s] == 0 {
+				counts[s] = 1
+				break
+			}
+		}
+	}
+	lengthCode := &prefixCode{lengths: huffmanLengths(counts, vp8lMaxCodeLengthLength)}
+	lengthCode.codes = canonicalCodes(lengthCode.lengths)
+
+	bw.writeBits(uint32(len(vp8lCodeLengthOrder)-4), 4)
+	for _, s := range vp8lCodeLengthOrder {
+		bw.writeBits(uint32(lengthCode.lengths[s]), 3)
+	}
+	bw.writeBit(false) // code lengths for the whole alphabet follow
+	for _, t := range tokens {
+		lengthCode.writeSymbol(bw, t.symbol)
+		if t.bits > 0 {
+			bw.writeBits(uint32(t.extra), t.bits)
+		}
+	}
+}
+
+func (c *prefixCode) writeSymbol(bw *bitWriter, symbol int) {
+	if n := c.lengths[symbol]; n > 0 {
+		bw.writeBits(c.codes[symbol], n)
+	}
+}
+
+func countUsed(counts []int) int {
+	n := 0
+	for _, c := range counts {
+		if c > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+type codeLengthToken struct {
+	symbol int
+	extra  int
+	bits   int
+}
+
+// codeLengthTokens run-length codes lengths: literal lengths 0-15, and 17 and
+// 18 for runs of 3-10 and 11-138 zeros.
+func codeLengthTokens(lengths []int) []codeLengthToken {
+	var tokens []codeLengthToken
+	for i := 0; i < len(lengths); {
+		if lengths[i] != 0 {
+			tokens = append(tokens, codeLengthToken{symbol: lengths[i]})
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(lengths) && lengths[i+run] == 0 {
+			run++
+		}
+		i += run
+		for run >= 11 {
+			n := min(run, 138)
+			tokens = append(tokens, codeLengthToken{symbol: 18, extra: n - 11, bits: 7})
+			run -= n
+		}
+		if run >= 3 {
+			tokens = append(tokens, codeLengthToken{symbol: 17, extra: run - 3, bits: 3})
+			run = 0
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, codeLengthToken{symbol: 0})
+		}
+	}
+	return tokens
+}
+
+// huffmanLengths returns Huffman code lengths for counts, no longer than
+// limit. Counts are halved until the tree fits.
+func huffmanLengths(counts []int, limit int) []int {
+	counts = append([]int(nil), counts...)
+	for {
+		lengths, longest := buildHuffman(counts)
+		if longest <= limit {
+			return lengths
+		}
+		for s, n := range counts {
+			if n > 0 {
+				counts[s] = (n + 1) / 2
+			}
+		}
+	}
+}
+
+func buildHuffman(counts []int) ([]int, int) {
+	type node struct {
+		weight int
+		parent int
+	}
+	var nodes []node
+	leaves := map[int]int{}
+	var active []int
+	for s, n := range counts {
+		if n > 0 {
+			leaves[s] = len(nodes)
+			active = append(active, len(nodes))
+			nodes = append(nodes, node{weight: n, parent: -1})
+		}
+	}
+	for len(active) > 1 {
+		sort.Slice(active, func(i, j int) bool {
+			a, b := nodes[active[i]], nodes[active[j]]
+			if a.weight != b.weight {
+				return a.weight < b.weight
+			}
+			return active[i] < active[j]
+		})
+		parent := len(nodes)
+		nodes = append(nodes, node{weight: nodes[active[0]].weight + nodes[active[1]].weight, parent: -1})
+		nodes[active[0]].parent = parent
+		nodes[active[1]].parent = parent
+		active = append(active[2:], parent)
+	}
+
+	lengths := make([]int, len(counts))
+	longest := 0
+	for s, leaf := range leaves {
+		depth := 0
+		for n := leaf; nodes[n].parent >= 0; n = nodes[n].parent {
+			depth++
+		}
+		lengths[s] = depth
+		longest = max(longest, depth)
+	}
+	return lengths, longest
+}
+
+// canonicalCodes assigns canonical codes to lengths, shorter codes and then
+// lower symbols first, and returns them bit-reversed.
+func canonicalCodes(lengths []int) []uint32 {
+	var count [vp8lMaxCodeLength + 1]int
+	for _, n := range lengths {
+		count[n]++
+	}
+	count[0] = 0
+	var next [vp8lMaxCodeLength + 1]uint32
+	code := uint32(0)
+	for n := 1; n <= vp8lMaxCodeLength; n++ {
+		code = (code + uint32(count[n-1])) << 1
+		next[n] = code
+	}
+	codes := make([]uint32, len(lengths))
+	for s, n := range lengths {
+		if n == 0 {
+			continue
+		}
+		codes[s] = reverseBits(next[n], n)
+		next[n]++
+	}
+	return codes
+}
+
+func reverseBits(code uint32, n int) uint32 {
+	var out uint32
+	for i := 0; i < n; i++ {
+		out = out<<1 | code&1
+		code >>= 1
+	}
+	return out
+}
+
+// bitWriter packs bits least significant bit first, as VP8L reads them.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits int
+}
+
+func (bw *bitWriter) writeBits(v uint32, n int) {
+	bw.acc |= uint64(v&(1<<n-1)) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+func (bw *bitWriter) writeBit(b bool) {
+	if b {
+		bw.writeBits(1, 1)
+	} else {
+		bw.writeBits(0, 1)
+	}
+}
+
+func (bw *bitWriter) bytes() []byte {
+	if bw.nbits > 0 {
+		return append(bw.buf, byte(bw.acc))
+	}
+	return bw.buf
+}
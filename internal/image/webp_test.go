@@ -0,0 +1,82 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	fill := func(w, h int, pixel func(x, y int) color.NRGBA) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.SetNRGBA(x, y, pixel(x, y))
+			}
+		}
+		return img
+	}
+	random := func(x, y int) color.NRGBA {
+		return color.NRGBA{uint8(rng.Uint32()), uint8(rng.Uint32()), uint8(rng.Uint32()), uint8(rng.Uint32())}
+	}
+	gradient := func(x, y int) color.NRGBA {
+		return color.NRGBA{uint8(x * 3), uint8(y * 5), uint8(x + y), 255}
+	}
+	skewed := func(x, y int) color.NRGBA {
+		if rng.IntN(50) == 0 {
+			return color.NRGBA{200, 10, 10, 128}
+		}
+		return color.NRGBA{12, 34, 56, 255}
+	}
+
+	tests := []struct {
+		name string
+		img  *image.NRGBA
+	}{
+		{"single pixel", fill(1, 1, random)},
+		{"random", fill(37, 23, random)},
+		{"random wider than a predictor block", fill(515, 3, random)},
+		{"gradient", fill(101, 67, gradient)},
+		{"single color", fill(63, 65, func(x, y int) color.NRGBA { return color.NRGBA{90, 160, 30, 255} })},
+		{"transparent", fill(9, 7, func(x, y int) color.NRGBA { return color.NRGBA{} })},
+		{"two colors", fill(40, 41, skewed)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeWebP(&buf, tc.img); err != nil {
+				t.Fatalf("encodeWebP: %v", err)
+			}
+			decoded, err := webp.Decode(&buf)
+			if err != nil {
+				t.Fatalf("webp.Decode: %v", err)
+			}
+			got, ok := decoded.(*image.NRGBA)
+			if !ok {
+				t.Fatalf("decoded %T, want *image.NRGBA", decoded)
+			}
+			if got.Bounds() != tc.img.Bounds() {
+				t.Fatalf("bounds = %v, want %v", got.Bounds(), tc.img.Bounds())
+			}
+			for y := 0; y < tc.img.Bounds().Dy(); y++ {
+				for x := 0; x < tc.img.Bounds().Dx(); x++ {
+					if g, w := got.NRGBAAt(x, y), tc.img.NRGBAAt(x, y); g != w {
+						t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, g, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeWebPRejectsOversizedImages(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, vp8lMaxDimension+1, 1))
+	if err := encodeWebP(&bytes.Buffer{}, img); err == nil {
+		t.Fatal("encodeWebP accepted an image wider than VP8L allows")
+	}
+}
@@ -30,6 +30,8 @@ type ErrorInfo struct {
 type Timing struct {
 	APICallMs int64 `json:"api_call_ms,omitempty"`
 	TotalMs   int64 `json:"total_ms,omitempty"`
+	Attempts  int   `json:"attempts,omitempty"`
+	Retries   int   `json:"retries,omitempty"`
 }
 
 // ImageResult represents a generated or processed image
@@ -50,6 +52,9 @@ type Formatter struct {
 	JSONMode bool
 	Quiet    bool
 	NoColor  bool
+	// Stream writes JSON output one object per line, so progress events and
+	// the final response form an NDJSON stream.
+	Stream bool
 }
 
 // NewFormatter creates a new output formatter
@@ -86,6 +91,11 @@ func (f *Formatter) Success(command string, data interface{}, timing *Timing) {
 
 // Error outputs an error response
 func (f *Formatter) Error(command string, code string, message string, hint string) {
+	f.ErrorWithDetails(command, code, message, hint, nil)
+}
+
+// ErrorWithDetails outputs an error response with structured details
+func (f *Formatter) ErrorWithDetails(command string, code string, message string, hint string, details map[string]string) {
 	if f.JSONMode {
 		resp := Response{
 			Success: false,
@@ -93,6 +103,7 @@ func (f *Formatter) Error(command string, code string, message string, hint stri
 			Error: &ErrorInfo{
 				Code:    code,
 				Message: message,
+				Details: details,
 				Hint:    hint,
 			},
 		}
@@ -127,6 +138,24 @@ func (f *Formatter) Progress(format string, args ...interface{}) {
 	fmt.Fprintf(os.Stdout, "%s %s\n", cyan("→"), fmt.Sprintf(format, args...))
 }
 
+// Event outputs a streaming progress event. In JSON mode it is a single-line
+// object with an "event" field; in text mode message is shown dimmed.
+func (f *Formatter) Event(name string, fields map[string]interface{}, message string) {
+	if f.JSONMode {
+		event := map[string]interface{}{"event": name}
+		for k, v := range fields {
+			event[k] = v
+		}
+		json.NewEncoder(os.Stdout).Encode(event)
+		return
+	}
+	if f.Quiet || message == "" {
+		return
+	}
+	dim := color.New(color.Faint).SprintFunc()
+	fmt.Fprintf(os.Stdout, "%s %s\n", dim("…"), dim(message))
+}
+
 // ImageSaved outputs a message about a saved image
 func (f *Formatter) ImageSaved(path string, width, height int) {
 	if f.JSONMode || f.Quiet {
@@ -139,7 +168,9 @@ func (f *Formatter) ImageSaved(path string, width, height int) {
 
 func (f *Formatter) outputJSON(v interface{}) {
 	encoder := json.NewEncoder(os.Stdout)
-	encoder.SetIndent("", "  ")
+	if !f.Stream {
+		encoder.SetIndent("", "  ")
+	}
 	encoder.Encode(v)
 }
 
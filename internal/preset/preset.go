@@ -0,0 +1,180 @@
+// Package preset stores named generation presets: a system instruction, model,
+// aspect ratio, image size and reference images that generate, icon and
+// pattern can apply with --preset.
+package preset
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	appconfig "github.com/lyalindotcom/nano-banana-cli/internal/config"
+	"go.yaml.in/yaml/v3"
+)
+
+const presetFile = "preset.yaml"
+
+// Preset is one saved preset. Empty fields leave the command's own default in
+// place. Reference images are copied into the preset directory so a preset
+// keeps working when the originals move.
+type Preset struct {
+	Name        string   `yaml:"name" json:"name"`
+	System      string   `yaml:"system,omitempty" json:"system,omitempty"`
+	Model       string   `yaml:"model,omitempty" json:"model,omitempty"`
+	AspectRatio string   `yaml:"aspect_ratio,omitempty" json:"aspect_ratio,omitempty"`
+	ImageSize   string   `yaml:"image_size,omitempty" json:"image_size,omitempty"`
+	References  []string `yaml:"references,omitempty" json:"references,omitempty"`
+
+	dir string
+}
+
+// Dir returns the directory holding all presets.
+func Dir() (string, error) {
+	return appconfig.StateDir("presets")
+}
+
+// New creates an unsaved, empty preset.
+func New(name string) (*Preset, error) {
+	if err := appconfig.ValidateName("preset", name); err != nil {
+		return nil, err
+	}
+	root, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return &Preset{Name: name, dir: filepath.Join(root, name)}, nil
+}
+
+// Load reads a saved preset. A missing preset returns an error matching
+// os.ErrNotExist.
+func Load(name string) (*Preset, error) {
+	p, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(p.dir, presetFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("preset %s not found: %w", name, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to read preset: %w", err)
+	}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse preset %s: %w", name, err)
+	}
+	p.Name = name
+	return p, nil
+}
+
+// List returns every saved preset, sorted by name. Presets that cannot be
+// read are skipped.
+func List() ([]*Preset, error) {
+	root, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list presets: %w", err)
+	}
+	var presets []*Preset
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if p, err := Load(entry.Name()); err == nil {
+			presets = append(presets, p)
+		}
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].Name < presets[j].Name })
+	return presets, nil
+}
+
+// Exists reports whether a preset with this name has been saved.
+func Exists(name string) bool {
+	p, err := New(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(p.dir, presetFile))
+	return err == nil
+}
+
+// Delete removes a saved preset and its reference images.
+func Delete(name string) error {
+	p, err := New(name)
+	if err != nil {
+		return err
+	}
+	if !Exists(name) {
+		return fmt.Errorf("preset %s not found: %w", name, os.ErrNotExist)
+	}
+	if err := os.RemoveAll(p.dir); err != nil {
+		return fmt.Errorf("failed to delete preset: %w", err)
+	}
+	return nil
+}
+
+// Save replaces any preset of the same name with p, copying refs into the
+// preset directory as its reference images.
+func (p *Preset) Save(refs []string) error {
+	// Preset names cannot start with '.', so the staging directory never
+	// collides with another preset.
+	root := filepath.Dir(p.dir)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to create preset directory: %w", err)
+	}
+	staging, err := os.MkdirTemp(root, "."+p.Name+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create preset directory: %w", err)
+	}
+	os.Chmod(staging, 0755)
+
+	p.References = nil
+	for i, ref := range refs {
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			os.RemoveAll(staging)
+			return fmt.Errorf("failed to read reference image: %w", err)
+		}
+		name := fmt.Sprintf("ref_%d%s", i+1, strings.ToLower(filepath.Ext(ref)))
+		if err := os.WriteFile(filepath.Join(staging, name), data, 0644); err != nil {
+			os.RemoveAll(staging)
+			return fmt.Errorf("failed to copy reference image: %w", err)
+		}
+		p.References = append(p.References, name)
+	}
+
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		os.RemoveAll(staging)
+		return fmt.Errorf("failed to marshal preset: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, presetFile), data, 0644); err != nil {
+		os.RemoveAll(staging)
+		return fmt.Errorf("failed to write preset: %w", err)
+	}
+
+	if err := os.RemoveAll(p.dir); err != nil {
+		return fmt.Errorf("failed to replace preset: %w", err)
+	}
+	if err := os.Rename(staging, p.dir); err != nil {
+		return fmt.Errorf("failed to save preset: %w", err)
+	}
+	return nil
+}
+
+// ReferencePaths returns the paths of the preset's reference images.
+func (p *Preset) ReferencePaths() []string {
+	paths := make([]string, 0, len(p.References))
+	for _, ref := range p.References {
+		paths = append(paths, filepath.Join(p.dir, ref))
+	}
+	return paths
+}
@@ -0,0 +1,87 @@
+package preset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveLoadDelete(t *testing.T) {
+	t.Setenv("NANOBANANA_CONFIG_DIR", t.TempDir())
+
+	if _, err := Load("brand-hero"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load missing = %v", err)
+	}
+
+	ref := filepath.Join(t.TempDir(), "Logo.PNG")
+	os.WriteFile(ref, []byte("logo"), 0644)
+
+	p, err := New("brand-hero")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	p.System = "Use the brand palette."
+	p.Model = "pro"
+	p.AspectRatio = "16:9"
+	if err := p.Save([]string{ref}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	os.Remove(ref)
+
+	loaded, err := Load("brand-hero")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.System != p.System || loaded.Model != "pro" || loaded.AspectRatio != "16:9" || len(loaded.References) != 1 {
+		t.Fatalf("loaded = %+v", loaded)
+	}
+	if data, err := os.ReadFile(loaded.ReferencePaths()[0]); err != nil || string(data) != "logo" || filepath.Base(loaded.ReferencePaths()[0]) != "ref_1.png" {
+		t.Fatalf("reference = %q, %v", data, err)
+	}
+
+	loaded.AspectRatio = ""
+	if err := loaded.Save(nil); err != nil {
+		t.Fatalf("Save over existing: %v", err)
+	}
+	if again, _ := Load("brand-hero"); again.AspectRatio != "" || len(again.References) != 0 {
+		t.Fatalf("overwritten = %+v", again)
+	}
+
+	if presets, err := List(); err != nil || len(presets) != 1 || presets[0].Name != "brand-hero" {
+		t.Fatalf("List = %v, %v", presets, err)
+	}
+	if err := Delete("brand-hero"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := Delete("brand-hero"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Delete missing = %v", err)
+	}
+	if _, err := New("../escape"); err == nil {
+		t.Fatal("expected an invalid name error")
+	}
+}
+
+func TestSaveKeepsPresetsNamedLikeStaging(t *testing.T) {
+	t.Setenv("NANOBANANA_CONFIG_DIR", t.TempDir())
+
+	tmp, err := New("brand.tmp")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	tmp.System = "kept"
+	if err := tmp.Save(nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	brand, _ := New("brand")
+	if err := brand.Save(nil); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if loaded, err := Load("brand.tmp"); err != nil || loaded.System != "kept" {
+		t.Fatalf("brand.tmp after saving brand = %+v, %v", loaded, err)
+	}
+	if presets, err := List(); err != nil || len(presets) != 2 {
+		t.Fatalf("List = %v, %v", presets, err)
+	}
+}
@@ -0,0 +1,279 @@
+package provenance
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	xmpHeader         = "http://ns.adobe.com/xap/1.0/\x00"
+	extendedXMPHeader = "http://ns.adobe.com/xmp/extension/\x00"
+	xmpNamespace      = "https://github.com/lyalindotcom/nano-banana-cli/ns/1.0/"
+	// maxSegment is the largest APP1 payload, after the length bytes.
+	maxSegment = 0xFFFF - 2
+	// maxExtendedChunk is the part of an extended XMP packet carried by one
+	// segment, as recommended by the XMP specification.
+	maxExtendedChunk = 65400
+)
+
+type jpegSegment struct {
+	marker byte
+	data   []byte // payload after the length bytes
+	raw    []byte
+}
+
+// jpegHeaderSegments returns the segments between SOI and the first one that
+// is not an APPn segment, and the offset where the rest of the file starts.
+func jpegHeaderSegments(data []byte) ([]jpegSegment, int, error) {
+	var segments []jpegSegment
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF && data[i+1] >= 0xE0 && data[i+1] <= 0xEF {
+		n := be16(data[i+2:])
+		if n < 2 || i+2+n > len(data) {
+			return nil, 0, fmt.Errorf("truncated JPEG segment")
+		}
+		segments = append(segments, jpegSegment{marker: data[i+1], data: data[i+4 : i+2+n], raw: data[i : i+2+n]})
+		i += 2 + n
+	}
+	return segments, i, nil
+}
+
+func isXMP(s jpegSegment) bool {
+	return s.marker == 0xE1 && bytes.HasPrefix(s.data, []byte(xmpHeader))
+}
+
+func isExtendedXMP(s jpegSegment) bool {
+	return s.marker == 0xE1 && bytes.HasPrefix(s.data, []byte(extendedXMPHeader))
+}
+
+// embedJPEG writes rec as an XMP packet in an APP1 segment, after any JFIF and
+// Exif segments, replacing an earlier XMP packet. A record too large for one
+// segment moves to extended XMP, split across further APP1 segments.
+func embedJPEG(data []byte, rec *Record, recJSON []byte) ([]byte, error) {
+	segments, rest, err := jpegHeaderSegments(data)
+	if err != nil {
+		return nil, err
+	}
+	ours, err := xmpSegments(rec, recJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:2])
+	written := false
+	for _, s := range segments {
+		if isXMP(s) || isExtendedXMP(s) {
+			continue
+		}
+		if !written && s.marker != 0xE0 && s.marker != 0xE1 {
+			buf.Write(ours)
+			written = true
+		}
+		buf.Write(s.raw)
+	}
+	if !written {
+		buf.Write(ours)
+	}
+	buf.Write(data[rest:])
+	return buf.Bytes(), nil
+}
+
+// xmpSegments renders the APP1 segments carrying rec. When the main packet
+// would not fit, the record is written as extended XMP, identified by the
+// MD5 of its packet, and the main packet only points to it; a prompt too long
+// for the main packet is then left to the record.
+func xmpSegments(rec *Record, recJSON []byte) ([]byte, error) {
+	main := xmpPacket(rec, recJSON, "", true)
+	if len(xmpHeader)+len(main) <= maxSegment {
+		return app1(xmpHeader, main), nil
+	}
+
+	extended := extendedXMPPacket(recJSON)
+	sum := md5.Sum(extended)
+	guid := strings.ToUpper(hex.EncodeToString(sum[:]))
+	main = xmpPacket(rec, nil, guid, true)
+	if len(xmpHeader)+len(main) > maxSegment {
+		main = xmpPacket(rec, nil, guid, false)
+	}
+	if len(xmpHeader)+len(main) > maxSegment {
+		return nil, fmt.Errorf("provenance is too large for a JPEG XMP segment (%d bytes)", len(main))
+	}
+
+	var out bytes.Buffer
+	out.Write(app1(xmpHeader, main))
+	for offset := 0; offset < len(extended); offset += maxExtendedChunk {
+		end := min(offset+maxExtendedChunk, len(extended))
+		var header [8]byte
+		binary.BigEndian.PutUint32(header[:4], uint32(len(extended)))
+		binary.BigEndian.PutUint32(header[4:], uint32(offset))
+		out.Write(app1(extendedXMPHeader+guid+string(header[:]), extended[offset:end]))
+	}
+	return out.Bytes(), nil
+}
+
+func app1(header string, payload []byte) []byte {
+	n := len(header) + len(payload) + 2
+	segment := append([]byte{0xFF, 0xE1, byte(n >> 8), byte(n)}, header...)
+	return append(segment, payload...)
+}
+
+// xmpPacket renders the main XMP packet. The record is included when recJSON
+// is set; otherwise guid names the extended XMP holding it.
+func xmpPacket(rec *Record, recJSON []byte, guid string, description bool) []byte {
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(` <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	b.WriteString(`  <rdf:Description rdf:about=""` + "\n")
+	b.WriteString(`    xmlns:xmp="http://ns.adobe.com/xap/1.0/"` + "\n")
+	b.WriteString(`    xmlns:dc="http://purl.org/dc/elements/1.1/"` + "\n")
+	fmt.Fprintf(&b, "    xmlns:%s=\"%s\"\n", Key, xmpNamespace)
+	if guid != "" {
+		b.WriteString(`    xmlns:xmpNote="http://ns.adobe.com/xmp/note/"` + "\n")
+		fmt.Fprintf(&b, "    xmpNote:HasExtendedXMP=\"%s\"\n", guid)
+	}
+	fmt.Fprintf(&b, "    xmp:CreatorTool=\"%s\"\n", escape(rec.Tool))
+	fmt.Fprintf(&b, "    xmp:CreateDate=\"%s\">\n", rec.CreatedAt.Format(time.RFC3339))
+	if description {
+		fmt.Fprintf(&b, "   <dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", escape(rec.Prompt))
+	}
+	if recJSON != nil {
+		fmt.Fprintf(&b, "   <%s:record>%s</%s:record>\n", Key, escape(string(recJSON)), Key)
+	}
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n")
+	b.WriteString(`<?xpacket end="w"?>`)
+	return []byte(b.String())
+}
+
+// extendedXMPPacket renders the extended XMP holding the record. It has no
+// xpacket wrapper, as the specification requires.
+func extendedXMPPacket(recJSON []byte) []byte {
+	var b strings.Builder
+	b.WriteString(`<x:xmpmeta xmlns:x="adobe:ns:meta/">` + "\n")
+	b.WriteString(` <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` + "\n")
+	fmt.Fprintf(&b, "  <rdf:Description rdf:about=\"\" xmlns:%s=\"%s\">\n", Key, xmpNamespace)
+	fmt.Fprintf(&b, "   <%s:record>%s</%s:record>\n", Key, escape(string(recJSON)), Key)
+	b.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n")
+	return []byte(b.String())
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func readJPEG(data []byte) (*Metadata, error) {
+	segments, _, err := jpegHeaderSegments(data)
+	if err != nil {
+		return nil, err
+	}
+	meta := &Metadata{Format: "jpeg", Text: map[string]string{}}
+	for _, s := range segments {
+		if isXMP(s) {
+			if err := readXMP(s.data[len(xmpHeader):], meta.Text); err != nil {
+				return nil, fmt.Errorf("invalid XMP packet: %w", err)
+			}
+		}
+	}
+	if guid, ok := meta.Text["HasExtendedXMP"]; ok {
+		delete(meta.Text, "HasExtendedXMP")
+		extended, err := extendedXMP(segments, guid)
+		if err != nil {
+			return nil, err
+		}
+		if err := readXMP(extended, meta.Text); err != nil {
+			return nil, fmt.Errorf("invalid extended XMP packet: %w", err)
+		}
+	}
+	return meta, nil
+}
+
+// extendedXMP reassembles the extended XMP packet with the given GUID.
+func extendedXMP(segments []jpegSegment, guid string) ([]byte, error) {
+	var packet []byte
+	received := 0
+	for _, s := range segments {
+		if !isExtendedXMP(s) {
+			continue
+		}
+		body := s.data[len(extendedXMPHeader):]
+		if len(body) < 40 || string(body[:32]) != guid {
+			continue
+		}
+		total := int(binary.BigEndian.Uint32(body[32:]))
+		offset := int(binary.BigEndian.Uint32(body[36:]))
+		chunk := body[40:]
+		if packet == nil {
+			packet = make([]byte, total)
+		}
+		if total != len(packet) || offset+len(chunk) > total {
+			return nil, fmt.Errorf("invalid extended XMP segment")
+		}
+		received += copy(packet[offset:], chunk)
+	}
+	if packet == nil || received != len(packet) {
+		return nil, fmt.Errorf("extended XMP %s is incomplete", guid)
+	}
+	return packet, nil
+}
+
+// readXMP collects the simple properties of an XMP packet: attributes of
+// rdf:Description and elements holding only text, keyed by local name. The
+// record property is stored under Key.
+func readXMP(packet []byte, text map[string]string) error {
+	const rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	dec := xml.NewDecoder(bytes.NewReader(packet))
+	var stack []xml.Name
+	var chars strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == rdfNamespace && t.Name.Local == "Description" {
+				for _, attr := range t.Attr {
+					if attr.Name.Space != "xmlns" && attr.Name.Space != rdfNamespace && attr.Name.Local != "xmlns" {
+						text[attr.Name.Local] = attr.Value
+					}
+				}
+			}
+			stack = append(stack, t.Name)
+			chars.Reset()
+		case xml.CharData:
+			chars.Write(t)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			value := strings.TrimSpace(chars.String())
+			chars.Reset()
+			if value == "" {
+				continue
+			}
+			// Language alternatives and lists are named after their property.
+			name := t.Name
+			for i := len(stack) - 1; i >= 0 && name.Space == rdfNamespace; i-- {
+				name = stack[i]
+			}
+			switch {
+			case name.Space == xmpNamespace && name.Local == "record":
+				text[Key] = value
+			case name.Space != rdfNamespace:
+				text[name.Local] = value
+			}
+		}
+	}
+}
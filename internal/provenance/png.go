@@ -0,0 +1,148 @@
+package provenance
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// Text keywords written besides Key, from the PNG specification's
+// predefined set.
+const (
+	pngSoftware     = "Software"
+	pngDescription  = "Description"
+	pngCreationTime = "Creation Time"
+)
+
+type pngChunk struct {
+	typ  string
+	data []byte
+	raw  []byte // the whole chunk, including length and CRC
+}
+
+func pngChunks(data []byte) ([]pngChunk, error) {
+	var chunks []pngChunk
+	for rest := data[len(pngSignature):]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+		n := binary.BigEndian.Uint32(rest)
+		if uint64(n)+12 > uint64(len(rest)) {
+			return nil, fmt.Errorf("truncated PNG chunk")
+		}
+		end := int(n) + 12
+		chunks = append(chunks, pngChunk{typ: string(rest[4:8]), data: rest[8 : 8+n], raw: rest[:end]})
+		rest = rest[end:]
+	}
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" {
+		return nil, fmt.Errorf("PNG does not start with IHDR")
+	}
+	return chunks, nil
+}
+
+// embedPNG writes rec as text chunks right after IHDR, dropping earlier
+// chunks with the same keywords.
+func embedPNG(data []byte, rec *Record, recJSON []byte) ([]byte, error) {
+	chunks, err := pngChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	ours := map[string]bool{Key: true, pngSoftware: true, pngDescription: true, pngCreationTime: true}
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	buf.Write(chunks[0].raw)
+	writePNGChunk(&buf, "tEXt", []byte(pngSoftware+"\x00"+rec.Tool))
+	writePNGChunk(&buf, "tEXt", []byte(pngCreationTime+"\x00"+rec.CreatedAt.UTC().Format(time.RFC1123Z)))
+	writePNGChunk(&buf, "iTXt", itxt(pngDescription, rec.Prompt))
+	writePNGChunk(&buf, "iTXt", itxt(Key, string(recJSON)))
+	for _, c := range chunks[1:] {
+		if c.typ == "tEXt" || c.typ == "iTXt" || c.typ == "zTXt" {
+			if keyword, _, _ := bytes.Cut(c.data, []byte{0}); ours[string(keyword)] {
+				continue
+			}
+		}
+		buf.Write(c.raw)
+	}
+	return buf.Bytes(), nil
+}
+
+// itxt builds an uncompressed iTXt chunk, which unlike tEXt holds UTF-8.
+func itxt(keyword, text string) []byte {
+	return []byte(keyword + "\x00\x00\x00\x00\x00" + text)
+}
+
+func writePNGChunk(w io.Writer, typ string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	w.Write(header[:])
+	w.Write(data)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+func readPNG(data []byte) (*Metadata, error) {
+	chunks, err := pngChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	meta := &Metadata{Format: "png", Text: map[string]string{}}
+	for _, c := range chunks {
+		keyword, text, ok := bytes.Cut(c.data, []byte{0})
+		if !ok {
+			continue
+		}
+		switch c.typ {
+		case "tEXt":
+			meta.Text[string(keyword)] = latin1(text)
+		case "zTXt":
+			if len(text) > 0 {
+				if s, err := inflate(text[1:]); err == nil {
+					meta.Text[string(keyword)] = latin1(s)
+				}
+			}
+		case "iTXt":
+			if len(text) < 2 {
+				continue
+			}
+			compressed := text[0] == 1
+			_, rest, _ := bytes.Cut(text[2:], []byte{0}) // language tag
+			_, rest, _ = bytes.Cut(rest, []byte{0})      // translated keyword
+			if compressed {
+				if rest, err = inflate(rest); err != nil {
+					continue
+				}
+			}
+			meta.Text[string(keyword)] = string(rest)
+		}
+	}
+	return meta, nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// latin1 decodes tEXt and zTXt, which the PNG specification keeps to
+// ISO 8859-1.
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
@@ -0,0 +1,134 @@
+// Package provenance embeds a record of how an image was generated (prompt,
+// model, options, time and input hashes) into PNG text chunks and JPEG XMP
+// packets, and reads it back.
+package provenance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Key is the PNG text keyword that holds the JSON record, and the prefix of
+// its XMP namespace.
+const Key = "nanobanana"
+
+// ErrUnsupportedFormat is returned for images that are neither PNG nor JPEG.
+var ErrUnsupportedFormat = errors.New("provenance can only be embedded in PNG and JPEG files")
+
+// ErrNotFound is returned by Read when an image carries no provenance record.
+var ErrNotFound = errors.New("no nanobanana provenance found")
+
+// Record describes how an image was generated.
+type Record struct {
+	// Tool is the generating program and its version.
+	Tool    string `json:"tool"`
+	Command string `json:"command"`
+	Prompt  string `json:"prompt"`
+	Model   string `json:"model"`
+	// Options are the command's generation settings, such as aspect_ratio,
+	// image_size and sampling.
+	Options   map[string]any `json:"options,omitempty"`
+	Inputs    []Input        `json:"inputs,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Input identifies a reference image by name and content hash.
+type Input struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// Metadata is what Read finds in an image.
+type Metadata struct {
+	// Format is png or jpeg.
+	Format string `json:"format"`
+	// Text holds the PNG text chunks or the simple XMP properties, including
+	// those written by other tools.
+	Text   map[string]string `json:"text,omitempty"`
+	Record *Record           `json:"record,omitempty"`
+}
+
+// Embed returns data with rec written into it, replacing any earlier record:
+// PNGs get tEXt and iTXt chunks, JPEGs an XMP packet.
+func Embed(data []byte, rec *Record) ([]byte, error) {
+	recJSON, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal provenance: %w", err)
+	}
+	switch {
+	case isPNG(data):
+		return embedPNG(data, rec, recJSON)
+	case isJPEG(data):
+		return embedJPEG(data, rec, recJSON)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// Read extracts the metadata of a PNG or JPEG image. It returns ErrNotFound,
+// along with the metadata it did find, when there is no provenance record.
+func Read(data []byte) (*Metadata, error) {
+	var meta *Metadata
+	var err error
+	switch {
+	case isPNG(data):
+		meta, err = readPNG(data)
+	case isJPEG(data):
+		meta, err = readJPEG(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := meta.Text[Key]
+	if !ok {
+		return meta, ErrNotFound
+	}
+	delete(meta.Text, Key)
+	var rec Record
+	if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+		return nil, fmt.Errorf("invalid provenance record: %w", err)
+	}
+	meta.Record = &rec
+	return meta, nil
+}
+
+// WriteFile embeds rec into the image at path.
+func WriteFile(path string, rec *Record) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err = Embed(data, rec)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ReadFile reads the metadata of the image at path, as Read.
+func ReadFile(path string) (*Metadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Read(data)
+}
+
+func isPNG(data []byte) bool {
+	return bytes.HasPrefix(data, pngSignature)
+}
+
+func isJPEG(data []byte) bool {
+	return len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF
+}
+
+func be16(b []byte) int {
+	return int(binary.BigEndian.Uint16(b))
+}
@@ -0,0 +1,114 @@
+package provenance
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encoded(t *testing.T, encode func(*bytes.Buffer, image.Image) error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encode(&buf, image.NewNRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestEmbedRead(t *testing.T) {
+	rec := &Record{
+		Tool:      "nanobanana test",
+		Command:   "generate",
+		Prompt:    "a café at dusk, <neon> & \"rain\"",
+		Model:     "gemini-3.1-flash-image-preview",
+		Options:   map[string]any{"aspect_ratio": "16:9"},
+		Inputs:    []Input{{Name: "ref.png", SHA256: "abc123"}},
+		CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	formats := map[string][]byte{
+		"png":  encoded(t, func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) }),
+		"jpeg": encoded(t, func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) }),
+	}
+	for format, data := range formats {
+		if _, err := Read(data); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: Read without provenance = %v", format, err)
+		}
+
+		first, err := Embed(data, &Record{Tool: "old", Prompt: "superseded prompt"})
+		if err != nil {
+			t.Fatalf("%s: Embed: %v", format, err)
+		}
+		out, err := Embed(first, rec)
+		if err != nil {
+			t.Fatalf("%s: Embed: %v", format, err)
+		}
+		if _, name, err := image.Decode(bytes.NewReader(out)); err != nil || name != format {
+			t.Fatalf("%s: embedded image no longer decodes: %q, %v", format, name, err)
+		}
+
+		meta, err := Read(out)
+		if err != nil {
+			t.Fatalf("%s: Read: %v", format, err)
+		}
+		got := meta.Record
+		if meta.Format != format || got.Prompt != rec.Prompt || got.Model != rec.Model || !got.CreatedAt.Equal(rec.CreatedAt) ||
+			got.Options["aspect_ratio"] != "16:9" || len(got.Inputs) != 1 || got.Inputs[0].SHA256 != "abc123" {
+			t.Errorf("%s: record = %+v", format, got)
+		}
+		if meta.Text["Description"]+meta.Text["description"] != rec.Prompt {
+			t.Errorf("%s: text = %v", format, meta.Text)
+		}
+		if bytes.Contains(out, []byte("superseded prompt")) {
+			t.Errorf("%s: the earlier record was not replaced", format)
+		}
+	}
+
+	gifData := encoded(t, func(b *bytes.Buffer, img image.Image) error { return gif.Encode(b, img, nil) })
+	if _, err := Embed(gifData, rec); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Embed(gif) = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestEmbedLargeJPEGRecord(t *testing.T) {
+	data := encoded(t, func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) })
+	rec := &Record{
+		Tool:    "nanobanana test",
+		Prompt:  strings.Repeat("a very long prompt ", 4000),
+		Options: map[string]any{"system_instruction": strings.Repeat("brand <rules> & ", 10000)},
+	}
+	out, err := Embed(data, rec)
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Fatalf("embedded image no longer decodes: %v", err)
+	}
+	if !bytes.Contains(out, []byte(extendedXMPHeader)) {
+		t.Fatal("a record over 64 KB should use extended XMP")
+	}
+	meta, err := Read(out)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if meta.Record.Prompt != rec.Prompt || meta.Record.Options["system_instruction"] != rec.Options["system_instruction"] {
+		t.Errorf("record was not read back intact")
+	}
+	if _, ok := meta.Text["HasExtendedXMP"]; ok {
+		t.Errorf("text = %v", meta.Text)
+	}
+
+	// A smaller record replaces every extended segment.
+	out, err = Embed(out, &Record{Tool: "nanobanana test", Prompt: "short"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if meta, err := Read(out); err != nil || meta.Record.Prompt != "short" || bytes.Contains(out, []byte(extendedXMPHeader)) {
+		t.Errorf("re-embedded record = %+v, %v", meta, err)
+	}
+}
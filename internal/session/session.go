@@ -0,0 +1,198 @@
+// Package session persists interactive editing sessions: their settings, the
+// turns taken so far and the conversation history they continue from.
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	appconfig "github.com/lyalindotcom/nano-banana-cli/internal/config"
+)
+
+const (
+	stateFile   = "session.json"
+	historyFile = "history.json"
+)
+
+// Session is the saved state of one session. The conversation itself lives in
+// HistoryPath so it can be used with generate --history-in.
+type Session struct {
+	Name        string    `json:"name"`
+	Model       string    `json:"model"`
+	OutputDir   string    `json:"output_dir"`
+	AspectRatio string    `json:"aspect_ratio,omitempty"`
+	ImageSize   string    `json:"image_size,omitempty"`
+	Parent      string    `json:"parent,omitempty"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+	// Counter numbers saved images and never decreases, so undone turns
+	// do not get their files overwritten.
+	Counter int `json:"counter"`
+	// Turns are the turns of the current conversation, oldest first.
+	Turns []Turn `json:"turns,omitempty"`
+	// Attachments are reference images queued for the next prompt.
+	Attachments []string `json:"attachments,omitempty"`
+
+	dir string
+}
+
+// Turn records one prompt and what it produced.
+type Turn struct {
+	Prompt      string    `json:"prompt"`
+	Attachments []string  `json:"attachments,omitempty"`
+	Images      []string  `json:"images,omitempty"`
+	Time        time.Time `json:"time"`
+	// HistoryLen is the number of history contents before this turn, so the
+	// turn can be undone by truncating the history.
+	HistoryLen int `json:"history_len"`
+}
+
+// Dir returns the directory holding all sessions.
+func Dir() (string, error) {
+	return appconfig.StateDir("sessions")
+}
+
+// New creates an unsaved session.
+func New(name, model, outputDir string) (*Session, error) {
+	if err := appconfig.ValidateName("session", name); err != nil {
+		return nil, err
+	}
+	root, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &Session{
+		Name:      name,
+		Model:     model,
+		OutputDir: outputDir,
+		Created:   now,
+		Updated:   now,
+		dir:       filepath.Join(root, name),
+	}, nil
+}
+
+// Load reads a saved session. A missing session returns an error matching
+// os.ErrNotExist.
+func Load(name string) (*Session, error) {
+	if err := appconfig.ValidateName("session", name); err != nil {
+		return nil, err
+	}
+	root, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(root, name)
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("session %s not found: %w", name, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", name, err)
+	}
+	s.dir = dir
+	return &s, nil
+}
+
+// List returns the names of saved sessions, sorted.
+func List() ([]string, error) {
+	root, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(root, entry.Name(), stateFile)); entry.IsDir() && err == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Save writes the session state.
+func (s *Session) Save() error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	s.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, stateFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+// HistoryPath is where the session's conversation history is stored. The file
+// does not exist until the first turn completes.
+func (s *Session) HistoryPath() string {
+	return filepath.Join(s.dir, historyFile)
+}
+
+// NextImagePath returns the path for the next saved image and advances Counter.
+func (s *Session) NextImagePath(ext string) string {
+	s.Counter++
+	return filepath.Join(s.OutputDir, fmt.Sprintf("%s_%03d%s", s.Name, s.Counter, ext))
+}
+
+// Branch copies the session and its history under a new name and saves the
+// copy. Existing sessions are not overwritten.
+func (s *Session) Branch(name string) (*Session, error) {
+	branch, err := New(name, s.Model, s.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(branch.dir, stateFile)); err == nil {
+		return nil, fmt.Errorf("session %s already exists", name)
+	}
+
+	branch.AspectRatio = s.AspectRatio
+	branch.ImageSize = s.ImageSize
+	branch.Parent = s.Name
+	branch.Turns = append([]Turn(nil), s.Turns...)
+	branch.Attachments = append([]string(nil), s.Attachments...)
+	if err := branch.Save(); err != nil {
+		return nil, err
+	}
+	if err := copyFile(s.HistoryPath(), branch.HistoryPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to copy history: %w", err)
+	}
+	return branch, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,56 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveLoadBranch(t *testing.T) {
+	t.Setenv("NANOBANANA_CONFIG_DIR", t.TempDir())
+
+	if _, err := Load("poster"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Load missing = %v", err)
+	}
+
+	s, err := New("poster", "pro", "art")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := s.NextImagePath(".png"); got != filepath.Join("art", "poster_001.png") {
+		t.Fatalf("NextImagePath = %s", got)
+	}
+	s.Turns = append(s.Turns, Turn{Prompt: "a poster", Images: []string{"art/poster_001.png"}})
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	os.WriteFile(s.HistoryPath(), []byte(`{"model":"m","contents":[]}`), 0644)
+
+	loaded, err := Load("poster")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.Counter != 1 || len(loaded.Turns) != 1 || loaded.Model != "pro" {
+		t.Fatalf("loaded = %+v", loaded)
+	}
+
+	branch, err := loaded.Branch("poster-v2")
+	if err != nil {
+		t.Fatalf("Branch: %v", err)
+	}
+	if branch.Parent != "poster" || len(branch.Turns) != 1 {
+		t.Fatalf("branch = %+v", branch)
+	}
+	if _, err := os.Stat(branch.HistoryPath()); err != nil {
+		t.Fatalf("branch history: %v", err)
+	}
+	if _, err := loaded.Branch("poster-v2"); err == nil {
+		t.Fatal("expected branching onto an existing session to fail")
+	}
+
+	names, err := List()
+	if err != nil || len(names) != 2 || names[0] != "poster" {
+		t.Fatalf("List = %v, %v", names, err)
+	}
+}
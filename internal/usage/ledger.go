@@ -0,0 +1,142 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	appconfig "github.com/lyalindotcom/nano-banana-cli/internal/config"
+)
+
+// Entry is one line of the usage ledger, written after every successful Gemini call.
+type Entry struct {
+	Time             time.Time `json:"time"`
+	Command          string    `json:"command"`
+	Model            string    `json:"model"`
+	Images           int       `json:"images"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CandidateTokens  int       `json:"candidate_tokens"`
+	ThoughtTokens    int       `json:"thought_tokens,omitempty"`
+	TotalTokens      int       `json:"total_tokens"`
+	EstimatedCostUSD float64   `json:"estimated_cost_usd"`
+}
+
+// Summary aggregates ledger entries under a single key (day, model or command).
+type Summary struct {
+	Key              string  `json:"key"`
+	Calls            int     `json:"calls"`
+	Images           int     `json:"images"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CandidateTokens  int     `json:"candidate_tokens"`
+	ThoughtTokens    int     `json:"thought_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+}
+
+// LedgerPath returns the location of the usage ledger in the user config directory.
+func LedgerPath() (string, error) {
+	dir, err := appconfig.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "usage.jsonl"), nil
+}
+
+// Append adds an entry to the ledger at path, creating it if needed.
+func Append(path string, entry Entry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create ledger directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage entry: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage ledger: %w", err)
+	}
+	return nil
+}
+
+// Load reads every entry from the ledger. A missing ledger yields no entries.
+func Load(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("usage ledger line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	return entries, nil
+}
+
+// Summarize groups entries by "day", "model" or "command", sorted by key.
+func Summarize(entries []Entry, by string) ([]Summary, error) {
+	keyFor, err := groupKey(by)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := map[string]*Summary{}
+	for _, e := range entries {
+		key := keyFor(e)
+		s, ok := byKey[key]
+		if !ok {
+			s = &Summary{Key: key}
+			byKey[key] = s
+		}
+		s.Calls++
+		s.Images += e.Images
+		s.PromptTokens += e.PromptTokens
+		s.CandidateTokens += e.CandidateTokens
+		s.ThoughtTokens += e.ThoughtTokens
+		s.TotalTokens += e.TotalTokens
+		s.EstimatedCostUSD += e.EstimatedCostUSD
+	}
+
+	out := make([]Summary, 0, len(byKey))
+	for _, s := range byKey {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+func groupKey(by string) (func(Entry) string, error) {
+	switch by {
+	case "", "day":
+		return func(e Entry) string { return e.Time.Local().Format("2006-01-02") }, nil
+	case "model":
+		return func(e Entry) string { return e.Model }, nil
+	case "command":
+		return func(e Entry) string { return e.Command }, nil
+	default:
+		return nil, fmt.Errorf("unknown grouping %q (use day, model or command)", by)
+	}
+}
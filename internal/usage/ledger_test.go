@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendLoadSummarize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+
+	entries := []Entry{
+		{Time: day, Command: "generate", Model: "gemini-3-pro-image-preview", Images: 1, PromptTokens: 10, CandidateTokens: 1290, TotalTokens: 1300, EstimatedCostUSD: 0.15},
+		{Time: day, Command: "icon", Model: "gemini-3-pro-image-preview", Images: 1, PromptTokens: 20, CandidateTokens: 1290, TotalTokens: 1310, EstimatedCostUSD: 0.16},
+		{Time: day.Add(24 * time.Hour), Command: "generate", Model: "gemini-2.5-flash-image", Images: 2, PromptTokens: 5, CandidateTokens: 2580, TotalTokens: 2585, EstimatedCostUSD: 0.08},
+	}
+	for _, e := range entries {
+		if err := Append(path, e); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded) != len(entries) {
+		t.Fatalf("loaded %d entries, want %d", len(loaded), len(entries))
+	}
+
+	byModel, err := Summarize(loaded, "model")
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if len(byModel) != 2 || byModel[1].Key != "gemini-3-pro-image-preview" || byModel[1].Calls != 2 {
+		t.Fatalf("unexpected model summary: %+v", byModel)
+	}
+
+	byDay, err := Summarize(loaded, "day")
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if len(byDay) != 2 || byDay[0].Key != "2026-03-01" || byDay[0].Images != 2 {
+		t.Fatalf("unexpected day summary: %+v", byDay)
+	}
+
+	if _, err := Summarize(loaded, "week"); err == nil {
+		t.Fatal("Summarize accepted an unknown grouping")
+	}
+}
+
+func TestLoadMissingLedger(t *testing.T) {
+	entries, err := Load(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if err != nil || entries != nil {
+		t.Fatalf("Load(missing) = %v, %v; want nil, nil", entries, err)
+	}
+}